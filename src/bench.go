@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	benchDepth    int32  = 8
+	benchHashSize uint64 = 16
+)
+
+// A mix of openings, middlegames and endgames. Changing the list changes the bench signature
+var benchFens []string = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	"rnbqkb1r/pp1p1ppp/4pn2/2p5/2PP4/2N5/PP2PPPP/R1BQKBNR w KQkq - 0 4",
+	"r2q1rk1/pp2bppp/2n1pn2/3p4/2PP4/P1N1PN2/1P3PPP/R2QKB1R w KQ - 1 10",
+	"3r2k1/pp3ppp/4p3/3p4/3P4/1P2P3/P4PPP/2R3K1 w - - 0 25",
+	"6k1/5p2/6p1/8/7p/8/6PP/6K1 b - - 0 1",
+	"8/8/8/4k3/8/8/4P3/4K3 w - - 0 1",
+	"8/5pk1/6p1/8/1r6/6P1/5PK1/2R5 w - - 0 40",
+	"4r1k1/r1q2ppp/ppp2n2/4P3/5Rb1/1N1BQ3/PPP3PP/R5K1 w - - 1 17",
+}
+
+// Format is "bench [depth]". The node count only depends on the code, so it is quoted in commit
+// messages to show whether a change was meant to alter the search
+func BenchCommand(args []string) error {
+	depth := benchDepth
+	if len(args) > 0 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 || int32(parsed) > MaxSearchDepth {
+			return fmt.Errorf("invalid depth %q", args[0])
+		}
+		depth = int32(parsed)
+	}
+	nodes, duration, err := Bench(depth, true)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("Nodes searched:", nodes)
+	fmt.Println("Nodes/second:", uint64(float64(nodes)/max(duration.Seconds(), 0.001)))
+	fmt.Println("Time:", duration.Round(time.Millisecond))
+	return nil
+}
+
+// Searches every bench position to the depth with a single thread and a fresh table and history,
+// which is what makes the total node count reproducible
+func Bench(depth int32, verbose bool) (uint64, time.Duration, error) {
+	ResizeTable(benchHashSize)
+	SetSearchThreads(1)
+	totalNodes := uint64(0)
+	totalTime := time.Duration(0)
+	for i, fen := range benchFens {
+		s, err := ParseFEN(fen)
+		if err != nil {
+			return 0, 0, err
+		}
+		clear(transpositionTable)
+		ClearSearchHistory()
+		lastMoveScore = startingEval
+		stopSearch.Store(false)
+		start := time.Now()
+		bestMove := s.Search(SearchLimits{maxDepth: depth}, false, nil)
+		totalTime += time.Since(start)
+		nodes := totalNodesSearched()
+		totalNodes += nodes
+		if verbose {
+			fmt.Printf("Position %d/%d: %s, %d nodes\n", i+1, len(benchFens), s.SAN(bestMove), nodes)
+		}
+	}
+	return totalNodes, totalTime, nil
+}
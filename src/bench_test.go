@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestBenchDeterministic(t *testing.T) {
+	first, _, err := Bench(4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetupHashRandoms()
+	second, _, err := Bench(4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("bench node counts differ between runs: %d and %d", first, second)
+	}
+}
@@ -71,6 +71,11 @@ func (s Square) Step(step Step) Square  { return (s + Square(step)) % 64 }
 func (s Square) Rank() int8 { return int8(s / 8) }
 func (s Square) File() int8 { return int8(s % 8) }
 
+// Number of king moves between two squares
+func (s Square) Distance(other Square) int8 {
+	return max(absInt8(s.Rank()-other.Rank()), absInt8(s.File()-other.File()))
+}
+
 func PopLSB(b *Bitboard) Square {
 	lsb := Square(bits.TrailingZeros64(uint64(*b)))
 	*b &= (*b - 1)
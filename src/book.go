@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+)
+
+const (
+	polyglotEntrySize = 16
+
+	DefaultBookDepth   = 20 // Full moves
+	MaxBookDepth       = 1000
+	DefaultBookVariety = 100
+)
+
+// One move of a Polyglot .bin book. Entries are stored big endian and sorted by key
+type PolyglotEntry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+	learn  uint32
+}
+
+type OpeningBook struct {
+	path    string
+	entries []PolyglotEntry
+}
+
+type BookMove struct {
+	move   Move
+	weight uint16
+}
+
+// Depth is in full moves. Variety 0 always plays the most weighted move and 100 picks between
+// every book move in proportion to its weight, values in between only consider moves whose weight
+// is at least that far from the best one
+type BookSettings struct {
+	depth   int
+	variety int
+}
+
+// Nil when no book is loaded
+var openingBook *OpeningBook
+var bookSettings BookSettings = BookSettings{depth: DefaultBookDepth, variety: DefaultBookVariety}
+
+// Polyglot promotion pieces are none, knight, bishop, rook and queen
+var polyglotPromotions [5]int = [5]int{-1, KnightPromotion, BishopPromotion, RookPromotion, QueenPromotion}
+
+func LoadOpeningBook(path string) (*OpeningBook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%polyglotEntrySize != 0 {
+		return nil, fmt.Errorf("%s is not a Polyglot book, its size is not a multiple of %d bytes", path, polyglotEntrySize)
+	}
+	book := &OpeningBook{path: path, entries: make([]PolyglotEntry, len(data)/polyglotEntrySize)}
+	for i := range book.entries {
+		entryData := data[i*polyglotEntrySize:]
+		book.entries[i] = PolyglotEntry{
+			key:    binary.BigEndian.Uint64(entryData[0:8]),
+			move:   binary.BigEndian.Uint16(entryData[8:10]),
+			weight: binary.BigEndian.Uint16(entryData[10:12]),
+			learn:  binary.BigEndian.Uint32(entryData[12:16]),
+		}
+	}
+	// Probing relies on the order so books written by other tools are not trusted to have it
+	if !sort.SliceIsSorted(book.entries, func(i, j int) bool { return book.entries[i].key < book.entries[j].key }) {
+		sort.SliceStable(book.entries, func(i, j int) bool { return book.entries[i].key < book.entries[j].key })
+	}
+	return book, nil
+}
+
+// An empty path unloads the current book
+func SetBookFile(path string) error {
+	if path == "" {
+		openingBook = nil
+		return nil
+	}
+	book, err := LoadOpeningBook(path)
+	if err != nil {
+		return err
+	}
+	openingBook = book
+	return nil
+}
+
+// Legal book moves for the position. The state hash uses the Polyglot key layout so it is looked
+// up directly
+func (book *OpeningBook) Probe(s *State) []BookMove {
+	first := sort.Search(len(book.entries), func(i int) bool { return book.entries[i].key >= s.hashcode })
+	legalMoves := LegalMoves(s)
+	bookMoves := []BookMove{}
+	for i := first; i < len(book.entries) && book.entries[i].key == s.hashcode; i++ {
+		entry := book.entries[i]
+		move, ok := s.polyglotMoveToMove(entry.move, legalMoves)
+		if ok && entry.weight > 0 {
+			bookMoves = append(bookMoves, BookMove{move, entry.weight})
+		}
+	}
+	return bookMoves
+}
+
+// Polyglot packs the destination file and rank, then the origin file and rank, 3 bits each,
+// followed by the promotion piece. Castling is written as the king capturing its own rook
+func (s *State) polyglotMoveToMove(polyglotMove uint16, legalMoves []Move) (Move, bool) {
+	destination := sFromRankFile(int(polyglotMove&7), int((polyglotMove>>3)&7))
+	origin := sFromRankFile(int((polyglotMove>>6)&7), int((polyglotMove>>9)&7))
+	promotionIndex := int((polyglotMove >> 12) & 7)
+	if promotionIndex >= len(polyglotPromotions) {
+		return NilMove, false
+	}
+	promotion := polyglotPromotions[promotionIndex]
+	for _, move := range legalMoves {
+		if move.OriginSquare() != origin {
+			continue
+		}
+		moveDestination := move.DestinationSquare()
+		if move.SpecialMove() == CastleSpecialMove {
+			// The king lands on the g or c file while the book names the rook's square
+			moveDestination = s.castleRookSquare(move)
+		}
+		if moveDestination != destination {
+			continue
+		}
+		if move.SpecialMove() == PromotionSpecialMove {
+			if int(move.PromotionType()) != promotion {
+				continue
+			}
+		} else if promotion != -1 {
+			continue
+		}
+		return move, true
+	}
+	return NilMove, false
+}
+
+// Inverse of polyglotMoveToMove for a legal move in the state
+func (s *State) moveToPolyglotMove(move Move) uint16 {
+	origin := move.OriginSquare()
+	destination := move.DestinationSquare()
+	if move.SpecialMove() == CastleSpecialMove {
+		destination = s.castleRookSquare(move)
+	}
+	polyglotMove := uint16(destination.File()) | uint16(destination.Rank())<<3 | uint16(origin.File())<<6 | uint16(origin.Rank())<<9
+	if move.SpecialMove() == PromotionSpecialMove {
+		polyglotMove |= uint16(slices.Index(polyglotPromotions[:], int(move.PromotionType()))) << 12
+	}
+	return polyglotMove
+}
+
+// Chooses a move from the loaded book according to bookSettings
+func (s *State) BookMove() (Move, bool) {
+	if openingBook == nil || int(s.ply)/2 >= bookSettings.depth {
+		return NilMove, false
+	}
+	bookMoves := openingBook.Probe(s)
+	if len(bookMoves) == 0 {
+		return NilMove, false
+	}
+	return chooseBookMove(bookMoves, bookSettings.variety, rand.Intn), true
+}
+
+func chooseBookMove(bookMoves []BookMove, variety int, randomInt func(int) int) Move {
+	best := bookMoves[0]
+	for _, bookMove := range bookMoves {
+		if bookMove.weight > best.weight {
+			best = bookMove
+		}
+	}
+	if variety <= 0 {
+		return best.move
+	}
+	minWeight := int(best.weight) * (100 - min(variety, 100)) / 100
+	totalWeight := 0
+	for _, bookMove := range bookMoves {
+		if int(bookMove.weight) >= minWeight {
+			totalWeight += int(bookMove.weight)
+		}
+	}
+	choice := randomInt(totalWeight)
+	for _, bookMove := range bookMoves {
+		if int(bookMove.weight) < minWeight {
+			continue
+		}
+		choice -= int(bookMove.weight)
+		if choice < 0 {
+			return bookMove.move
+		}
+	}
+	return best.move
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type BookBuilderSettings struct {
+	pgnPaths   []string
+	outPath    string
+	maxPly     int
+	minGames   int
+	minElo     int // Both players must be rated at least this, 0 also allows unrated games
+	winScore   int
+	drawScore  int
+	lossScore  int
+	maxEntries int // Only the most played moves are kept when set
+}
+
+// Games and results collected for one move out of one position
+type BookBuilderMove struct {
+	games int
+	score int
+}
+
+type BookBuilder struct {
+	settings     *BookBuilderSettings
+	positions    map[uint64]map[uint16]*BookBuilderMove
+	gamesUsed    int
+	gamesSkipped int
+}
+
+// Parses the command line of "ghobos makebook" and writes the book
+func MakeBookCommand(args []string) error {
+	settings, err := parseBookBuilderFlags(args)
+	if err != nil {
+		return err
+	}
+	builder := NewBookBuilder(settings)
+	for _, path := range settings.pgnPaths {
+		if err := builder.AddPGNFile(path); err != nil {
+			return err
+		}
+	}
+	entries := builder.Entries()
+	if err := WritePolyglotBook(settings.outPath, entries); err != nil {
+		return err
+	}
+	fmt.Printf("Used %d games and skipped %d, wrote %d moves for %d positions to %s\n", builder.gamesUsed, builder.gamesSkipped,
+		len(entries), countBookPositions(entries), settings.outPath)
+	return nil
+}
+
+func parseBookBuilderFlags(args []string) (*BookBuilderSettings, error) {
+	flags := flag.NewFlagSet("makebook", flag.ContinueOnError)
+	outPath := flags.String("out", "book.bin", "Polyglot book to write")
+	maxPly := flags.Int("maxply", 30, "only moves within this many plies of the start are added")
+	minGames := flags.Int("mingames", 3, "moves played in fewer games are left out")
+	minElo := flags.Int("minelo", 0, "games are only used if both players are rated at least this")
+	winScore := flags.Int("win", 2, "weight a move gets for every game won by the side that played it")
+	drawScore := flags.Int("draw", 1, "weight a move gets for every drawn game")
+	lossScore := flags.Int("loss", 0, "weight a move gets for every game lost by the side that played it")
+	maxEntries := flags.Int("maxentries", 0, "keep only this many of the most played moves, 0 keeps all of them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ghobos makebook [flags] games.pgn...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() == 0 {
+		return nil, errors.New("no PGN files given")
+	}
+	if *winScore < 0 || *drawScore < 0 || *lossScore < 0 {
+		return nil, errors.New("result weights can not be negative")
+	}
+	return &BookBuilderSettings{
+		pgnPaths:   flags.Args(),
+		outPath:    *outPath,
+		maxPly:     *maxPly,
+		minGames:   max(*minGames, 1),
+		minElo:     *minElo,
+		winScore:   *winScore,
+		drawScore:  *drawScore,
+		lossScore:  *lossScore,
+		maxEntries: *maxEntries,
+	}, nil
+}
+
+func NewBookBuilder(settings *BookBuilderSettings) *BookBuilder {
+	return &BookBuilder{settings: settings, positions: map[uint64]map[uint16]*BookBuilderMove{}}
+}
+
+// Games that can not be read are counted as skipped rather than stopping the build
+func (builder *BookBuilder) AddPGNFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := NewPGNReader(file)
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			builder.gamesSkipped++
+			continue
+		}
+		if !builder.AddGame(game) {
+			builder.gamesSkipped++
+		}
+	}
+}
+
+// Returns false if the game was filtered out
+func (builder *BookBuilder) AddGame(game *PGNGame) bool {
+	settings := builder.settings
+	var whiteScore int
+	switch game.result {
+	case "1-0":
+		whiteScore = 2
+	case "0-1":
+		whiteScore = 0
+	case "1/2-1/2":
+		whiteScore = 1
+	default:
+		return false
+	}
+	if settings.minElo > 0 {
+		whiteElo, whiteErr := strconv.Atoi(game.Tag("WhiteElo"))
+		blackElo, blackErr := strconv.Atoi(game.Tag("BlackElo"))
+		if whiteErr != nil || blackErr != nil || whiteElo < settings.minElo || blackElo < settings.minElo {
+			return false
+		}
+	}
+	s, err := game.StartState()
+	if err != nil {
+		return false
+	}
+	for ply, pgnMove := range game.moves {
+		if ply >= settings.maxPly {
+			break
+		}
+		moves := builder.positions[s.hashcode]
+		if moves == nil {
+			moves = map[uint16]*BookBuilderMove{}
+			builder.positions[s.hashcode] = moves
+		}
+		polyglotMove := s.moveToPolyglotMove(pgnMove.move)
+		stats := moves[polyglotMove]
+		if stats == nil {
+			stats = &BookBuilderMove{}
+			moves[polyglotMove] = stats
+		}
+		stats.games++
+		sideScore := whiteScore
+		if s.turn == Black {
+			sideScore = 2 - whiteScore
+		}
+		stats.score += [3]int{settings.lossScore, settings.drawScore, settings.winScore}[sideScore]
+		s.MakeMove(pgnMove.move)
+	}
+	builder.gamesUsed++
+	return true
+}
+
+// Book entries sorted by key and then by weight. Moves below the minimum game count or without
+// any weight are left out and weights are scaled per position to fit in 16 bits
+func (builder *BookBuilder) Entries() []PolyglotEntry {
+	settings := builder.settings
+	type candidate struct {
+		entry PolyglotEntry
+		games int
+	}
+	candidates := []candidate{}
+	for key, moves := range builder.positions {
+		maxScore := 0
+		for _, stats := range moves {
+			if stats.games >= settings.minGames {
+				maxScore = max(maxScore, stats.score)
+			}
+		}
+		scale := 1.0
+		if maxScore > math.MaxUint16 {
+			scale = float64(math.MaxUint16) / float64(maxScore)
+		}
+		for polyglotMove, stats := range moves {
+			weight := uint16(float64(stats.score) * scale)
+			if stats.games < settings.minGames || weight == 0 {
+				continue
+			}
+			candidates = append(candidates, candidate{PolyglotEntry{key: key, move: polyglotMove, weight: weight}, stats.games})
+		}
+	}
+	if settings.maxEntries > 0 && len(candidates) > settings.maxEntries {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].games > candidates[j].games })
+		candidates = candidates[:settings.maxEntries]
+	}
+	entries := make([]PolyglotEntry, len(candidates))
+	for i, candidate := range candidates {
+		entries[i] = candidate.entry
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		if entries[i].weight != entries[j].weight {
+			return entries[i].weight > entries[j].weight
+		}
+		return entries[i].move < entries[j].move
+	})
+	return entries
+}
+
+func WritePolyglotBook(path string, entries []PolyglotEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	data := make([]byte, 0, polyglotEntrySize)
+	for _, entry := range entries {
+		data = binary.BigEndian.AppendUint64(data[:0], entry.key)
+		data = binary.BigEndian.AppendUint16(data, entry.move)
+		data = binary.BigEndian.AppendUint16(data, entry.weight)
+		data = binary.BigEndian.AppendUint32(data, entry.learn)
+		if _, err := writer.Write(data); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func countBookPositions(entries []PolyglotEntry) int {
+	positions := 0
+	for i, entry := range entries {
+		if i == 0 || entries[i-1].key != entry.key {
+			positions++
+		}
+	}
+	return positions
+}
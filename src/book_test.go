@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolyglotMoveEncoding(t *testing.T) {
+	s := StartingFen()
+	e4, _ := s.moveFromShortString("e2e4")
+	if encoded := s.moveToPolyglotMove(e4); encoded != 796 {
+		t.Errorf("e2e4 encoded as %d, want 796", encoded)
+	}
+	castling, err := ParseFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortCastle, _ := castling.moveFromShortString("e1g1")
+	// The king captures its own rook on h1
+	if encoded := castling.moveToPolyglotMove(shortCastle); encoded != 263 {
+		t.Errorf("e1g1 encoded as %d, want 263", encoded)
+	}
+	for _, test := range perftCases {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legalMoves := LegalMoves(s)
+		for _, move := range legalMoves {
+			decoded, ok := s.polyglotMoveToMove(s.moveToPolyglotMove(move), legalMoves)
+			if !ok || decoded != move {
+				t.Errorf("%s in %s did not survive a Polyglot round trip", move.ShortString(), test.fen)
+			}
+		}
+	}
+}
+
+func TestBookProbe(t *testing.T) {
+	s := StartingFen()
+	entries := []struct {
+		move   string
+		weight uint16
+	}{{"e2e4", 10}, {"d2d4", 30}, {"g1f3", 0}}
+	data := []byte{}
+	for _, entry := range entries {
+		move, _ := s.moveFromShortString(entry.move)
+		data = binary.BigEndian.AppendUint64(data, s.hashcode)
+		data = binary.BigEndian.AppendUint16(data, s.moveToPolyglotMove(move))
+		data = binary.BigEndian.AppendUint16(data, entry.weight)
+		data = binary.BigEndian.AppendUint32(data, 0)
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	book, err := LoadOpeningBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookMoves := book.Probe(s)
+	// Moves with no weight are never played
+	if len(bookMoves) != 2 {
+		t.Fatalf("found %d book moves, want 2", len(bookMoves))
+	}
+	if move := chooseBookMove(bookMoves, 0, nil); move.ShortString() != "d2d4" {
+		t.Errorf("variety 0 chose %s, want d2d4", move.ShortString())
+	}
+	// With full variety the first 10 of the 40 weight go to e2e4
+	if move := chooseBookMove(bookMoves, 100, func(int) int { return 9 }); move.ShortString() != "e2e4" {
+		t.Errorf("variety 100 chose %s, want e2e4", move.ShortString())
+	}
+	if move := chooseBookMove(bookMoves, 100, func(int) int { return 10 }); move.ShortString() != "d2d4" {
+		t.Errorf("variety 100 chose %s, want d2d4", move.ShortString())
+	}
+	// Half variety leaves out moves below half the best weight
+	if move := chooseBookMove(bookMoves, 50, func(int) int { return 0 }); move.ShortString() != "d2d4" {
+		t.Errorf("variety 50 chose %s, want d2d4", move.ShortString())
+	}
+	e4, _ := s.moveFromShortString("e2e4")
+	s.MakeMove(e4)
+	if len(book.Probe(s)) != 0 {
+		t.Error("found book moves for a position not in the book")
+	}
+}
+
+// Entries written by hand with the published Polyglot keys, as any other tool would write them
+func TestBookProbePolyglotKeys(t *testing.T) {
+	entries := []PolyglotEntry{
+		{key: 0x463B96181691FC9C, move: 796, weight: 2},  // e2e4 from the start position
+		{key: 0x463B96181691FC9C, move: 731, weight: 1},  // d2d4
+		{key: 0x823C9B50FD114196, move: 3364, weight: 1}, // e7e5 after 1.e4
+		{key: 0x823C9B50FD114196, move: 3234, weight: 3}, // c7c5
+	}
+	data := []byte{}
+	for _, entry := range entries {
+		data = binary.BigEndian.AppendUint64(data, entry.key)
+		data = binary.BigEndian.AppendUint16(data, entry.move)
+		data = binary.BigEndian.AppendUint16(data, entry.weight)
+		data = binary.BigEndian.AppendUint32(data, entry.learn)
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	book, err := LoadOpeningBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := func(s *State) map[string]uint16 {
+		weights := map[string]uint16{}
+		for _, bookMove := range book.Probe(s) {
+			weights[bookMove.move.ShortString()] = bookMove.weight
+		}
+		return weights
+	}
+	s := StartingFen()
+	if got := weights(s); len(got) != 2 || got["e2e4"] != 2 || got["d2d4"] != 1 {
+		t.Errorf("start position book moves %v, want e2e4 with weight 2 and d2d4 with weight 1", got)
+	}
+	e4, _ := s.moveFromShortString("e2e4")
+	s.MakeMove(e4)
+	if got := weights(s); len(got) != 2 || got["e7e5"] != 1 || got["c7c5"] != 3 {
+		t.Errorf("book moves after e4 %v, want e7e5 with weight 1 and c7c5 with weight 3", got)
+	}
+}
+
+func TestBookBuilder(t *testing.T) {
+	games := `[WhiteElo "2500"]
+[BlackElo "2400"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[WhiteElo "2500"]
+[BlackElo "2100"]
+[Result "1/2-1/2"]
+
+1. e4 c5 2. Nf3 1/2-1/2
+
+[WhiteElo "2600"]
+[BlackElo "2500"]
+[Result "0-1"]
+
+1. d4 d5 0-1
+
+[Result "*"]
+
+1. e4 e5 *
+`
+	directory := t.TempDir()
+	pgnPath := filepath.Join(directory, "games.pgn")
+	if err := os.WriteFile(pgnPath, []byte(games), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	build := func(args ...string) *OpeningBook {
+		bookPath := filepath.Join(directory, "book.bin")
+		settings, err := parseBookBuilderFlags(append(append([]string{"-out", bookPath}, args...), pgnPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder := NewBookBuilder(settings)
+		if err := builder.AddPGNFile(pgnPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := WritePolyglotBook(bookPath, builder.Entries()); err != nil {
+			t.Fatal(err)
+		}
+		book, err := LoadOpeningBook(bookPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return book
+	}
+	weights := func(book *OpeningBook, s *State) map[string]uint16 {
+		weights := map[string]uint16{}
+		for _, bookMove := range book.Probe(s) {
+			weights[bookMove.move.ShortString()] = bookMove.weight
+		}
+		return weights
+	}
+	s := StartingFen()
+	// e4 won once and drew once, d4 lost its only game and has no weight
+	book := build("-mingames", "1")
+	if got := weights(book, s); len(got) != 1 || got["e2e4"] != 3 {
+		t.Errorf("start position book moves %v, want e2e4 with weight 3", got)
+	}
+	// The file has to be readable by other tools, so the key written is the published one
+	startKeyFound := false
+	for _, entry := range book.entries {
+		startKeyFound = startKeyFound || entry.key == 0x463B96181691FC9C
+	}
+	if !startKeyFound {
+		t.Error("the book has no entry with the Polyglot key of the start position, 0x463B96181691FC9C")
+	}
+	e4, _ := s.moveFromShortString("e2e4")
+	s.MakeMove(e4)
+	// Black's weights are from its own point of view
+	if got := weights(book, s); len(got) != 1 || got["c7c5"] != 1 {
+		t.Errorf("book moves after e4 %v, want c7c5 with weight 1", got)
+	}
+	if got := weights(build("-mingames", "2"), s); len(got) != 0 {
+		t.Errorf("book moves after e4 with 2 minimum games %v, want none", got)
+	}
+	if got := weights(build("-mingames", "1", "-maxply", "1"), s); len(got) != 0 {
+		t.Errorf("book moves after e4 with a max ply of 1 %v, want none", got)
+	}
+	s.UnMakeMove(e4)
+	if got := weights(build("-mingames", "1", "-minelo", "2200", "-loss", "1"), s); len(got) != 2 || got["e2e4"] != 2 || got["d2d4"] != 1 {
+		t.Errorf("start position book moves with a 2200 minimum Elo %v, want e2e4 with weight 2 and d2d4 with weight 1", got)
+	}
+}
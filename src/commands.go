@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Non interactive commands run as "ghobos <command> [flags]"
+var commands map[string]func(args []string) error = map[string]func(args []string) error{
+	"match":      MatchCommand,
+	"epd":        EPDCommand,
+	"perft":      PerftCommand,
+	"bench":      BenchCommand,
+	"generate":   GenerateCommand,
+	"makebook":   MakeBookCommand,
+	"datagen":    DatagenCommand,
+	"tune":       TuneCommand,
+	"evalparams": EvalParamsCommand,
+	"eval":       EvalCommand,
+}
+
+func runCommand(args []string) error {
+	command, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available commands are %v", args[0], names)
+	}
+	// Commands that search resize the table themselves, a small one is enough for hashing positions
+	SetupTable(MinHashSize)
+	return command(args[1:])
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	trainingRecordSize  = 32
+	noTrainingEnPassant = 64
+)
+
+type DatagenSettings struct {
+	outPath     string
+	openings    []string
+	games       int
+	concurrency int
+	limits      SearchLimits
+	randomPlies int
+	maxPlies    int   // Games this long are scored as draws
+	winScore    int32 // Centipawns, a game is won once the score stays past this for winPlies plies
+	winPlies    int
+	hashSize    uint64
+	seed        int64
+}
+
+// A quiet position from a finished game. The score is in centipawns and, like the result, from
+// white's point of view. Results are 1 for a white win, 0.5 for a draw and 0 for a black win
+type TrainingPosition struct {
+	fen    string
+	score  int16
+	result float64
+}
+
+type DatagenGame struct {
+	positions []TrainingPosition
+	result    string
+}
+
+// Text files have one "FEN;score;result" line per position. Files ending in .bin are packed with
+// 32 bytes per position, see packTrainingPosition
+type TrainingWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	packed bool
+}
+
+// Parses the command line of "ghobos datagen" and plays the games
+func DatagenCommand(args []string) error {
+	settings, err := parseDatagenFlags(args)
+	if err != nil {
+		return err
+	}
+	return RunDatagen(settings)
+}
+
+func parseDatagenFlags(args []string) (*DatagenSettings, error) {
+	flags := flag.NewFlagSet("datagen", flag.ContinueOnError)
+	outPath := flags.String("out", "data.txt", "file positions are appended to, packed if it ends in .bin and text otherwise")
+	openingsPath := flags.String("openings", "", "file with one opening FEN or EPD per line, the start position is used if empty")
+	games := flags.Int("games", 100, "number of games to play")
+	concurrency := flags.Int("concurrency", 1, "number of games played at the same time")
+	nodes := flags.Uint64("nodes", 5000, "nodes per move, 0 for no limit")
+	depth := flags.Int("depth", 0, "depth per move, 0 for no limit")
+	randomPlies := flags.Int("random", 8, "random plies played from the opening before the search takes over")
+	maxPlies := flags.Int("maxply", 400, "plies after which the game is a draw")
+	winScore := flags.Int("winscore", 2000, "score in centipawns after which a game is adjudicated as won")
+	winPlies := flags.Int("winplies", 4, "consecutive plies the score must stay past -winscore, 0 disables")
+	hashSize := flags.Uint64("hash", 64, "transposition table size in megabytes, shared by every game")
+	seed := flags.Int64("seed", 0, "seed for the random openings, 0 picks one from the clock")
+	evalFile := flags.String("evalfile", "", "network used instead of the handcrafted evaluation")
+	evalParamsPath := flags.String("evalparams", "", "JSON file with the weights of the handcrafted evaluation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ghobos datagen [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *nodes == 0 && *depth == 0 {
+		return nil, errors.New("either -nodes or -depth has to limit the search")
+	}
+	if *hashSize < MinHashSize || *hashSize > MaxHashSize {
+		return nil, fmt.Errorf("hash size must be between %d and %d", MinHashSize, MaxHashSize)
+	}
+	if err := SetEvalParamsFile(*evalParamsPath); err != nil {
+		return nil, err
+	}
+	if *evalFile != "" {
+		if err := SetEvalFile(*evalFile); err != nil {
+			return nil, err
+		}
+		useNNUE = true
+	}
+	settings := &DatagenSettings{
+		outPath:     *outPath,
+		openings:    []string{startingFenString},
+		games:       *games,
+		concurrency: max(*concurrency, 1),
+		limits:      SearchLimits{maxDepth: int32(*depth), maxNodes: *nodes},
+		randomPlies: max(*randomPlies, 0),
+		maxPlies:    *maxPlies,
+		winScore:    int32(*winScore),
+		winPlies:    *winPlies,
+		hashSize:    *hashSize,
+		seed:        *seed,
+	}
+	if settings.seed == 0 {
+		settings.seed = time.Now().UnixNano()
+	}
+	if *openingsPath != "" {
+		openings, err := readOpenings(*openingsPath)
+		if err != nil {
+			return nil, err
+		}
+		settings.openings = openings
+	}
+	return settings, nil
+}
+
+func RunDatagen(settings *DatagenSettings) error {
+	ResizeTable(settings.hashSize)
+	writer, err := CreateTrainingFile(settings.outPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Playing %d games with seed %d\n", settings.games, settings.seed)
+	jobs := make(chan int, settings.games)
+	for game := range settings.games {
+		jobs <- game
+	}
+	close(jobs)
+	results := make(chan DatagenGame)
+	workers := sync.WaitGroup{}
+	for range settings.concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			// Every game gets a main thread worker so a search always finishes with a move
+			worker := NewSearchWorker(0)
+			for game := range jobs {
+				results <- playDatagenGame(settings, worker, rand.New(rand.NewSource(settings.seed+int64(game))))
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+	start := time.Now()
+	gamesPlayed, positionsWritten := 0, 0
+	resultCounts := map[string]int{}
+	var writeErr error
+	for game := range results {
+		gamesPlayed++
+		resultCounts[game.result]++
+		for _, position := range game.positions {
+			if writeErr == nil {
+				writeErr = writer.Write(position)
+			}
+		}
+		positionsWritten += len(game.positions)
+		if gamesPlayed%10 == 0 || gamesPlayed == settings.games {
+			fmt.Printf("Games %d/%d (+%d =%d -%d), %d positions, %.0f positions/s\n", gamesPlayed, settings.games, resultCounts["1-0"],
+				resultCounts["1/2-1/2"], resultCounts["0-1"], positionsWritten, float64(positionsWritten)/max(time.Since(start).Seconds(), 0.001))
+		}
+	}
+	return errors.Join(writeErr, writer.Close())
+}
+
+// Plays one game from a random opening and returns its quiet positions
+func playDatagenGame(settings *DatagenSettings, worker *SearchWorker, random *rand.Rand) DatagenGame {
+	s := datagenOpening(settings, random)
+	worker.clearHistory()
+	positions := []TrainingPosition{}
+	winStreaks := [2]int{}
+	result := ""
+	for ply := 0; result == ""; ply++ {
+		if gameResult, _, over := s.gameResult(); over {
+			result = gameResult
+			break
+		} else if insufficientMaterial(s) || ply >= settings.maxPlies {
+			result = "1/2-1/2"
+			break
+		}
+		move, score := worker.Run(s, settings.limits, nil, nil)
+		if score < -mateValueCutoff || score > mateValueCutoff {
+			// Mate scores say nothing about the evaluation and the game is decided anyway
+			if (score > 0) == (s.turn == White) {
+				result = "1-0"
+			} else {
+				result = "0-1"
+			}
+			break
+		}
+		whiteScore := score / CentiPawn
+		if s.turn == Black {
+			whiteScore = -whiteScore
+		}
+		if !s.isCapture(move) && move.SpecialMove() != PromotionSpecialMove && worker.isQuiet(s) {
+			positions = append(positions, TrainingPosition{fen: s.fenString(), score: int16(whiteScore)})
+		}
+		winStreaks[White] = streak(winStreaks[White], whiteScore >= settings.winScore)
+		winStreaks[Black] = streak(winStreaks[Black], whiteScore <= -settings.winScore)
+		if settings.winPlies > 0 && winStreaks[White] >= settings.winPlies {
+			result = "1-0"
+		} else if settings.winPlies > 0 && winStreaks[Black] >= settings.winPlies {
+			result = "0-1"
+		}
+		s.MakeMove(move)
+	}
+	whiteResult := map[string]float64{"1-0": 1, "1/2-1/2": 0.5, "0-1": 0}[result]
+	for i := range positions {
+		positions[i].result = whiteResult
+	}
+	return DatagenGame{positions: positions, result: result}
+}
+
+// Random moves from one of the openings, retried until the game is not already over
+func datagenOpening(settings *DatagenSettings, random *rand.Rand) *State {
+	for {
+		s := FenState(settings.openings[random.Intn(len(settings.openings))])
+		for range settings.randomPlies {
+			moves := LegalMoves(s)
+			if len(moves) == 0 {
+				break
+			}
+			s.MakeMove(moves[random.Intn(len(moves))])
+		}
+		if _, _, over := s.gameResult(); !over {
+			return s
+		}
+	}
+}
+
+// Positions in check or where a capture beats the static evaluation are left out because the
+// evaluation alone can not be expected to score them
+func (w *SearchWorker) isQuiet(s *State) bool {
+	if s.check {
+		return false
+	}
+	w.prepare(s, SearchLimits{}, time.Now(), nil)
+	score, _ := w.QuiescenceSearch(LowestEval, highestEval)
+	return score == s.EvalState(s.turn)
+}
+
+func CreateTrainingFile(path string) (*TrainingWriter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &TrainingWriter{file: file, writer: bufio.NewWriter(file), packed: strings.HasSuffix(path, ".bin")}, nil
+}
+
+func (writer *TrainingWriter) Write(position TrainingPosition) error {
+	if !writer.packed {
+		_, err := fmt.Fprintf(writer.writer, "%s;%d;%s\n", position.fen, position.score, strconv.FormatFloat(position.result, 'f', 1, 64))
+		return err
+	}
+	record, err := packTrainingPosition(position)
+	if err != nil {
+		return err
+	}
+	_, err = writer.writer.Write(record)
+	return err
+}
+
+func (writer *TrainingWriter) Close() error {
+	if err := writer.writer.Flush(); err != nil {
+		writer.file.Close()
+		return err
+	}
+	return writer.file.Close()
+}
+
+func ReadTrainingFile(path string) ([]TrainingPosition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	positions := []TrainingPosition{}
+	if strings.HasSuffix(path, ".bin") {
+		if len(data)%trainingRecordSize != 0 {
+			return nil, fmt.Errorf("%s is not packed training data, its size is not a multiple of %d bytes", path, trainingRecordSize)
+		}
+		for i := 0; i < len(data); i += trainingRecordSize {
+			position, err := unpackTrainingPosition(data[i : i+trainingRecordSize])
+			if err != nil {
+				return nil, fmt.Errorf("%s record %d: %w", path, i/trainingRecordSize+1, err)
+			}
+			positions = append(positions, position)
+		}
+		return positions, nil
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		position, err := parseTrainingLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+func parseTrainingLine(line string) (TrainingPosition, error) {
+	fields := strings.Split(line, ";")
+	if len(fields) != 3 {
+		return TrainingPosition{}, fmt.Errorf("expected FEN;score;result but found %q", line)
+	}
+	if _, err := ParseFEN(fields[0]); err != nil {
+		return TrainingPosition{}, err
+	}
+	score, err := strconv.ParseInt(fields[1], 10, 16)
+	if err != nil {
+		return TrainingPosition{}, fmt.Errorf("invalid score %q", fields[1])
+	}
+	result, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil || (result != 0 && result != 0.5 && result != 1) {
+		return TrainingPosition{}, fmt.Errorf("invalid result %q", fields[2])
+	}
+	return TrainingPosition{fen: fields[0], score: int16(score), result: result}, nil
+}
+
+// Packed records are little endian:
+//
+//	0-7   occupied squares
+//	8-23  a piece for every occupied square from a1 upwards, 4 bits each with the low half first,
+//	      numbered like the boards of a State
+//	24    bit 0 is the side to move, bits 1-4 the castling rights K, k, Q and q
+//	25    en passant square, 64 if there is none
+//	26    halfmove clock, at most 255
+//	27-28 fullmove number
+//	29-30 score
+//	31    result, 0 for a black win, 1 for a draw and 2 for a white win
+func packTrainingPosition(position TrainingPosition) ([]byte, error) {
+	s, err := ParseFEN(position.fen)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, 0, trainingRecordSize)
+	record = binary.LittleEndian.AppendUint64(record, uint64(s.occupied))
+	pieces := [16]byte{}
+	occupied := s.occupied
+	for i := 0; occupied != 0; i++ {
+		piece := s.board.getPieceAt(PopLSB(&occupied))
+		pieces[i/2] |= piece << (4 * (i % 2))
+	}
+	record = append(record, pieces[:]...)
+	flags := s.turn
+	for i, available := range s.castleAvailability {
+		if available {
+			flags |= 1 << (i + 1)
+		}
+	}
+	enPassant := byte(noTrainingEnPassant)
+	if s.canEnpassant {
+		enPassant = byte(s.enPassantSquare)
+	}
+	record = append(record, flags, enPassant, byte(min(s.lastCapOrPawn, 255)))
+	record = binary.LittleEndian.AppendUint16(record, s.ply/2+1)
+	record = binary.LittleEndian.AppendUint16(record, uint16(position.score))
+	return append(record, byte(position.result*2)), nil
+}
+
+func unpackTrainingPosition(record []byte) (TrainingPosition, error) {
+	occupied := binary.LittleEndian.Uint64(record[0:8])
+	if bits.OnesCount64(occupied) > 32 {
+		return TrainingPosition{}, errors.New("more than 32 pieces")
+	}
+	squares := [64]byte{}
+	for i := range squares {
+		squares[i] = ' '
+	}
+	for i := 0; occupied != 0; i++ {
+		square := bits.TrailingZeros64(occupied)
+		occupied &= occupied - 1
+		piece := (record[8+i/2] >> (4 * (i % 2))) & 15
+		if piece > 11 {
+			return TrainingPosition{}, fmt.Errorf("invalid piece %d", piece)
+		}
+		squares[square] = "KQRBNPkqrbnp"[piece]
+	}
+	fen := strings.Builder{}
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			if piece := squares[rank*8+file]; piece == ' ' {
+				empty++
+			} else {
+				if empty > 0 {
+					fen.WriteString(strconv.Itoa(empty))
+				}
+				fen.WriteByte(piece)
+				empty = 0
+			}
+		}
+		if empty > 0 {
+			fen.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			fen.WriteByte('/')
+		}
+	}
+	flags := record[24]
+	fen.WriteString([]string{" w ", " b "}[flags&1])
+	castling := ""
+	for i, right := range []string{"K", "Q", "k", "q"} {
+		// Rights are stored in the order of castleAvailability, which alternates colors
+		if flags&(1<<([4]int{0, 2, 1, 3}[i]+1)) != 0 {
+			castling += right
+		}
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	enPassant := "-"
+	if record[25] != noTrainingEnPassant {
+		if record[25] > 63 {
+			return TrainingPosition{}, fmt.Errorf("invalid en passant square %d", record[25])
+		}
+		enPassant = Square(record[25]).String()
+	}
+	fmt.Fprintf(&fen, "%s %s %d %d", castling, enPassant, record[26], binary.LittleEndian.Uint16(record[27:29]))
+	if record[31] > 2 {
+		return TrainingPosition{}, fmt.Errorf("invalid result %d", record[31])
+	}
+	position := TrainingPosition{
+		fen:    fen.String(),
+		score:  int16(binary.LittleEndian.Uint16(record[29:31])),
+		result: float64(record[31]) / 2,
+	}
+	if _, err := ParseFEN(position.fen); err != nil {
+		return TrainingPosition{}, err
+	}
+	return position, nil
+}
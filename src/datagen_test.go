@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTrainingFileFormats(t *testing.T) {
+	positions := []TrainingPosition{
+		{startingFenString, 25, 0.5},
+		{"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 3", -310, 0},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 17 60", 20000, 1},
+	}
+	for _, test := range perftCases {
+		positions = append(positions, TrainingPosition{FenState(test.fen).fenString(), -1, 1})
+	}
+	for _, name := range []string{"data.txt", "data.bin"} {
+		path := filepath.Join(t.TempDir(), name)
+		writer, err := CreateTrainingFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, position := range positions {
+			if err := writer.Write(position); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadTrainingFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(read, positions) {
+			t.Errorf("%s: read %v, want %v", name, read, positions)
+		}
+	}
+}
+
+func TestDatagenGame(t *testing.T) {
+	settings := &DatagenSettings{
+		openings:    []string{startingFenString},
+		limits:      SearchLimits{maxNodes: 1000},
+		randomPlies: 8,
+		maxPlies:    60,
+		winScore:    1000,
+		winPlies:    4,
+	}
+	game := playDatagenGame(settings, NewSearchWorker(0), rand.New(rand.NewSource(1)))
+	if len(game.positions) == 0 {
+		t.Fatal("no positions were kept")
+	}
+	whiteResult := map[string]float64{"1-0": 1, "1/2-1/2": 0.5, "0-1": 0}[game.result]
+	for _, position := range game.positions {
+		s, err := ParseFEN(position.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.check {
+			t.Errorf("%s is in check", position.fen)
+		}
+		if position.result != whiteResult {
+			t.Errorf("%s has result %v but the game ended %s", position.fen, position.result, game.result)
+		}
+	}
+}
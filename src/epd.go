@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Points given to a best move when the position has no STS style c0 scores
+const epdBestMovePoints = 10
+
+// A position from an EPD file along with the opcodes the test runner understands
+type EPDPosition struct {
+	id         string
+	state      *State
+	bestMoves  []Move       // bm
+	avoidMoves []Move       // am
+	points     map[Move]int // From a c0 comment in the STS format, eg "Nf3=10, e4=7"
+}
+
+type EPDSettings struct {
+	paths    []string
+	limits   SearchLimits
+	hashSize uint64
+	threads  int
+	verbose  bool
+}
+
+type EPDResult struct {
+	position       *EPDPosition
+	move           Move
+	score          int32
+	solved         bool
+	timeToSolution time.Duration // Elapsed time of the first iteration after which the best move stayed a solution
+	points         int
+	depth          int32
+	nodes          uint64
+}
+
+// Reads one EPD record: the first four FEN fields followed by ";" terminated operations, whose
+// operands may be quoted strings
+func ParseEPD(line string) (*EPDPosition, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid EPD %q: expected at least 4 fields", line)
+	}
+	state, err := ParseFEN(strings.Join(fields[:4], " "))
+	if err != nil {
+		return nil, err
+	}
+	position := &EPDPosition{state: state}
+	// Skip past the four FEN fields in the original line so quoted operands keep their spacing
+	rest := line
+	for range 4 {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end == -1 {
+			end = len(rest)
+		}
+		rest = rest[end:]
+	}
+	operations, err := parseEPDOperations(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPD %q: %w", line, err)
+	}
+	for _, operation := range operations {
+		opcode := operation[0]
+		operands := operation[1:]
+		switch opcode {
+		case "id":
+			position.id = strings.Join(operands, " ")
+		case "bm", "am":
+			moves, err := parseEPDMoves(state, operands)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EPD %q: %w", line, err)
+			}
+			if opcode == "bm" {
+				position.bestMoves = moves
+			} else {
+				position.avoidMoves = moves
+			}
+		case "c0":
+			// c0 is a free form comment, it only holds scores if every entry reads as one
+			if len(operands) == 1 {
+				position.points = parseSTSPoints(state, operands[0])
+			}
+		}
+	}
+	if len(position.bestMoves) == 0 && len(position.avoidMoves) == 0 {
+		return nil, fmt.Errorf("invalid EPD %q: no bm or am operation", line)
+	}
+	return position, nil
+}
+
+func parseEPDOperations(operationString string) ([][]string, error) {
+	operations := [][]string{}
+	current := []string{}
+	token := strings.Builder{}
+	inToken, inQuotes := false, false
+	endToken := func() {
+		if inToken {
+			current = append(current, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for _, c := range operationString {
+		switch {
+		case inQuotes:
+			if c == '"' {
+				inQuotes = false
+			} else {
+				token.WriteRune(c)
+			}
+		case c == '"':
+			inQuotes, inToken = true, true
+		case c == ';':
+			endToken()
+			if len(current) > 0 {
+				operations = append(operations, current)
+			}
+			current = []string{}
+		case c == ' ' || c == '\t':
+			endToken()
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated string operand")
+	}
+	endToken()
+	if len(current) > 0 {
+		return nil, fmt.Errorf("operation %q is missing its \";\"", strings.Join(current, " "))
+	}
+	return operations, nil
+}
+
+// Moves are normally in SAN but some suites use coordinates
+func parseEPDMoves(s *State, moveStrings []string) ([]Move, error) {
+	moves := []Move{}
+	for _, moveString := range moveStrings {
+		move, err := s.ParseSAN(moveString)
+		if err != nil {
+			coordinateMove, ok := s.moveFromShortString(moveString)
+			if !ok {
+				return nil, err
+			}
+			move = coordinateMove
+		}
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
+
+// STS suites list the points for each good move in c0, eg "f5=10, Be5+=2, Bf2=3"
+func parseSTSPoints(s *State, comment string) map[Move]int {
+	points := map[Move]int{}
+	for _, entry := range strings.Split(comment, ",") {
+		// The last "=" as promotions such as e8=Q=10 have their own
+		index := strings.LastIndex(entry, "=")
+		if index == -1 {
+			return nil
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(entry[index+1:]))
+		if err != nil {
+			return nil
+		}
+		move, err := s.ParseSAN(entry[:index])
+		if err != nil {
+			return nil
+		}
+		points[move] = value
+	}
+	return points
+}
+
+// Blank lines and lines starting with # are skipped
+func ReadEPDFile(path string) ([]*EPDPosition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	positions := []*EPDPosition{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		position, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		if position.id == "" {
+			position.id = fmt.Sprintf("%s:%d", path, i+1)
+		}
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+func (position *EPDPosition) isSolution(move Move) bool {
+	if len(position.bestMoves) != 0 && !slices.Contains(position.bestMoves, move) {
+		return false
+	}
+	return !slices.Contains(position.avoidMoves, move)
+}
+
+// The best possible points for the position, used as the denominator of the STS score
+func (position *EPDPosition) maxPoints() int {
+	if position.points == nil {
+		return epdBestMovePoints
+	}
+	best := 0
+	for _, value := range position.points {
+		best = max(best, value)
+	}
+	return best
+}
+
+func (position *EPDPosition) pointsFor(move Move) int {
+	if position.points == nil {
+		if position.isSolution(move) {
+			return epdBestMovePoints
+		}
+		return 0
+	}
+	return position.points[move]
+}
+
+// Searches the position from an empty table and history so results do not depend on the order
+// positions are run in
+func (position *EPDPosition) Run(limits SearchLimits) EPDResult {
+	clear(transpositionTable)
+	ClearSearchHistory()
+	lastMoveScore = startingEval
+	stopSearch.Store(false)
+	result := EPDResult{position: position, timeToSolution: -1}
+	reporter := func(report SearchReport) {
+		result.score = report.score
+		result.depth = report.depth
+		if len(report.pv) == 0 {
+			return
+		}
+		if !position.isSolution(report.pv[0]) {
+			result.timeToSolution = -1
+		} else if result.timeToSolution < 0 {
+			result.timeToSolution = report.elapsed
+		}
+	}
+	result.move = position.state.Search(limits, false, reporter)
+	result.nodes = totalNodesSearched()
+	result.solved = position.isSolution(result.move)
+	result.points = position.pointsFor(result.move)
+	if !result.solved {
+		result.timeToSolution = -1
+	}
+	return result
+}
+
+func (position *EPDPosition) expected() string {
+	expected := []string{}
+	for _, move := range position.bestMoves {
+		expected = append(expected, position.state.SAN(move))
+	}
+	for _, move := range position.avoidMoves {
+		expected = append(expected, "not "+position.state.SAN(move))
+	}
+	return strings.Join(expected, " ")
+}
+
+// Parses the command line of "ghobos epd" and runs the suites
+func EPDCommand(args []string) error {
+	settings, err := parseEPDFlags(args)
+	if err != nil {
+		return err
+	}
+	return RunEPD(settings)
+}
+
+func parseEPDFlags(args []string) (*EPDSettings, error) {
+	flags := flag.NewFlagSet("epd", flag.ContinueOnError)
+	moveTime := flags.Duration("time", time.Second, "time per position, 0 for no limit")
+	depth := flags.Int("depth", 0, "depth per position, 0 for no limit")
+	nodes := flags.Uint64("nodes", 0, "nodes per position, 0 for no limit")
+	hashSize := flags.Uint64("hash", 64, "transposition table size in megabytes")
+	threads := flags.Int("threads", DefaultSearchThreads, "search threads")
+	verbose := flags.Bool("v", false, "print every position instead of only the failed ones")
+	evalParamsPath := flags.String("evalparams", "", "JSON file with the weights of the handcrafted evaluation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ghobos epd [flags] file.epd...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() == 0 {
+		return nil, errors.New("no EPD files given")
+	}
+	if *hashSize < MinHashSize || *hashSize > MaxHashSize {
+		return nil, fmt.Errorf("hash size must be between %d and %d", MinHashSize, MaxHashSize)
+	}
+	if err := SetEvalParamsFile(*evalParamsPath); err != nil {
+		return nil, err
+	}
+	return &EPDSettings{
+		paths:    flags.Args(),
+		limits:   SearchLimits{maxTime: *moveTime, maxDepth: int32(*depth), maxNodes: *nodes},
+		hashSize: *hashSize,
+		threads:  *threads,
+		verbose:  *verbose,
+	}, nil
+}
+
+func RunEPD(settings *EPDSettings) error {
+	ResizeTable(settings.hashSize)
+	SetSearchThreads(settings.threads)
+	for _, path := range settings.paths {
+		positions, err := ReadEPDFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d positions\n", path, len(positions))
+		solved, points, maxPoints := 0, 0, 0
+		totalTime := time.Duration(0)
+		totalNodes := uint64(0)
+		start := time.Now()
+		for _, position := range positions {
+			result := position.Run(settings.limits)
+			totalNodes += result.nodes
+			points += result.points
+			maxPoints += position.maxPoints()
+			if result.solved {
+				solved++
+				totalTime += result.timeToSolution
+			}
+			if settings.verbose || !result.solved {
+				status := "failed"
+				if result.solved {
+					status = fmt.Sprintf("solved in %v", result.timeToSolution.Round(time.Millisecond))
+				}
+				fmt.Printf("%s: %s, played %s expected %s, depth %d score %s\n", position.id, status, position.state.SAN(result.move), position.expected(),
+					result.depth, uciScore(result.score))
+			}
+		}
+		averageTime := time.Duration(0)
+		if solved > 0 {
+			averageTime = totalTime / time.Duration(solved)
+		}
+		fmt.Printf("%s: solved %d/%d (%.1f%%), average time to solution %v, score %d/%d (%.1f%%), %d nodes in %v\n", path, solved, len(positions),
+			100*float64(solved)/float64(max(len(positions), 1)), averageTime.Round(time.Millisecond), points, maxPoints,
+			100*float64(points)/float64(max(maxPoints, 1)), totalNodes, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	tests := []struct {
+		line   string
+		id     string
+		bm     []string
+		am     []string
+		points map[string]int // nil when c0 holds no scores
+	}{
+		{`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`, "WAC.001", []string{"g3g6"}, nil, nil},
+		{`8/7p/5k2/5p2/p1p2P2/Pr1pPK2/1P1R3P/8 b - - bm Rxb2; id "WAC.002";`, "WAC.002", []string{"b3b2"}, nil, nil},
+		{`1kr5/3n4/q3p2p/p2n2p1/PppB1P2/5BP1/1P2Q2P/3R2K1 w - - bm f5; id "Undermine.001"; c0 "f5=10, Be5+=2, Bf2=3, Bg4=2";`,
+			"Undermine.001", []string{"f4f5"}, nil, map[string]int{"f4f5": 10, "d4e5": 2, "d4f2": 3, "f3g4": 2}},
+		{`8/4P1k1/8/8/8/8/8/4K3 w - - bm e8=Q; c0 "e8=Q=10, e8=N=3";`, "", []string{"e7e8q"}, nil, map[string]int{"e7e8q": 10, "e7e8n": 3}},
+		{`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - am f3 g4; id "two  spaces; and a semicolon";`,
+			"two  spaces; and a semicolon", nil, []string{"f2f3", "g2g4"}, nil},
+		{`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - bm e2e4 d4; c0 "f5=10, a free comment";`, "", []string{"e2e4", "d2d4"}, nil, nil},
+	}
+	for _, test := range tests {
+		position, err := ParseEPD(test.line)
+		if err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		if position.id != test.id {
+			t.Errorf("%s has id %q, want %q", test.line, position.id, test.id)
+		}
+		moveStrings := func(moves []Move) []string {
+			shortStrings := []string{}
+			for _, move := range moves {
+				shortStrings = append(shortStrings, move.ShortString())
+			}
+			return shortStrings
+		}
+		if bm, am := moveStrings(position.bestMoves), moveStrings(position.avoidMoves); strings.Join(bm, " ") != strings.Join(test.bm, " ") ||
+			strings.Join(am, " ") != strings.Join(test.am, " ") {
+			t.Errorf("%s has bm %v and am %v, want %v and %v", test.line, bm, am, test.bm, test.am)
+		}
+		if (position.points == nil) != (test.points == nil) || len(position.points) != len(test.points) {
+			t.Errorf("%s has points %v, want %v", test.line, position.points, test.points)
+			continue
+		}
+		for move, value := range position.points {
+			if test.points[move.ShortString()] != value {
+				t.Errorf("%s gives %s %d points, want %d", test.line, move.ShortString(), value, test.points[move.ShortString()])
+			}
+		}
+	}
+}
+
+func TestParseEPDErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{"8/8/8/8 w", "expected at least 4 fields"},
+		{`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001;`, "unterminated string operand"},
+		{`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6`, `operation "bm Qg6" is missing its ";"`},
+		{`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - id "WAC.001";`, "no bm or am operation"},
+		{`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Ke3;`, "illegal move"},
+	}
+	for _, test := range tests {
+		if _, err := ParseEPD(test.line); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s gave error %v, want one containing %q", test.line, err, test.err)
+		}
+	}
+}
@@ -1,33 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 const (
-	CentiPawn   int32 = 65536
-	PawnValue   int32 = CentiPawn * 100
-	BishopValue int32 = CentiPawn * 325
-	KnightValue int32 = CentiPawn * 300
-	RookValue   int32 = CentiPawn * 500
-	QueenValue  int32 = CentiPawn * 900
-	KingValue   int32 = CentiPawn * 1000
+	CentiPawn int32 = 65536
 
-	DoublePawnValue   int32 = CentiPawn * -25
-	PassedPawnValue   int32 = CentiPawn * 50
-	IsolatedPawnValue int32 = CentiPawn * -25
-
-	OpenFileRookValue int32 = CentiPawn * 10
-
-	MobilityValue int32 = CentiPawn * 1
-
-	PawnKingAttackValue   int32 = 1
-	KnightKingAttackValue int32 = 2
-	BishopKingAttackValue int32 = 2
-	RookKingAttackValue   int32 = 3
-	QueenKingAttackValue  int32 = 5
-	KingKingAttackValue   int32 = 1
+	MaxEvalParam = 10000 // Limit for weights set as options
 
 	PawnPhaseValue   int8 = 0
 	BishopPhaseValue int8 = 1
@@ -38,46 +25,155 @@ const (
 	TotalPhaseValue  int8 = PawnPhaseValue*16 + BishopPhaseValue*4 + KnightPhaseValue*4 + RookPhaseValue*4 + QueenPhaseValue*2 + KingPhaseValue*2
 )
 
-var valueTable [6]int32 = [6]int32{KingValue, QueenValue, RookValue, BishopValue, KnightValue, PawnValue}
+// Weights in centipawns for one phase of the game. Tables are indexed like the boards of a State
+// and piece square tables are from white's point of view
+type PhaseWeights struct {
+	PieceValues  [6]int32
+	DoubledPawn  int32
+	IsolatedPawn int32
+	OpenFileRook int32
+	Mobility     int32
+	KingSafety   [60]int32 // Indexed by the attack weights summed over every attacker
+	PieceSquare  [6][64]int32
+
+	// Passed pawn tables are indexed by the rank counted from the pawn's own side
+	PassedPawn                  [8]int32
+	PassedPawnFreePath          [8]int32 // No piece stands between the pawn and its promotion square
+	PassedPawnBlocked           int32    // An enemy piece stands right in front of the pawn
+	PassedPawnSupported         int32    // Defended by a friendly pawn
+	PassedPawnConnected         int32    // Another passed pawn on a neighboring file next to it
+	PassedPawnKingDistance      int32    // Per square from the friendly king to the promotion square and rank advanced
+	PassedPawnEnemyKingDistance int32    // Same for the enemy king
+	PassedPawnUnstoppable       int32    // In pawn endgames when the enemy king is outside the square of the pawn
+}
+
+// Every weight has a midgame and an endgame value which are blended by the game phase. The king
+// attack weights count attacks on the squares around the enemy king and are the same in both. The
+// defaults are in eval_params.go
+type EvalParams struct {
+	Midgame           PhaseWeights
+	Endgame           PhaseWeights
+	KingAttackWeights [6]int32
+}
+
+var pieceNames [6]string = [6]string{"King", "Queen", "Rook", "Bishop", "Knight", "Pawn"}
+
+// A named group of weights that the tuner changes one value at a time
+type EvalTerm struct {
+	name   string
+	values []*int32
+}
+
+// A midgame and an endgame value packed into one number, so both are summed with a single addition
+// and the evaluation only has to blend them once. The endgame value is in the upper half
+type Score int64
+
+func S(midgame int32, endgame int32) Score {
+	return Score(int64(endgame)<<32 + int64(midgame))
+}
+
+func (score Score) midgame() int32 {
+	return int32(score)
+}
+
+// A negative midgame value borrows one from the upper half, adding half of the lower range undoes it
+func (score Score) endgame() int32 {
+	return int32((int64(score) + 1<<31) >> 32)
+}
+
+// Phase is out of TotalPhaseValue, which is a full midgame. The products can overflow 32 bits once
+// the values are scaled by CentiPawn
+func (score Score) taper(phase int8) int32 {
+	return int32((int64(score.midgame())*int64(phase) + int64(score.endgame())*int64(TotalPhaseValue-phase)) / int64(TotalPhaseValue))
+}
+
+var evalParams EvalParams = defaultEvalParams
 
-var midgamePieceSquareTable [12][64]int32
-var endgamePieceSquareTable [12][64]int32
+// Everything below is derived from evalParams by setupEvalValues
+var valueTable [6]int32 // Midgame piece values for ordering captures
 
-var kingSafetyTable [60]uint16
-var gamePhaseValues [6]int8 = [6]int8{KingPhaseValue, QueenPhaseValue, RookPhaseValue, BishopPhaseValue, KingPhaseValue, PawnPhaseValue}
+var pieceValueScores [6]Score
+var DoublePawnValue Score
+var IsolatedPawnValue Score
+var OpenFileRookValue Score
+var MobilityValue Score
+
+var passedPawnValues [8]Score
+var passedPawnFreePathValues [8]Score
+var passedPawnBlockedValue Score
+var passedPawnSupportedValue Score
+var passedPawnConnectedValue Score
+var passedPawnKingDistanceValue Score
+var passedPawnEnemyKingDistanceValue Score
+var passedPawnUnstoppableValue Score
+
+var pieceSquareTable [12][64]Score
+
+var kingAttackWeights [6]int32
+var kingSafetyTable [60]Score
+var gamePhaseValues [6]int8 = [6]int8{KingPhaseValue, QueenPhaseValue, RookPhaseValue, BishopPhaseValue, KnightPhaseValue, PawnPhaseValue}
+
+// The squares in front of a pawn on its own file
+var pawnFrontSpans [2][64]Bitboard
+
+// The squares in front of a pawn on its own and the neighboring files, a pawn is passed if no enemy
+// pawn is on them
+var passedPawnMasks [2][64]Bitboard
 
 func InitializeEvalVariables() {
+	setupPawnMasks()
 	setupEvalValues()
 }
 
+func setupPawnMasks() {
+	for square := Square(0); square < 64; square++ {
+		for rank := square.Rank() + 1; rank < 8; rank++ {
+			pawnFrontSpans[White][square] |= files[square.File()] & ranks[rank]
+		}
+		for rank := int8(0); rank < square.Rank(); rank++ {
+			pawnFrontSpans[Black][square] |= files[square.File()] & ranks[rank]
+		}
+		for side := White; side <= Black; side++ {
+			span := pawnFrontSpans[side][square]
+			passedPawnMasks[side][square] = span | (span&^File7)<<1 | (span&^File0)>>1
+		}
+	}
+}
+
 func (s *State) EvalState(perspective uint8) int32 {
-	var eval int32 = 0
+	if nnueEnabled() {
+		return s.nnueEval(perspective)
+	}
+	return s.handcraftedEval(perspective, nil)
+}
+
+// Every term is also added to the trace unless it is nil
+func (s *State) handcraftedEval(perspective uint8, trace *EvalTrace) int32 {
+	var eval Score = 0
 	var phaseValue int8 = 0
-	var mgPieceSquareTableEval int32 = 0
-	var egPieceSquareTableEval int32 = 0
 	for i := 0; i < 6; i++ {
 		whiteCount := BitCount(s.board[i])
 		blackCount := BitCount(s.board[6+i])
-		eval += int32(whiteCount) * valueTable[i]
-		eval -= int32(blackCount) * valueTable[i]
+		eval += Score(whiteCount) * pieceValueScores[i]
+		eval -= Score(blackCount) * pieceValueScores[i]
+		trace.add(traceMaterial, White, Score(whiteCount)*pieceValueScores[i])
+		trace.add(traceMaterial, Black, Score(blackCount)*pieceValueScores[i])
 		phaseValue += int8(whiteCount+blackCount) * gamePhaseValues[i]
 		whiteBoard := s.board[i]
 		for whiteBoard != 0 {
 			square := PopLSB(&whiteBoard)
-			mgPieceSquareTableEval += midgamePieceSquareTable[i][square]
-			egPieceSquareTableEval += endgamePieceSquareTable[i][square]
+			eval += pieceSquareTable[i][square]
+			trace.add(tracePieceSquare, White, pieceSquareTable[i][square])
 		}
 		blackBoard := s.board[i+6]
 		for blackBoard != 0 {
 			square := PopLSB(&blackBoard)
-			mgPieceSquareTableEval -= midgamePieceSquareTable[i+6][square]
-			egPieceSquareTableEval -= endgamePieceSquareTable[i+6][square]
+			eval -= pieceSquareTable[i+6][square]
+			trace.add(tracePieceSquare, Black, pieceSquareTable[i+6][square])
 		}
 	}
-	// Adjust piece square values based off of phase of the game
 	mgPhaseValue := min(phaseValue, TotalPhaseValue)
-	egPhaseValue := TotalPhaseValue - mgPhaseValue
-	eval += (mgPieceSquareTableEval*int32(mgPhaseValue) + egPieceSquareTableEval*int32(egPhaseValue)) / int32(TotalPhaseValue)
+	trace.setPhase(mgPhaseValue)
 
 	// King Safety and Piece Mobility. Allows pieces to "move" to square occupied by friendly pieces because defending a friendly piece is still beneficial
 	for side := uint8(0); side < 2; side++ {
@@ -94,7 +190,7 @@ func (s *State) EvalState(perspective uint8) int32 {
 			safeSquares := s.getPinBoard(bishopSquare, friendKingSquare, side)
 			bishopMoves := getBishopMoves(bishopSquare, s.occupied) & safeSquares
 			mobilityCount += int32(BitCount(bishopMoves))
-			kingAttackerPoints += int32(BitCount(bishopMoves&enemyKingNeighbors)) * BishopKingAttackValue
+			kingAttackerPoints += int32(BitCount(bishopMoves&enemyKingNeighbors)) * kingAttackWeights[Bishop]
 		}
 		knightBoard := s.board[friendIndex+Knight]
 		for knightBoard != 0 {
@@ -102,7 +198,7 @@ func (s *State) EvalState(perspective uint8) int32 {
 			safeSquares := s.getPinBoard(knightSquare, friendKingSquare, side)
 			knightMoves := moveBoards[Knight][knightSquare] & safeSquares
 			mobilityCount += int32(BitCount(knightMoves))
-			kingAttackerPoints += int32(BitCount(knightMoves&enemyKingNeighbors)) * KnightKingAttackValue
+			kingAttackerPoints += int32(BitCount(knightMoves&enemyKingNeighbors)) * kingAttackWeights[Knight]
 		}
 		rookBoard := s.board[friendIndex+Rook]
 		for rookBoard != 0 {
@@ -110,7 +206,7 @@ func (s *State) EvalState(perspective uint8) int32 {
 			safeSquares := s.getPinBoard(rookSquare, friendKingSquare, side)
 			rookMoves := getRookMoves(rookSquare, s.occupied) & safeSquares
 			mobilityCount += int32(BitCount(rookMoves))
-			kingAttackerPoints += int32(BitCount(rookMoves&enemyKingNeighbors)) * RookKingAttackValue
+			kingAttackerPoints += int32(BitCount(rookMoves&enemyKingNeighbors)) * kingAttackWeights[Rook]
 		}
 		queenBoard := s.board[friendIndex+Queen]
 		for queenBoard != 0 {
@@ -118,7 +214,7 @@ func (s *State) EvalState(perspective uint8) int32 {
 			safeSquares := s.getPinBoard(queenSquare, friendKingSquare, side)
 			queenMoves := getQueenMoves(queenSquare, s.occupied) & safeSquares
 			mobilityCount += int32(BitCount(queenMoves))
-			kingAttackerPoints += int32(BitCount(queenMoves&enemyKingNeighbors)) * QueenKingAttackValue
+			kingAttackerPoints += int32(BitCount(queenMoves&enemyKingNeighbors)) * kingAttackWeights[Queen]
 		}
 		pawnBoard := s.board[friendIndex+Pawn]
 		moveStep := -Step(16*side - 8) // Turns 0 into 8 for upstep and 1 int -8 for down step
@@ -129,31 +225,31 @@ func (s *State) EvalState(perspective uint8) int32 {
 			pawnMoves := GetPawnMoves(pawnSquare, s.occupied, moveStep, homeRank) & safeSquare
 			pawnAttacks := pawnAttackBoards[side][pawnSquare] & s.sideOccupied[1-side]
 			mobilityCount += int32(BitCount(pawnMoves | pawnAttacks))
-			kingAttackerPoints += int32(BitCount(pawnAttacks&enemyKingNeighbors)) * PawnKingAttackValue
+			kingAttackerPoints += int32(BitCount(pawnAttacks&enemyKingNeighbors)) * kingAttackWeights[Pawn]
 		}
 		kingBoard := s.board[friendIndex+King]
-		for knightBoard != 0 {
+		for kingBoard != 0 {
 			kingSquare := PopLSB(&kingBoard)
 			kingMoves := moveBoards[King][kingSquare]
 			mobilityCount += int32(BitCount(kingMoves))
-			kingAttackerPoints += int32(BitCount(kingMoves&enemyKingNeighbors)) * KingKingAttackValue
+			kingAttackerPoints += int32(BitCount(kingMoves&enemyKingNeighbors)) * kingAttackWeights[King]
 		}
+		trace.add(traceMobility, side, Score(mobilityCount)*MobilityValue)
+		trace.add(traceKingSafety, side, getKingSafetyValue(kingAttackerPoints))
 		if side == White {
-			eval += mobilityCount * MobilityValue
+			eval += Score(mobilityCount) * MobilityValue
 			eval += getKingSafetyValue(kingAttackerPoints)
 		} else {
-			eval -= mobilityCount * MobilityValue
+			eval -= Score(mobilityCount) * MobilityValue
 			eval -= getKingSafetyValue(kingAttackerPoints)
 		}
 	}
 
 	// Pawn Eval
 	whitePawns := s.board[WhitePawn]
-	shallowestWhiteNeighbor := [8]int8{7, 7, 7, 7, 7, 7, 7, 7}
 	neighboringWhitePawns := [8]int8{}
 	whiteFileCount := [8]uint8{}
 	blackPawns := s.board[BlackPawn]
-	shallowestBlackNeighbor := [8]int8{0, 0, 0, 0, 0, 0, 0, 0}
 	neighboringBlackPawns := [8]int8{}
 	blackFileCount := [8]uint8{}
 	openFiles := [8]bool{true, true, true, true, true, true, true, true}
@@ -162,21 +258,11 @@ func (s *State) EvalState(perspective uint8) int32 {
 		file := square.File()
 		whiteFileCount[file]++
 		openFiles[file] = false
-		rank := square.Rank()
-		if rank < shallowestWhiteNeighbor[file] {
-			shallowestWhiteNeighbor[file] = rank
-		}
 		if file != 0 {
 			neighboringWhitePawns[file-1]++
-			if rank < shallowestWhiteNeighbor[file-1] {
-				shallowestWhiteNeighbor[file-1] = rank
-			}
 		}
 		if file != 7 {
 			neighboringWhitePawns[file+1]++
-			if rank < shallowestWhiteNeighbor[file+1] {
-				shallowestWhiteNeighbor[file+1] = rank
-			}
 		}
 	}
 	for blackPawns != 0 {
@@ -184,65 +270,45 @@ func (s *State) EvalState(perspective uint8) int32 {
 		file := square.File()
 		blackFileCount[file]++
 		openFiles[file] = false
-		rank := square.Rank()
-		if rank > shallowestBlackNeighbor[file] {
-			shallowestBlackNeighbor[file] = rank
-		}
 		if file != 0 {
 			neighboringBlackPawns[file-1]++
-			if rank > shallowestBlackNeighbor[file-1] {
-				shallowestBlackNeighbor[file-1] = rank
-			}
 		}
 		if file != 7 {
 			neighboringBlackPawns[file+1]++
-			if rank > shallowestBlackNeighbor[file+1] {
-				shallowestBlackNeighbor[file+1] = rank
-			}
 		}
 	}
 	whitePawns = s.board[WhitePawn]
-	whiteDoubled := 0
-	whiteIsolated := 0
-	whitePassed := 0
-	blackDoubled := 0
-	blackIsolated := 0
-	blackPassed := 0
 	blackPawns = s.board[BlackPawn]
 	for whitePawns != 0 {
 		square := PopLSB(&whitePawns)
 		file := square.File()
-		rank := square.Rank()
 		if whiteFileCount[file] > 1 {
 			eval += DoublePawnValue
-			whiteDoubled++
+			trace.add(traceDoubledPawns, White, DoublePawnValue)
 		}
 		if neighboringWhitePawns[file] == 0 {
 			eval += IsolatedPawnValue
-			whiteIsolated++
-		}
-		if (neighboringBlackPawns[file] == 0 && blackFileCount[file] == 0) || rank >= shallowestBlackNeighbor[file] {
-			eval += DoublePawnValue
-			whitePassed++
+			trace.add(traceIsolatedPawns, White, IsolatedPawnValue)
 		}
 	}
 	for blackPawns != 0 {
 		square := PopLSB(&blackPawns)
 		file := square.File()
-		rank := square.Rank()
 		if blackFileCount[file] > 1 {
 			eval -= DoublePawnValue
-			blackDoubled++
+			trace.add(traceDoubledPawns, Black, DoublePawnValue)
 		}
 		if neighboringBlackPawns[file] == 0 {
 			eval -= IsolatedPawnValue
-			blackIsolated++
-		}
-		if (neighboringWhitePawns[file] == 0 && whiteFileCount[file] == 0) || rank <= shallowestWhiteNeighbor[file] {
-			eval -= DoublePawnValue
-			blackPassed++
+			trace.add(traceIsolatedPawns, Black, IsolatedPawnValue)
 		}
 	}
+	pawnEndgame := s.occupied == s.board[WhiteKing]|s.board[BlackKing]|s.board[WhitePawn]|s.board[BlackPawn]
+	whitePassedEval := s.passedPawnEval(White, pawnEndgame)
+	blackPassedEval := s.passedPawnEval(Black, pawnEndgame)
+	eval += whitePassedEval - blackPassedEval
+	trace.add(tracePassedPawns, White, whitePassedEval)
+	trace.add(tracePassedPawns, Black, blackPassedEval)
 
 	// Open File Rook Bonus
 	whiteRooks := s.board[WhiteRook]
@@ -252,6 +318,7 @@ func (s *State) EvalState(perspective uint8) int32 {
 		file := square.File()
 		if openFiles[file] {
 			eval += OpenFileRookValue
+			trace.add(traceOpenFileRooks, White, OpenFileRookValue)
 		}
 	}
 	for blackRooks != 0 {
@@ -259,12 +326,95 @@ func (s *State) EvalState(perspective uint8) int32 {
 		file := square.File()
 		if openFiles[file] {
 			eval -= OpenFileRookValue
+			trace.add(traceOpenFileRooks, Black, OpenFileRookValue)
 		}
 	}
+	// Every term is blended by the phase of the game at once
 	if perspective == White {
-		return eval
+		return eval.taper(mgPhaseValue)
+	}
+	return -eval.taper(mgPhaseValue)
+}
+
+// Passed pawns have no enemy pawns in front of them on their own or the neighboring files, and no
+// friendly pawn in front of them on their own file
+func (s *State) passedPawns(side uint8) Bitboard {
+	friendPawns := s.board[side*6+Pawn]
+	enemyPawns := s.board[(1-side)*6+Pawn]
+	passedPawns := EmptyBitboard
+	pawns := friendPawns
+	for pawns != 0 {
+		square := PopLSB(&pawns)
+		if passedPawnMasks[side][square]&enemyPawns == 0 && pawnFrontSpans[side][square]&friendPawns == 0 {
+			passedPawns |= boardFromSquare(square)
+		}
+	}
+	return passedPawns
+}
+
+func (s *State) passedPawnEval(side uint8, pawnEndgame bool) Score {
+	friendIndex := side * 6
+	enemyIndex := (1 - side) * 6
+	friendPawns := s.board[friendIndex+Pawn]
+	passedPawns := s.passedPawns(side)
+	friendKingSquare := GetLSB(s.board[friendIndex+King])
+	enemyKingSquare := GetLSB(s.board[enemyIndex+King])
+	eval := Score(0)
+	pawns := passedPawns
+	for pawns != 0 {
+		square := PopLSB(&pawns)
+		rank := relativeRank(square, side)
+		promotionSquare := sFromRankFile(int(square.File()), int(7-7*side))
+		stopSquare := square.Step(-Step(16*int8(side) - 8))
+		path := pawnFrontSpans[side][square]
+		eval += passedPawnValues[rank]
+		if path&s.occupied == 0 {
+			eval += passedPawnFreePathValues[rank]
+		} else if boardFromSquare(stopSquare)&s.sideOccupied[1-side] != 0 {
+			eval += passedPawnBlockedValue
+		}
+		if pawnAttackBoards[1-side][square]&friendPawns != 0 {
+			eval += passedPawnSupportedValue
+		}
+		if moveBoards[King][square]&passedPawns&^files[square.File()] != 0 {
+			eval += passedPawnConnectedValue
+		}
+		// Kings matter more the closer the pawn is to promoting
+		advanced := Score(rank - 1)
+		eval += advanced * Score(friendKingSquare.Distance(promotionSquare)) * passedPawnKingDistanceValue
+		eval += advanced * Score(enemyKingSquare.Distance(promotionSquare)) * passedPawnEnemyKingDistanceValue
+		if pawnEndgame && s.outsideSquare(square, side) {
+			eval += passedPawnUnstoppableValue
+		}
+	}
+	return eval
+}
+
+// Counted from the side's own first rank
+func relativeRank(square Square, side uint8) int8 {
+	if side == Black {
+		return 7 - square.Rank()
+	}
+	return square.Rank()
+}
+
+// Rule of the square, the enemy king can not catch a passed pawn with a free path if it is further
+// from the promotion square than the pawn is, counting its move first if it is the one to move
+func (s *State) outsideSquare(square Square, side uint8) bool {
+	if pawnFrontSpans[side][square]&s.occupied != 0 {
+		return false
+	}
+	rank := relativeRank(square, side)
+	pawnDistance := 7 - rank
+	if rank == 1 {
+		pawnDistance-- // Double push
+	}
+	promotionSquare := sFromRankFile(int(square.File()), int(7-7*side))
+	kingDistance := GetLSB(s.board[(1-side)*6+King]).Distance(promotionSquare)
+	if s.turn != side {
+		kingDistance--
 	}
-	return -eval
+	return pawnDistance < kingDistance
 }
 
 func (s *State) NormalizedEval(perspective uint8) float64 {
@@ -303,144 +453,174 @@ func EvalLowToHigh(eval int16) int32 {
 }
 
 func setupEvalValues() {
-	for i := 0; i < len(kingSafetyTable); i++ {
-		kingSafetyTable[i] = kingSafetyFunction(float64(i))
-	}
-	midgamePieceSquareTable[WhiteKing] = [64]int32{
-		20, 30, 10, 0, 0, 10, 30, 20,
-		20, 20, 0, 0, 0, 0, 20, 20,
-		-10, -20, -20, -20, -20, -20, -20, -10,
-		-20, -30, -30, -40, -40, -30, -30, -20,
-		-30, -40, -40, -50, -50, -40, -40, -30,
-		-30, -40, -40, -50, -50, -40, -40, -30,
-		-30, -40, -40, -50, -50, -40, -40, -30,
-		-30, -40, -40, -50, -50, -40, -40, -30}
-	endgamePieceSquareTable[WhiteKing] = [64]int32{
-		-50, -30, -30, -30, -30, -30, -30, -50,
-		-30, -30, 0, 0, 0, 0, -30, -30,
-		-30, -10, 20, 30, 30, 20, -10, -30,
-		-30, -10, 30, 40, 40, 30, -10, -30,
-		-30, -10, 30, 40, 40, 30, -10, -30,
-		-30, -10, 20, 30, 30, 20, -10, -30,
-		-30, -20, -10, 0, 0, -10, -20, -30,
-		-50, -40, -30, -20, -20, -30, -40, -50}
-	midgamePieceSquareTable[WhiteQueen] = [64]int32{
-		-20, -10, -10, -5, -5, -10, -10, -20,
-		-10, 0, 5, 0, 0, 0, 0, -10,
-		-10, 5, 5, 5, 5, 5, 0, -10,
-		0, 0, 5, 5, 5, 5, 0, -5,
-		-5, 0, 5, 5, 5, 5, 0, -5,
-		-10, 0, 5, 5, 5, 5, 0, -10,
-		-10, 0, 0, 0, 0, 0, 0, -10,
-		-20, -10, -10, -5, -5, -10, -10, -20}
-	endgamePieceSquareTable[WhiteQueen] = [64]int32{
-		-20, -10, -10, -5, -5, -10, -10, -20,
-		-10, 0, 5, 0, 0, 0, 0, -10,
-		-10, 5, 5, 5, 5, 5, 0, -10,
-		0, 0, 5, 5, 5, 5, 0, -5,
-		-5, 0, 5, 5, 5, 5, 0, -5,
-		-10, 0, 5, 5, 5, 5, 0, -10,
-		-10, 0, 0, 0, 0, 0, 0, -10,
-		-20, -10, -10, -5, -5, -10, -10, -20}
-	midgamePieceSquareTable[WhiteRook] = [64]int32{
-		0, 0, 0, 5, 5, 0, 0, 0,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		5, 10, 10, 10, 10, 10, 10, 5,
-		0, 0, 0, 0, 0, 0, 0, 0}
-	endgamePieceSquareTable[WhiteRook] = [64]int32{
-		0, 0, 0, 5, 5, 0, 0, 0,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		-5, 0, 0, 0, 0, 0, 0, -5,
-		5, 10, 10, 10, 10, 10, 10, 5,
-		0, 0, 0, 0, 0, 0, 0, 0}
-	midgamePieceSquareTable[WhiteBishop] = [64]int32{
-		-20, -10, -10, -10, -10, -10, -10, -20,
-		-10, 5, 0, 0, 0, 0, 5, -10,
-		-10, 10, 10, 10, 10, 10, 10, -10,
-		-10, 0, 10, 10, 10, 10, 0, -10,
-		-10, 5, 5, 10, 10, 5, 5, -10,
-		-10, 0, 5, 10, 10, 5, 0, -10,
-		-10, 0, 0, 0, 0, 0, 0, -10,
-		-20, -10, -10, -10, -10, -10, -10, -20}
-	endgamePieceSquareTable[WhiteBishop] = [64]int32{
-		-20, -10, -10, -10, -10, -10, -10, -20,
-		-10, 5, 0, 0, 0, 0, 5, -10,
-		-10, 10, 10, 10, 10, 10, 10, -10,
-		-10, 0, 10, 10, 10, 10, 0, -10,
-		-10, 5, 5, 10, 10, 5, 5, -10,
-		-10, 0, 5, 10, 10, 5, 0, -10,
-		-10, 0, 0, 0, 0, 0, 0, -10,
-		-20, -10, -10, -10, -10, -10, -10, -20}
-	midgamePieceSquareTable[WhiteKnight] = [64]int32{
-		-50, -40, -30, -30, -30, -30, -40, -50,
-		-40, -20, 0, 5, 5, 0, -20, -40,
-		-30, 5, 10, 15, 15, 10, 5, -30,
-		-30, 0, 15, 20, 20, 15, 0, -30,
-		-30, 5, 15, 20, 20, 15, 5, -30,
-		-30, 0, 10, 15, 15, 10, 0, -30,
-		-40, -20, 0, 0, 0, 0, -20, -40,
-		-50, -40, -30, -30, -30, -30, -40, -50}
-	endgamePieceSquareTable[WhiteKnight] = [64]int32{
-		-50, -40, -30, -30, -30, -30, -40, -50,
-		-40, -20, 0, 5, 5, 0, -20, -40,
-		-30, 5, 10, 15, 15, 10, 5, -30,
-		-30, 0, 15, 20, 20, 15, 0, -30,
-		-30, 5, 15, 20, 20, 15, 5, -30,
-		-30, 0, 10, 15, 15, 10, 0, -30,
-		-40, -20, 0, 0, 0, 0, -20, -40,
-		-50, -40, -30, -30, -30, -30, -40, -50}
-	midgamePieceSquareTable[WhitePawn] = [64]int32{
-		0, 0, 0, 0, 0, 0, 0, 0,
-		5, 10, 10, -20, -20, 10, 10, 5,
-		5, -5, -10, 0, 0, -10, -5, 5,
-		0, 0, 0, 20, 20, 0, 0, 0,
-		5, 5, 10, 25, 25, 10, 5, 5,
-		10, 10, 20, 30, 30, 20, 10, 10,
-		50, 50, 50, 50, 50, 50, 50, 50,
-		0, 0, 0, 0, 0, 0, 0, 0}
-	endgamePieceSquareTable[WhitePawn] = [64]int32{
-		0, 0, 0, 0, 0, 0, 0, 0,
-		-30, -30, -30, -30, -30, -30, -30, -30,
-		-10, -10, -10, -10, -10, -10, -10, -10,
-		0, 0, 0, 0, 0, 0, 0, 0,
-		20, 20, 20, 20, 20, 20, 20, 20,
-		40, 40, 40, 40, 40, 40, 40, 40,
-		60, 60, 60, 60, 60, 60, 60, 60,
-		0, 0, 0, 0, 0, 0, 0, 0}
-	//Normalize tables to centipawn value
-	for i := 0; i < 6; i++ {
-		for s := 0; s < 64; s++ {
-			midgamePieceSquareTable[i][s] *= CentiPawn
-			endgamePieceSquareTable[i][s] *= CentiPawn
-		}
+	mg, eg := &evalParams.Midgame, &evalParams.Endgame
+	score := func(midgame int32, endgame int32) Score {
+		return S(midgame*CentiPawn, endgame*CentiPawn)
+	}
+	for i := range valueTable {
+		valueTable[i] = mg.PieceValues[i] * CentiPawn
+		pieceValueScores[i] = score(mg.PieceValues[i], eg.PieceValues[i])
+	}
+	DoublePawnValue = score(mg.DoubledPawn, eg.DoubledPawn)
+	IsolatedPawnValue = score(mg.IsolatedPawn, eg.IsolatedPawn)
+	OpenFileRookValue = score(mg.OpenFileRook, eg.OpenFileRook)
+	MobilityValue = score(mg.Mobility, eg.Mobility)
+	for rank := range passedPawnValues {
+		passedPawnValues[rank] = score(mg.PassedPawn[rank], eg.PassedPawn[rank])
+		passedPawnFreePathValues[rank] = score(mg.PassedPawnFreePath[rank], eg.PassedPawnFreePath[rank])
+	}
+	passedPawnBlockedValue = score(mg.PassedPawnBlocked, eg.PassedPawnBlocked)
+	passedPawnSupportedValue = score(mg.PassedPawnSupported, eg.PassedPawnSupported)
+	passedPawnConnectedValue = score(mg.PassedPawnConnected, eg.PassedPawnConnected)
+	passedPawnKingDistanceValue = score(mg.PassedPawnKingDistance, eg.PassedPawnKingDistance)
+	passedPawnEnemyKingDistanceValue = score(mg.PassedPawnEnemyKingDistance, eg.PassedPawnEnemyKingDistance)
+	passedPawnUnstoppableValue = score(mg.PassedPawnUnstoppable, eg.PassedPawnUnstoppable)
+	kingAttackWeights = evalParams.KingAttackWeights
+	for i := range kingSafetyTable {
+		kingSafetyTable[i] = score(mg.KingSafety[i], eg.KingSafety[i])
 	}
-	//Reflect along x axis for black boards
 	for b := 0; b < 6; b++ {
 		for s := 0; s < 64; s++ {
+			pieceSquareTable[b][s] = score(mg.PieceSquare[b][s], eg.PieceSquare[b][s])
 			// xoring a square by 56 flips it over the x axis between the 4 and 5 rank
-			midgamePieceSquareTable[b+6][s^56] = midgamePieceSquareTable[b][s]
-			endgamePieceSquareTable[b+6][s^56] = endgamePieceSquareTable[b][s]
+			pieceSquareTable[b+6][s^56] = pieceSquareTable[b][s]
 		}
 	}
 }
 
-func kingSafetyFunction(x float64) uint16 {
-	const a = float64(-4e-5)
-	const b = float64(2.7e-3)
-	const c = float64(0.088)
-	const d = float64(0.70)
-	const e = float64(-0.8)
-	return uint16(math.Floor(max(0, a*math.Pow(x, 4)+b*math.Pow(x, 3)+c*math.Pow(x, 2)+d*x+e)))
+// Every weight except the king's value, which is the same for both sides, and the pawn squares and
+// passed pawn ranks on the first and last rank. Names start with the phase, eg "Endgame.PieceValues.Knight"
+func (params *EvalParams) terms() []EvalTerm {
+	pointers := func(values []int32) []*int32 {
+		result := make([]*int32, len(values))
+		for i := range values {
+			result[i] = &values[i]
+		}
+		return result
+	}
+	terms := []EvalTerm{}
+	for _, phase := range []struct {
+		name    string
+		weights *PhaseWeights
+	}{{"Midgame", &params.Midgame}, {"Endgame", &params.Endgame}} {
+		weights := phase.weights
+		for piece := Queen; piece <= Pawn; piece++ {
+			terms = append(terms, EvalTerm{phase.name + ".PieceValues." + pieceNames[piece], []*int32{&weights.PieceValues[piece]}})
+		}
+		terms = append(terms,
+			EvalTerm{phase.name + ".DoubledPawn", []*int32{&weights.DoubledPawn}},
+			EvalTerm{phase.name + ".IsolatedPawn", []*int32{&weights.IsolatedPawn}},
+			EvalTerm{phase.name + ".OpenFileRook", []*int32{&weights.OpenFileRook}},
+			EvalTerm{phase.name + ".Mobility", []*int32{&weights.Mobility}},
+			EvalTerm{phase.name + ".KingSafety", pointers(weights.KingSafety[:])},
+			EvalTerm{phase.name + ".PassedPawn", pointers(weights.PassedPawn[1:7])},
+			EvalTerm{phase.name + ".PassedPawnFreePath", pointers(weights.PassedPawnFreePath[1:7])},
+			EvalTerm{phase.name + ".PassedPawnBlocked", []*int32{&weights.PassedPawnBlocked}},
+			EvalTerm{phase.name + ".PassedPawnSupported", []*int32{&weights.PassedPawnSupported}},
+			EvalTerm{phase.name + ".PassedPawnConnected", []*int32{&weights.PassedPawnConnected}},
+			EvalTerm{phase.name + ".PassedPawnKingDistance", []*int32{&weights.PassedPawnKingDistance}},
+			EvalTerm{phase.name + ".PassedPawnEnemyKingDistance", []*int32{&weights.PassedPawnEnemyKingDistance}},
+			EvalTerm{phase.name + ".PassedPawnUnstoppable", []*int32{&weights.PassedPawnUnstoppable}},
+		)
+		for piece := King; piece <= Pawn; piece++ {
+			values := weights.PieceSquare[piece][:]
+			if piece == Pawn {
+				values = values[8:56]
+			}
+			terms = append(terms, EvalTerm{fmt.Sprintf("%s.PieceSquare.%s", phase.name, pieceNames[piece]), pointers(values)})
+		}
+	}
+	for piece := King; piece <= Pawn; piece++ {
+		terms = append(terms, EvalTerm{"KingAttackWeights." + pieceNames[piece], []*int32{&params.KingAttackWeights[piece]}})
+	}
+	return terms
+}
 
+// Terms with a single value can be set one at a time, eg as UCI options
+func (params *EvalParams) term(name string) (EvalTerm, bool) {
+	for _, term := range params.terms() {
+		if strings.EqualFold(term.name, name) && len(term.values) == 1 {
+			return term, true
+		}
+	}
+	return EvalTerm{}, false
+}
+
+// Format is "evalparams [-in params.json] [-out params.json]". Writes the default weights, or the
+// ones read from -in with any missing fields filled in, as a starting point for editing
+func EvalParamsCommand(args []string) error {
+	flags := flag.NewFlagSet("evalparams", flag.ContinueOnError)
+	inPath := flags.String("in", "", "JSON file to start from instead of the defaults")
+	outPath := flags.String("out", "eval_params.json", "JSON file to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := SetEvalParamsFile(*inPath); err != nil {
+		return err
+	}
+	if err := SaveEvalParams(*outPath, &evalParams); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", *outPath)
+	return nil
+}
+
+var jsonNumberArray *regexp.Regexp = regexp.MustCompile(`\[[-0-9,\s]*\]`)
+
+// Fields missing from the file keep their default values
+func LoadEvalParams(path string) (EvalParams, error) {
+	params := defaultEvalParams
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		return params, fmt.Errorf("%s: %w", path, err)
+	}
+	return params, nil
+}
+
+func SaveEvalParams(path string, params *EvalParams) error {
+	data, err := json.MarshalIndent(params, "", "\t")
+	if err != nil {
+		return err
+	}
+	// Arrays of numbers go on one line each, otherwise every value of a table gets its own line
+	data = jsonNumberArray.ReplaceAllFunc(data, func(array []byte) []byte {
+		numbers := bytes.Fields(bytes.ReplaceAll(array[1:len(array)-1], []byte(","), []byte(" ")))
+		return append(append([]byte("["), bytes.Join(numbers, []byte(", "))...), ']')
+	})
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Sets a term with a single value from an option, returns false if the value is invalid
+func setEvalTerm(term EvalTerm, value string) bool {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < -MaxEvalParam || parsed > MaxEvalParam {
+		return false
+	}
+	*term.values[0] = int32(parsed)
+	setupEvalValues()
+	return true
+}
+
+// An empty path goes back to the defaults
+func SetEvalParamsFile(path string) error {
+	params := defaultEvalParams
+	if path != "" {
+		var err error
+		params, err = LoadEvalParams(path)
+		if err != nil {
+			return err
+		}
+	}
+	evalParams = params
+	setupEvalValues()
+	return nil
 }
 
-func getKingSafetyValue(x int32) int32 {
-	return CentiPawn * int32(kingSafetyTable[min(x, 59)])
+func getKingSafetyValue(x int32) Score {
+	return kingSafetyTable[min(x, 59)]
 }
@@ -0,0 +1,177 @@
+// Code generated by "ghobos tune"; DO NOT EDIT.
+
+package main
+
+var defaultEvalParams EvalParams = EvalParams{
+	Midgame: PhaseWeights{
+		PieceValues:  [6]int32{1000, 900, 500, 325, 300, 100},
+		DoubledPawn:  -25,
+		IsolatedPawn: -25,
+		OpenFileRook: 10,
+		Mobility:     1,
+		KingSafety: [60]int32{
+			0, 0, 0, 2, 3, 5, 7, 9, 11, 14,
+			17, 20, 24, 27, 32, 36, 41, 46, 51, 57,
+			63, 69, 76, 83, 90, 98, 106, 114, 122, 131,
+			139, 148, 158, 167, 177, 187, 197, 207, 217, 227,
+			238, 248, 259, 269, 280, 290, 301, 311, 321, 331,
+			341, 351, 360, 369, 378, 387, 395, 402, 409, 416,
+		},
+		PieceSquare: [6][64]int32{
+			{ // King
+				20, 30, 10, 0, 0, 10, 30, 20,
+				20, 20, 0, 0, 0, 0, 20, 20,
+				-10, -20, -20, -20, -20, -20, -20, -10,
+				-20, -30, -30, -40, -40, -30, -30, -20,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+			},
+			{ // Queen
+				-20, -10, -10, -5, -5, -10, -10, -20,
+				-10, 0, 5, 0, 0, 0, 0, -10,
+				-10, 5, 5, 5, 5, 5, 0, -10,
+				0, 0, 5, 5, 5, 5, 0, -5,
+				-5, 0, 5, 5, 5, 5, 0, -5,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-20, -10, -10, -5, -5, -10, -10, -20,
+			},
+			{ // Rook
+				0, 0, 0, 5, 5, 0, 0, 0,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				5, 10, 10, 10, 10, 10, 10, 5,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			{ // Bishop
+				-20, -10, -10, -10, -10, -10, -10, -20,
+				-10, 5, 0, 0, 0, 0, 5, -10,
+				-10, 10, 10, 10, 10, 10, 10, -10,
+				-10, 0, 10, 10, 10, 10, 0, -10,
+				-10, 5, 5, 10, 10, 5, 5, -10,
+				-10, 0, 5, 10, 10, 5, 0, -10,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-20, -10, -10, -10, -10, -10, -10, -20,
+			},
+			{ // Knight
+				-50, -40, -30, -30, -30, -30, -40, -50,
+				-40, -20, 0, 5, 5, 0, -20, -40,
+				-30, 5, 10, 15, 15, 10, 5, -30,
+				-30, 0, 15, 20, 20, 15, 0, -30,
+				-30, 5, 15, 20, 20, 15, 5, -30,
+				-30, 0, 10, 15, 15, 10, 0, -30,
+				-40, -20, 0, 0, 0, 0, -20, -40,
+				-50, -40, -30, -30, -30, -30, -40, -50,
+			},
+			{ // Pawn
+				0, 0, 0, 0, 0, 0, 0, 0,
+				5, 10, 10, -20, -20, 10, 10, 5,
+				5, -5, -10, 0, 0, -10, -5, 5,
+				0, 0, 0, 20, 20, 0, 0, 0,
+				5, 5, 10, 25, 25, 10, 5, 5,
+				10, 10, 20, 30, 30, 20, 10, 10,
+				50, 50, 50, 50, 50, 50, 50, 50,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+		},
+		PassedPawn:                  [8]int32{0, 0, 5, 10, 20, 35, 60, 0},
+		PassedPawnFreePath:          [8]int32{0, 0, 0, 0, 5, 10, 20, 0},
+		PassedPawnBlocked:           -5,
+		PassedPawnSupported:         5,
+		PassedPawnConnected:         5,
+		PassedPawnKingDistance:      0,
+		PassedPawnEnemyKingDistance: 0,
+		PassedPawnUnstoppable:       0,
+	},
+	Endgame: PhaseWeights{
+		PieceValues:  [6]int32{1000, 900, 500, 325, 300, 100},
+		DoubledPawn:  -25,
+		IsolatedPawn: -25,
+		OpenFileRook: 10,
+		Mobility:     1,
+		KingSafety: [60]int32{
+			0, 0, 0, 2, 3, 5, 7, 9, 11, 14,
+			17, 20, 24, 27, 32, 36, 41, 46, 51, 57,
+			63, 69, 76, 83, 90, 98, 106, 114, 122, 131,
+			139, 148, 158, 167, 177, 187, 197, 207, 217, 227,
+			238, 248, 259, 269, 280, 290, 301, 311, 321, 331,
+			341, 351, 360, 369, 378, 387, 395, 402, 409, 416,
+		},
+		PieceSquare: [6][64]int32{
+			{ // King
+				-50, -30, -30, -30, -30, -30, -30, -50,
+				-30, -30, 0, 0, 0, 0, -30, -30,
+				-30, -10, 20, 30, 30, 20, -10, -30,
+				-30, -10, 30, 40, 40, 30, -10, -30,
+				-30, -10, 30, 40, 40, 30, -10, -30,
+				-30, -10, 20, 30, 30, 20, -10, -30,
+				-30, -20, -10, 0, 0, -10, -20, -30,
+				-50, -40, -30, -20, -20, -30, -40, -50,
+			},
+			{ // Queen
+				-20, -10, -10, -5, -5, -10, -10, -20,
+				-10, 0, 5, 0, 0, 0, 0, -10,
+				-10, 5, 5, 5, 5, 5, 0, -10,
+				0, 0, 5, 5, 5, 5, 0, -5,
+				-5, 0, 5, 5, 5, 5, 0, -5,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-20, -10, -10, -5, -5, -10, -10, -20,
+			},
+			{ // Rook
+				0, 0, 0, 5, 5, 0, 0, 0,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				5, 10, 10, 10, 10, 10, 10, 5,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			{ // Bishop
+				-20, -10, -10, -10, -10, -10, -10, -20,
+				-10, 5, 0, 0, 0, 0, 5, -10,
+				-10, 10, 10, 10, 10, 10, 10, -10,
+				-10, 0, 10, 10, 10, 10, 0, -10,
+				-10, 5, 5, 10, 10, 5, 5, -10,
+				-10, 0, 5, 10, 10, 5, 0, -10,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-20, -10, -10, -10, -10, -10, -10, -20,
+			},
+			{ // Knight
+				-50, -40, -30, -30, -30, -30, -40, -50,
+				-40, -20, 0, 5, 5, 0, -20, -40,
+				-30, 5, 10, 15, 15, 10, 5, -30,
+				-30, 0, 15, 20, 20, 15, 0, -30,
+				-30, 5, 15, 20, 20, 15, 5, -30,
+				-30, 0, 10, 15, 15, 10, 0, -30,
+				-40, -20, 0, 0, 0, 0, -20, -40,
+				-50, -40, -30, -30, -30, -30, -40, -50,
+			},
+			{ // Pawn
+				0, 0, 0, 0, 0, 0, 0, 0,
+				-30, -30, -30, -30, -30, -30, -30, -30,
+				-10, -10, -10, -10, -10, -10, -10, -10,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				20, 20, 20, 20, 20, 20, 20, 20,
+				40, 40, 40, 40, 40, 40, 40, 40,
+				60, 60, 60, 60, 60, 60, 60, 60,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+		},
+		PassedPawn:                  [8]int32{0, 10, 15, 25, 45, 75, 120, 0},
+		PassedPawnFreePath:          [8]int32{0, 0, 5, 10, 20, 35, 60, 0},
+		PassedPawnBlocked:           -10,
+		PassedPawnSupported:         10,
+		PassedPawnConnected:         15,
+		PassedPawnKingDistance:      -1,
+		PassedPawnEnemyKingDistance: 2,
+		PassedPawnUnstoppable:       500,
+	},
+	KingAttackWeights: [6]int32{1, 5, 3, 2, 2, 1},
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvalParamsFile(t *testing.T) {
+	t.Cleanup(func() {
+		SetEvalParamsFile("")
+	})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "params.json")
+	params := defaultEvalParams
+	params.Midgame.PieceSquare[Knight][SFS("f3")] = 17
+	params.Endgame.KingSafety[59] = -3
+	if err := SaveEvalParams(path, &params); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadEvalParams(path); err != nil {
+		t.Fatal(err)
+	} else if loaded != params {
+		t.Error("loaded parameters differ from the saved ones")
+	}
+
+	partialPath := filepath.Join(dir, "partial.json")
+	if err := os.WriteFile(partialPath, []byte(`{"Endgame": {"Mobility": 4, "PieceValues": [1000, 900, 500, 325, 320, 100]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetEvalParamsFile(partialPath); err != nil {
+		t.Fatal(err)
+	}
+	if MobilityValue != S(defaultEvalParams.Midgame.Mobility*CentiPawn, 4*CentiPawn) || pieceValueScores[Knight].endgame() != 320*CentiPawn ||
+		evalParams.Endgame.DoubledPawn != defaultEvalParams.Endgame.DoubledPawn || evalParams.Midgame != defaultEvalParams.Midgame {
+		t.Error("a partial file did not keep the defaults for the missing fields")
+	}
+
+	unknownPath := filepath.Join(dir, "unknown.json")
+	if err := os.WriteFile(unknownPath, []byte(`{"Endgame": {"Mobilty": 4}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEvalParams(unknownPath); err == nil {
+		t.Error("a misspelled field loaded without an error")
+	}
+}
+
+func TestEvalTermOptions(t *testing.T) {
+	t.Cleanup(func() {
+		SetEvalParamsFile("")
+	})
+	s := FenState("4k3/8/8/8/8/8/8/1N2K3 w - - 0 1")
+	before := s.EvalState(White)
+	// Only the endgame share of the change shows, however much the knight adds to the phase
+	want := S(0, 50*CentiPawn).taper(s.TraceEval().phase)
+	term, ok := evalParams.term("endgame.piecevalues.knight")
+	if !ok {
+		t.Fatal("Endgame.PieceValues.Knight is not an option")
+	}
+	if !setEvalTerm(term, "350") {
+		t.Fatal("350 was not accepted")
+	}
+	if after := s.EvalState(White); after-before-want < -1 || after-before-want > 1 {
+		t.Errorf("raising the knight by 50 changed the eval by %d, want %d", after-before, want)
+	}
+	if setEvalTerm(term, "x") || setEvalTerm(term, "20000") {
+		t.Error("an invalid value was accepted")
+	}
+	if _, ok := evalParams.term("Midgame.KingSafety"); ok {
+		t.Error("a table is settable as a single option")
+	}
+}
+
+// Kings add their moves to mobility and the squares they share with the enemy king to its attackers
+func TestKingEvalTerms(t *testing.T) {
+	t.Cleanup(func() {
+		SetEvalParamsFile("")
+	})
+	// The white king reaches c4, d4 and e4 next to the black king
+	s := FenState("8/8/8/3k4/8/3K4/8/8 w - - 0 1")
+	trace := s.TraceEval()
+	if mobility := trace.terms[traceMobility][White]; mobility != 8*MobilityValue {
+		t.Errorf("king mobility %v, want 8 moves", mobility)
+	}
+	if kingSafety := trace.terms[traceKingSafety][White]; kingSafety != getKingSafetyValue(3*kingAttackWeights[King]) {
+		t.Errorf("king attacks %v, want 3 squares", kingSafety)
+	}
+	term, ok := evalParams.term("KingAttackWeights.King")
+	if !ok || !setEvalTerm(term, "10") {
+		t.Fatal("KingAttackWeights.King could not be set")
+	}
+	if kingSafety := s.TraceEval().terms[traceKingSafety][White]; kingSafety != getKingSafetyValue(30) {
+		t.Errorf("king attacks with a weight of 10 %v, want the value for 30 points", kingSafety)
+	}
+}
+
+// The terms of the trace have to add up to the evaluation itself
+func TestEvalTrace(t *testing.T) {
+	for _, fen := range benchFens {
+		s, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace := s.TraceEval()
+		if eval := s.EvalState(White); trace.eval != eval || trace.total() != eval {
+			t.Errorf("%s: eval %d but the trace returned %d and its terms add up to %d", fen, eval, trace.eval, trace.total())
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	values := []int32{0, 1, -1, 100 * CentiPawn, -100 * CentiPawn, 1<<31 - 1, -1 << 31}
+	for _, midgame := range values {
+		for _, endgame := range values {
+			if score := S(midgame, endgame); score.midgame() != midgame || score.endgame() != endgame {
+				t.Errorf("S(%d, %d) unpacked to %d, %d", midgame, endgame, score.midgame(), score.endgame())
+			}
+		}
+	}
+	sum := S(300, -20) - S(-50, 70) + 3*S(-10, 5)
+	if sum.midgame() != 320 || sum.endgame() != -75 {
+		t.Errorf("sum unpacked to %d, %d", sum.midgame(), sum.endgame())
+	}
+	if tapered := S(240*CentiPawn, -48*CentiPawn).taper(TotalPhaseValue / 4); tapered != 24*CentiPawn {
+		t.Errorf("a quarter of the midgame tapered to %d", tapered/CentiPawn)
+	}
+}
+
+func TestPassedPawns(t *testing.T) {
+	tests := []struct {
+		fen   string
+		white []string
+		black []string
+	}{
+		{"4k3/8/8/3p4/8/8/3P4/4K3 w - - 0 1", nil, nil},
+		{"4k3/8/8/2p5/8/8/3P4/4K3 w - - 0 1", nil, nil},
+		{"4k3/8/8/8/2p5/3P4/8/4K3 w - - 0 1", nil, nil},
+		{"4k3/8/8/8/3P4/2p5/8/4K3 w - - 0 1", []string{"d4"}, []string{"c3"}},
+		{"4k3/8/8/8/3P4/3P4/8/4K3 w - - 0 1", []string{"d4"}, nil},
+		{"4k3/p7/8/8/8/8/6PP/4K3 w - - 0 1", []string{"g2", "h2"}, []string{"a7"}},
+	}
+	for _, test := range tests {
+		s := FenState(test.fen)
+		for side, squares := range [][]string{test.white, test.black} {
+			want := EmptyBitboard
+			for _, square := range squares {
+				want |= boardFromSquare(SFS(square))
+			}
+			if passed := s.passedPawns(uint8(side)); passed != want {
+				t.Errorf("%s: passed pawns of side %d are\n%v\nwant\n%v", test.fen, side, passed, want)
+			}
+		}
+	}
+}
+
+func TestRuleOfTheSquare(t *testing.T) {
+	tests := []struct {
+		fen     string
+		square  string
+		outside bool
+	}{
+		{"8/8/8/8/P4k2/8/8/K7 w - - 0 1", "a4", true},
+		{"8/8/8/8/P4k2/8/8/K7 b - - 0 1", "a4", false},
+		{"8/8/8/8/P3k3/8/8/K7 w - - 0 1", "a4", false},
+		{"8/8/8/8/5k2/8/P7/K7 w - - 0 1", "a2", false},
+		{"8/8/8/8/6k1/8/P7/K7 w - - 0 1", "a2", true},
+		{"k7/8/8/8/8/8/pK6/8 b - - 0 1", "a2", false},
+		{"k7/8/8/8/8/7K/p7/8 w - - 0 1", "a2", true},
+		{"8/K7/8/8/P5k1/8/8/8 w - - 0 1", "a4", false}, // Blocked by its own king
+	}
+	for _, test := range tests {
+		s := FenState(test.fen)
+		side := uint8(White)
+		if s.board[BlackPawn] != 0 {
+			side = Black
+		}
+		if outside := s.outsideSquare(SFS(test.square), side); outside != test.outside {
+			t.Errorf("%s: the king is outside the square of %s: %v, want %v", test.fen, test.square, outside, test.outside)
+		}
+	}
+}
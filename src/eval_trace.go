@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const (
+	traceMaterial = iota
+	tracePieceSquare
+	traceMobility
+	traceKingSafety
+	traceDoubledPawns
+	traceIsolatedPawns
+	tracePassedPawns
+	traceOpenFileRooks
+	traceTermCount
+)
+
+var traceTermNames [traceTermCount]string = [traceTermCount]string{
+	"Material", "Piece squares", "Mobility", "King attacks", "Doubled pawns", "Isolated pawns", "Passed pawns", "Open file rooks",
+}
+
+// What each term of the handcrafted evaluation added for each side, in the units of EvalState.
+// Both sides' values are positive when they are good for that side
+type EvalTrace struct {
+	terms [traceTermCount][2]Score // Term and side
+	phase int8                     // Out of TotalPhaseValue, which is a full midgame
+	eval  int32                    // From white's point of view
+}
+
+// Format is "eval [FEN]". Prints the evaluation of the position term by term
+func EvalCommand(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	evalParamsPath := flags.String("evalparams", "", "JSON file with the weights of the handcrafted evaluation")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ghobos eval [flags] [FEN]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := SetEvalParamsFile(*evalParamsPath); err != nil {
+		return err
+	}
+	fen := startingFenString
+	if flags.NArg() > 0 {
+		fen = strings.Join(flags.Args(), " ")
+	}
+	s, err := ParseFEN(fen)
+	if err != nil {
+		return err
+	}
+	fmt.Print(s.TraceEval())
+	return nil
+}
+
+func (s *State) TraceEval() *EvalTrace {
+	trace := &EvalTrace{}
+	trace.eval = s.handcraftedEval(White, trace)
+	return trace
+}
+
+func (trace *EvalTrace) add(term int, side uint8, value Score) {
+	if trace == nil {
+		return
+	}
+	trace.terms[term][side] += value
+}
+
+func (trace *EvalTrace) setPhase(phase int8) {
+	if trace == nil {
+		return
+	}
+	trace.phase = phase
+}
+
+func (trace *EvalTrace) String() string {
+	pawns := func(value int32) string {
+		return fmt.Sprintf("%7.2f", float64(value)/float64(CentiPawn)/100)
+	}
+	output := strings.Builder{}
+	output.WriteString("           Term |      White      |      Black      |      Total\n")
+	output.WriteString("                |    MG      EG   |    MG      EG   |    MG      EG\n")
+	output.WriteString("----------------+-----------------+-----------------+----------------\n")
+	for term, sides := range trace.terms {
+		white, black, total := sides[White], sides[Black], sides[White]-sides[Black]
+		fmt.Fprintf(&output, "%15s | %s %s | %s %s | %s %s\n", traceTermNames[term], pawns(white.midgame()), pawns(white.endgame()),
+			pawns(black.midgame()), pawns(black.endgame()), pawns(total.midgame()), pawns(total.endgame()))
+	}
+	output.WriteString("----------------+-----------------+-----------------+----------------\n")
+	fmt.Fprintf(&output, "Phase: %d/%d of the midgame\n", trace.phase, TotalPhaseValue)
+	fmt.Fprintf(&output, "Total: %s (white side)\n", strings.TrimSpace(pawns(trace.total())))
+	return output.String()
+}
+
+// Sum of the terms blended by the phase, which is the evaluation itself
+func (trace *EvalTrace) total() int32 {
+	total := Score(0)
+	for _, sides := range trace.terms {
+		total += sides[White] - sides[Black]
+	}
+	return total.taper(trace.phase)
+}
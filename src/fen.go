@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var fenPieceMap map[rune]uint8 = map[rune]uint8{'K': 0, 'Q': 1, 'R': 2, 'B': 3, 'N': 4, 'P': 5, 'k': 6, 'q': 7, 'r': 8, 'b': 9, 'n': 10, 'p': 11}
+
+// Accepts full six field FENs as well as the four field form used by EPD, in which case the half
+// move clock is 0 and the full move number is 1. The position is validated before it is returned
+func ParseFEN(fenString string) (*State, error) {
+	fields := strings.Fields(fenString)
+	if len(fields) < 4 || len(fields) > 6 {
+		return nil, fmt.Errorf("invalid FEN %q: expected 4 to 6 fields but found %d", fenString, len(fields))
+	}
+	board, err := parseFenBoard(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEN %q: %w", fenString, err)
+	}
+	var turn uint8
+	switch fields[1] {
+	case "w":
+		turn = White
+	case "b":
+		turn = Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: side to move must be w or b, not %q", fenString, fields[1])
+	}
+	castleAvailability, castleRookSquares, err := parseFenCastling(fields[2], board)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEN %q: %w", fenString, err)
+	}
+	enPassantSquare := Square(100)
+	canEnpassant := false
+	if fields[3] != "-" {
+		enPassantString := fields[3]
+		if len(enPassantString) != 2 || enPassantString[0] < 'a' || enPassantString[0] > 'h' || (enPassantString[1] != '3' && enPassantString[1] != '6') {
+			return nil, fmt.Errorf("invalid FEN %q: invalid en passant square %q", fenString, enPassantString)
+		}
+		enPassantSquare = SFS(enPassantString)
+		canEnpassant = true
+	}
+	halfMoveClock := 0
+	if len(fields) > 4 {
+		halfMoveClock, err = strconv.Atoi(fields[4])
+		if err != nil || halfMoveClock < 0 || halfMoveClock > 0xffff {
+			return nil, fmt.Errorf("invalid FEN %q: invalid half move clock %q", fenString, fields[4])
+		}
+	}
+	fullMoveNumber := 1
+	if len(fields) > 5 {
+		fullMoveNumber, err = strconv.Atoi(fields[5])
+		if err != nil || fullMoveNumber < 0 || fullMoveNumber > 0x7fff {
+			return nil, fmt.Errorf("invalid FEN %q: invalid full move number %q", fenString, fields[5])
+		}
+		// Some programs write 0 for the first move
+		fullMoveNumber = max(fullMoveNumber, 1)
+	}
+	ply := uint16((fullMoveNumber - 1) * 2)
+	if turn == Black {
+		ply += 1
+	}
+	sideOccupied := [2]Bitboard{EmptyBitboard, EmptyBitboard}
+	for i := 0; i < 6; i++ {
+		sideOccupied[White] |= board[i]
+		sideOccupied[Black] |= board[6+i]
+	}
+	occupied := sideOccupied[White] | sideOccupied[Black]
+	repetitionMap := make(RepetitionMap, 50)
+	s := &State{
+		board:                  board,
+		sideOccupied:           sideOccupied,
+		occupied:               occupied,
+		notOccupied:            ^occupied,
+		pinInfo:                PinInfo{pinnedBoards: [2]Bitboard{}, pinners: [2][8]Square{}, pinsSet: [2]bool{false, false}},
+		turn:                   turn,
+		enPassantSquare:        enPassantSquare,
+		captureHistory:         NewCaptureHistory(32),
+		canEnpassant:           canEnpassant,
+		enPassantSquareHistory: NewEnpassantHistory(16),
+		lastCapOrPawn:          uint16(halfMoveClock),
+		ply:                    ply,
+		castleAvailability:     castleAvailability,
+		castleRookSquares:      castleRookSquares,
+		castleHistory:          NewCastleHistory(4),
+		fiftyMoveHistory:       newFiftyMoveRuleHistory(104),
+		repetitionMap:          &repetitionMap,
+		hashHistory:            NewHashHistory(5),
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid FEN %q: %w", fenString, err)
+	}
+	s.setupCastling()
+	// Recorded as if the double pawn push was just played so unmaking a move restores it. At ply
+	// 0 this wraps around to the same value UnMakeMove compares against
+	if canEnpassant {
+		s.enPassantSquareHistory.Push(enPassantSquare, ply-1)
+	}
+	s.check = s.kingAttacked(turn)
+	s.hashcode = s.hash()
+	s.hashHistory.Push(s.hashcode)
+	s.ensurePins(White)
+	s.ensurePins(Black)
+	return s, nil
+}
+
+func parseFenBoard(boardString string) (Board, error) {
+	board := Board{}
+	rankStrings := strings.Split(boardString, "/")
+	if len(rankStrings) != 8 {
+		return board, fmt.Errorf("expected 8 ranks but found %d", len(rankStrings))
+	}
+	for i, rankString := range rankStrings {
+		rank := 7 - i
+		file := 0
+		for _, c := range rankString {
+			if piece, ok := fenPieceMap[c]; ok {
+				if file < 8 {
+					board[piece] |= boardFromSquare(Square(rank*8 + file))
+				}
+				file++
+			} else if c >= '1' && c <= '8' {
+				file += int(c - '0')
+			} else {
+				return board, fmt.Errorf("invalid character %q in rank %d", c, rank+1)
+			}
+		}
+		if file != 8 {
+			return board, fmt.Errorf("rank %d has %d squares instead of 8", rank+1, file)
+		}
+	}
+	return board, nil
+}
+
+// The standard KQkq letters stand for the outermost rook on that side of the king, as in X-FEN,
+// while Shredder-FEN names the file of each castling rook so Chess960 positions can use either
+func parseFenCastling(castleString string, board Board) (CastleAvailability, [4]Square, error) {
+	castleAvailability := CastleAvailability{}
+	rookSquares := [4]Square{7, 63, 0, 56}
+	if castleString == "-" {
+		return castleAvailability, rookSquares, nil
+	}
+	for _, c := range castleString {
+		side := uint8(White)
+		if c >= 'a' && c <= 'z' {
+			side = Black
+			c -= 'a' - 'A'
+		}
+		backRank := Square(56 * side)
+		kingBoard := board[side*6+King] & ranks[7*side]
+		// Without the king on its back rank the corners are kept for Validate to report
+		kingFile := int8(-1)
+		if kingBoard != 0 {
+			kingFile = GetLSB(kingBoard).File()
+		}
+		castle := int(side)
+		switch {
+		case c == 'K' || c == 'Q':
+			if c == 'Q' {
+				castle += 2
+			}
+			// The first rook found going from the corner towards the king
+			for i := int8(0); kingFile >= 0 && i < 8; i++ {
+				file := 7 - i
+				if c == 'Q' {
+					file = i
+				}
+				if file == kingFile || (file > kingFile) != (c == 'K') {
+					break
+				}
+				if board[side*6+Rook]&boardFromSquare(backRank+Square(file)) != 0 {
+					rookSquares[castle] = backRank + Square(file)
+					break
+				}
+			}
+		case c >= 'A' && c <= 'H':
+			file := int8(c - 'A')
+			if kingFile < 0 || file == kingFile {
+				return castleAvailability, rookSquares, fmt.Errorf("castling right %q without the king on its back rank beside the rook", c)
+			}
+			if file < kingFile {
+				castle += 2
+			}
+			rookSquares[castle] = backRank + Square(file)
+		default:
+			return castleAvailability, rookSquares, fmt.Errorf("invalid castling rights %q", castleString)
+		}
+		if castleAvailability[castle] {
+			return castleAvailability, rookSquares, fmt.Errorf("castling right %q repeated in %q", c, castleString)
+		}
+		castleAvailability[castle] = true
+	}
+	return castleAvailability, rookSquares, nil
+}
+
+// Works out the squares each castling move needs empty and safe, which stay the same for the
+// whole game as castling is only possible while the king and rook have not moved
+func (s *State) setupCastling() {
+	span := func(a Square, b Square) Bitboard {
+		board := EmptyBitboard
+		for square := min(a, b); square <= max(a, b); square++ {
+			board |= boardFromSquare(square)
+		}
+		return board
+	}
+	for castle, available := range s.castleAvailability {
+		if !available {
+			continue
+		}
+		rankIndex := Square(56 * (castle % 2))
+		kingSquare := GetLSB(s.board[(castle%2)*6+King])
+		rookSquare := s.castleRookSquares[castle]
+		kingDestination, rookDestination := rankIndex+6, rankIndex+5
+		if castle >= 2 {
+			kingDestination, rookDestination = rankIndex+2, rankIndex+3
+		}
+		kingPath := span(kingSquare, kingDestination) &^ boardFromSquare(kingSquare)
+		s.castleEmptyBoards[castle] = (kingPath | span(rookSquare, rookDestination)) &^ (boardFromSquare(kingSquare) | boardFromSquare(rookSquare))
+		s.castleSafeBoards[castle] = kingPath
+	}
+}
+
+// X-FEN letter of a castling right, the file of the rook when another rook is further out
+func (s *State) castleRightString(castle int) string {
+	side := castle % 2
+	rookSquare := s.castleRookSquares[castle]
+	for file := int8(0); file < 8; file++ {
+		further := file > rookSquare.File()
+		if castle >= 2 {
+			further = file < rookSquare.File()
+		}
+		if further && s.board[side*6+Rook]&boardFromSquare(Square(56*side)+Square(file)) != 0 {
+			return string([2]byte{'A', 'a'}[side] + byte(rookSquare.File()))
+		}
+	}
+	return string("KkQq"[castle])
+}
+
+// Checks that the position could have come from a legal game as far as can be told without its history
+func (s *State) Validate() error {
+	sideNames := [2]string{"white", "black"}
+	for side := uint8(White); side <= Black; side++ {
+		friendIndex := side * 6
+		if kings := BitCount(s.board[friendIndex+King]); kings != 1 {
+			return fmt.Errorf("%s has %d kings", sideNames[side], kings)
+		}
+		if pawns := BitCount(s.board[friendIndex+Pawn]); pawns > 8 {
+			return fmt.Errorf("%s has %d pawns", sideNames[side], pawns)
+		}
+		if pieces := BitCount(s.sideOccupied[side]); pieces > 16 {
+			return fmt.Errorf("%s has %d pieces", sideNames[side], pieces)
+		}
+	}
+	if (s.board[WhitePawn]|s.board[BlackPawn])&(ranks[0]|ranks[7]) != 0 {
+		return fmt.Errorf("pawns on the first or last rank")
+	}
+	if s.kingAttacked(1 - s.turn) {
+		return fmt.Errorf("%s is in check but it is %s's turn", sideNames[1-s.turn], sideNames[s.turn])
+	}
+	castleNames := [4]string{"K", "k", "Q", "q"}
+	for i, available := range s.castleAvailability {
+		side := uint8(i % 2)
+		if !available {
+			continue
+		}
+		if s.board[side*6+King]&ranks[7*side] == 0 {
+			return fmt.Errorf("castling right %s without the king on its back rank", castleNames[i])
+		}
+		if s.board[side*6+Rook]&boardFromSquare(s.castleRookSquares[i]) == 0 {
+			return fmt.Errorf("castling right %s without a rook on %s", castleNames[i], s.castleRookSquares[i])
+		}
+	}
+	if s.canEnpassant {
+		// The pawn that just moved two squares must be in front of the en passant square with
+		// both squares it passed over empty
+		var pawnStep Step = DownStep
+		var startStep Step = UpStep
+		var expectedRank int8 = 5
+		if s.turn == Black {
+			pawnStep = UpStep
+			startStep = DownStep
+			expectedRank = 2
+		}
+		pawnSquare := s.enPassantSquare.Step(pawnStep)
+		startSquare := s.enPassantSquare.Step(startStep)
+		if s.enPassantSquare.Rank() != expectedRank {
+			return fmt.Errorf("en passant square %s is on the wrong rank for %s to move", s.enPassantSquare, sideNames[s.turn])
+		}
+		if s.board[(1-s.turn)*6+Pawn]&boardFromSquare(pawnSquare) == 0 {
+			return fmt.Errorf("en passant square %s without a pawn that just moved past it", s.enPassantSquare)
+		}
+		if s.occupied&(boardFromSquare(s.enPassantSquare)|boardFromSquare(startSquare)) != 0 {
+			return fmt.Errorf("en passant square %s is not empty", s.enPassantSquare)
+		}
+	}
+	return nil
+}
+
+// True if the king of the given side is attacked by any enemy piece
+func (s *State) kingAttacked(side uint8) bool {
+	enemyIndex := (1 - side) * 6
+	bishopBoard := s.board[enemyIndex+Bishop] | s.board[enemyIndex+Queen]
+	rookBoard := s.board[enemyIndex+Rook] | s.board[enemyIndex+Queen]
+	enemySafetyCheck := &SafetyCheckBoards{bishopBoard, rookBoard, s.board[enemyIndex+Knight], s.board[enemyIndex+King], s.board[enemyIndex+Pawn], s.sideOccupied[1-side]}
+	return !isSquareSafe(GetLSB(s.board[side*6+King]), s.sideOccupied[side], enemySafetyCheck, side)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFEN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		err  string // Part of the error message, empty when the FEN is valid
+	}{
+		{"start position", startingFenString, ""},
+		{"epd fields", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3", ""},
+		{"move number 0", "4k3/8/8/8/8/8/8/4K3 w - - 0 0", ""},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", ""},
+		{"missing fields", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq", "expected 4 to 6 fields but found 3"},
+		{"extra fields", startingFenString + " 1", "expected 4 to 6 fields but found 7"},
+		{"missing rank", "rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "expected 8 ranks but found 7"},
+		{"short rank", "rnbqkbnr/pppppppp/8/8/7/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rank 4 has 7 squares instead of 8"},
+		{"long rank", "rnbqkbnr/pppppppp/8/8/44P/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rank 4 has 9 squares instead of 8"},
+		{"bad piece", "rnbqkbnr/pppppppp/8/8/3X4/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "invalid character 'X'"},
+		{"bad side to move", "4k3/8/8/8/8/8/8/4K3 x - - 0 1", "side to move must be w or b"},
+		{"two kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "white has 2 kings"},
+		{"no king", "8/8/8/8/8/8/8/4K3 w - - 0 1", "black has 0 kings"},
+		{"pawn on the back rank", "4k2P/8/8/8/8/8/8/4K3 w - - 0 1", "pawns on the first or last rank"},
+		{"pawn on the first rank", "4k3/8/8/8/8/8/8/p3K3 b - - 0 1", "pawns on the first or last rank"},
+		{"side not to move in check", "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "black is in check but it is white's turn"},
+		{"castling without the rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", "without a rook on h1"},
+		{"castling without the king", "r3k2r/8/8/8/8/8/4K3/R6R w Q - 0 1", "without the king on its back rank"},
+		{"castling with the rook gone", "r3k2r/8/8/8/8/8/8/R3K2R w C - 0 1", "without a rook on c1"},
+		{"castling on the king's file", "r3k2r/8/8/8/8/8/8/R3K2R w E - 0 1", "without the king on its back rank beside the rook"},
+		{"chess960 x-fen", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", ""},
+		{"chess960 shredder-fen", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", ""},
+		{"repeated castling right", "r3k2r/8/8/8/8/8/8/R3K2R w KK - 0 1", "repeated"},
+		{"bad castling right", "r3k2r/8/8/8/8/8/8/R3K2R w X - 0 1", "invalid castling rights"},
+		{"en passant without a pawn", "4k3/8/8/8/8/8/8/4K3 w - d6 0 1", "without a pawn that just moved past it"},
+		{"en passant on the wrong rank", "4k3/8/8/3pP3/8/8/8/4K3 b - d6 0 1", "on the wrong rank"},
+		{"bad en passant square", "4k3/8/8/8/8/8/8/4K3 w - d5 0 1", "invalid en passant square"},
+		{"bad half move clock", "4k3/8/8/8/8/8/8/4K3 w - - x 1", "invalid half move clock"},
+		{"bad full move number", "4k3/8/8/8/8/8/8/4K3 w - - 0 -1", "invalid full move number"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseFEN(test.fen)
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if s.fenString() == "" {
+					t.Error("no FEN written for a valid position")
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+	// Castling rights name the outermost rook unless there is a rook further out
+	castlingTests := []struct {
+		fen         string
+		rookSquares [4]string
+		written     string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1", [4]string{"h1", "h8", "a1", "a8"}, "KQkq"},
+		{"1r4kr/8/8/8/8/8/8/1R4KR w KQkq - 0 1", [4]string{"h1", "h8", "b1", "b8"}, "KQkq"},
+		{"rr2k3/8/8/8/8/8/8/RR2K3 w Bb - 0 1", [4]string{"h1", "h8", "b1", "b8"}, "Bb"},
+		{"4k1rr/8/8/8/8/8/8/4K1RR w Gg - 0 1", [4]string{"g1", "g8", "a1", "a8"}, "Gg"},
+	}
+	for _, test := range castlingTests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, square := range test.rookSquares {
+			if s.castleRookSquares[i] != SFS(square) {
+				t.Errorf("%s has castling rook %d on %s, want %s", test.fen, i, s.castleRookSquares[i], square)
+			}
+		}
+		if written := strings.Fields(s.fenString())[2]; written != test.written {
+			t.Errorf("%s written with castling rights %s, want %s", test.fen, written, test.written)
+		}
+	}
+	// EPD positions have a zero half move clock and start at the first move
+	s, _ := ParseFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3")
+	if s.lastCapOrPawn != 0 || s.ply != 1 || !s.canEnpassant || s.enPassantSquare != SFS("e3") {
+		t.Errorf("EPD position parsed with half move clock %d, ply %d and en passant %v", s.lastCapOrPawn, s.ply, s.canEnpassant)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Changing the seed and running "ghobos generate" changes the magic numbers in the source
+const magicSeed = 1070372
+
+const generatedHeader = "// Code generated by \"ghobos generate\"; DO NOT EDIT.\n\npackage main\n\n"
+
+// Format is "generate [-dir <directory>]". Rewrites magic_data.go from the fixed seed, so running it
+// without changing the seed reproduces the file exactly
+func GenerateCommand(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "directory of the source files to write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	formatted, err := format.Source(generateMagicSource())
+	if err != nil {
+		return fmt.Errorf("formatting magic_data.go: %w", err)
+	}
+	path := filepath.Join(*dir, "magic_data.go")
+	if err := os.WriteFile(path, formatted, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	return nil
+}
+
+func generateMagicSource() []byte {
+	random := rand.New(rand.NewSource(magicSeed))
+	source := bytes.Buffer{}
+	source.WriteString(generatedHeader)
+	pieces := []struct {
+		name    string
+		attacks *[64]Bitboard
+	}{{"bishopMagics", &moveBoards[Bishop]}, {"rookMagics", &moveBoards[Rook]}}
+	for _, piece := range pieces {
+		source.WriteString("// Mask, magic number, index bits and offset into the attack table\n")
+		fmt.Fprintf(&source, "var %s [64]Magic = [64]Magic{\n", piece.name)
+		var offset uint32 = 0
+		for square := Square(0); square < 64; square++ {
+			magic := FindMagic(square, piece.attacks, random)
+			magic.offset = offset
+			offset += 1 << magic.index
+			fmt.Fprintf(&source, "{0x%016X, 0x%016X, %d, 0x%X}, // %s\n", uint64(magic.mask), magic.magicNumber, magic.index, magic.offset, square)
+		}
+		source.WriteString("}\n\n")
+	}
+	return source.Bytes()
+}
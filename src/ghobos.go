@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"strings"
 	"time"
 )
@@ -15,8 +16,27 @@ func main() {
 	InitializeMoveBoards()
 	InitializeEvalVariables()
 	setupFillBoards()
-	SetupTable(4096)
-	UIGame()
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	SetSearchThreads(DefaultSearchThreads)
+	fmt.Printf("%s by %s. Enter \"uci\" or \"xboard\" for protocol mode or anything else to play in the console\n", EngineName, EngineAuthor)
+	var mode string
+	fmt.Scanln(&mode)
+	switch strings.ToLower(mode) {
+	case "uci":
+		UCILoop()
+	case "xboard":
+		SetupTable(DefaultHashSize)
+		XBoardLoop()
+	default:
+		SetupTable(DefaultHashSize)
+		UIGame()
+	}
 }
 
 func UIGame() {
@@ -38,7 +58,22 @@ func UIGame() {
 			break
 		}
 	}
+	fmt.Print("Opening book file (leave empty for none): ")
+	var bookPath string
+	// An empty line is reported as an error and leaves the path empty
+	fmt.Scanln(&bookPath)
+	if err := SetBookFile(bookPath); err != nil {
+		fmt.Println("Could not load book:", err)
+	}
 	gameState := StartingFen()
+	game := NewPGNGame()
+	game.SetTag("Date", time.Now().Format("2006.01.02"))
+	game.SetTag("White", "Player")
+	game.SetTag("Black", EngineName)
+	if playerSide == Black {
+		game.SetTag("White", EngineName)
+		game.SetTag("Black", "Player")
+	}
 	gameOver := false
 	playerTurn := false
 	if playerSide == gameState.turn {
@@ -49,18 +84,20 @@ func UIGame() {
 		fmt.Println(gameState.fenString())
 		if playerTurn {
 			for {
-				playerMove := getUserMove()
-				validMoves := gameState.quickGenMoves()
+				playerMove := getUserMove(gameState)
+				validMoves := LegalMoves(gameState)
 				found := false
 				var foundMove Move
-				for _, move := range *validMoves {
+				for _, move := range validMoves {
 					if sameSourceDes(playerMove, Move(move)) {
 						found = true
 						foundMove = move
 					}
 				}
 				if found {
-					if foundMove.SpecialMove() == PromotionSpecialMove {
+					if playerMove.SpecialMove() == PromotionSpecialMove {
+						foundMove = playerMove
+					} else if foundMove.SpecialMove() == PromotionSpecialMove {
 						for {
 							fmt.Print("What piece do you want to promote to (queen/rook/bishop/knight): ")
 							var promotionString string
@@ -79,6 +116,7 @@ func UIGame() {
 							}
 						}
 					}
+					game.AddMove(foundMove)
 					gameState.MakeMove(foundMove)
 					break
 				} else {
@@ -86,12 +124,19 @@ func UIGame() {
 				}
 			}
 		} else {
-			searchTime := GetUserFloat("How long would you like to search (in seconds)?: ")
-			bestMove := gameState.IterativeDeepiningSearch(time.Duration(searchTime*float64(time.Second)), true)
+			bestMove, inBook := gameState.BookMove()
+			if inBook {
+				fmt.Println("Ghobos plays", gameState.SAN(bestMove), "from its book")
+			} else {
+				searchTime := GetUserFloat("How long would you like to search (in seconds)?: ")
+				bestMove = gameState.IterativeDeepiningSearch(time.Duration(searchTime*float64(time.Second)), true)
+				fmt.Println("Ghobos plays", gameState.SAN(bestMove))
+			}
+			game.AddMove(bestMove)
 			gameState.MakeMove(bestMove)
 		}
-		moves := gameState.quickGenMoves()
-		if len(*moves) == 0 {
+		moves := LegalMoves(gameState)
+		if len(moves) == 0 {
 			fmt.Println(gameState)
 			if gameState.check {
 				if playerTurn {
@@ -114,4 +159,8 @@ func UIGame() {
 		}
 		playerTurn = !playerTurn
 	}
+	result, _, _ := gameState.gameResult()
+	game.SetResult(result)
+	fmt.Println()
+	game.Write(os.Stdout)
 }
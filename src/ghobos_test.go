@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Sets up the same tables main does before any test runs
+func TestMain(m *testing.M) {
+	InitializeMoveBoards()
+	InitializeEvalVariables()
+	setupFillBoards()
+	SetupTable(MinHashSize)
+	os.Exit(m.Run())
+}
@@ -1,3 +1,3 @@
-module main
+module ghobos
 
 go 1.22.4
@@ -1,26 +1,41 @@
 package main
 
-import "math/rand"
-
 type RepetitionMap map[uint64]uint16
 
 var squareHashes [12][64]uint64
-var blackHash uint64
+var turnHash uint64 // Included while white is to move
 var castleHashes [4]uint64
 var enPassantHashes [8]uint64
 
+// The keys are the Polyglot Random64 table in polyglot_random.go, so hashes are Polyglot keys: 64
+// keys for each piece kind, then the castling rights and en passant files and finally the side to move
+const (
+	zobristCastleOffset    = 768
+	zobristEnPassantOffset = 772
+	zobristTurnOffset      = 780
+	zobristKeyCount        = 781
+)
+
+// Polyglot orders piece kinds black pawn, white pawn, black knight, white knight and so on up to
+// the kings
+var zobristPieceKinds [12]int = [12]int{11, 9, 7, 5, 3, 1, 10, 8, 6, 4, 2, 0}
+
+// Polyglot orders castling rights K, Q, k, q where castleAvailability is K, k, Q, q
+var zobristCastleOrder [4]int = [4]int{0, 2, 1, 3}
+
+// Copies the precomputed keys into the tables used while making moves
 func SetupHashRandoms() {
 	for i := 0; i < 12; i++ {
 		for j := 0; j < 64; j++ {
-			squareHashes[i][j] = rand.Uint64()
+			squareHashes[i][j] = polyglotRandom64[64*zobristPieceKinds[i]+j]
 		}
 	}
-	blackHash = rand.Uint64()
+	turnHash = polyglotRandom64[zobristTurnOffset]
 	for i := 0; i < 4; i++ {
-		castleHashes[i] = rand.Uint64()
+		castleHashes[i] = polyglotRandom64[zobristCastleOffset+zobristCastleOrder[i]]
 	}
 	for i := 0; i < 8; i++ {
-		enPassantHashes[i] = rand.Uint64()
+		enPassantHashes[i] = polyglotRandom64[zobristEnPassantOffset+i]
 	}
 }
 
@@ -33,20 +48,26 @@ func (s *State) hash() uint64 {
 			hash ^= squareHashes[i][square]
 		}
 	}
-	if s.turn == Black {
-		hash ^= blackHash
+	if s.turn == White {
+		hash ^= turnHash
 	}
 	for i := 0; i < 4; i++ {
 		if s.castleAvailability[i] {
 			hash ^= castleHashes[i]
 		}
 	}
-	if s.canEnpassant {
+	if s.enPassantHashed() {
 		hash ^= enPassantHashes[s.enPassantSquare.File()]
 	}
 	return hash
 }
 
+// As in Polyglot the en passant file is only part of the hash when a pawn of the side to move
+// stands next to the pawn that just moved two squares, whether or not the capture is legal
+func (s *State) enPassantHashed() bool {
+	return s.canEnpassant && pawnAttackBoards[1-s.turn][s.enPassantSquare]&s.board[s.turn*6+Pawn] != 0
+}
+
 func (repetitionMap *RepetitionMap) add(hash uint64) {
 	_, exists := (*repetitionMap)[hash]
 	if exists {
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"strings"
+	"testing"
+)
+
+// A double pawn push only changes the hash through the en passant file when it can be captured
+func TestEnPassantHashing(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     string
+		afterFen string
+		same     bool
+	}{
+		{startingFenString, "e2e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", true},
+		{"rnbqkbnr/ppp1pppp/8/8/3p4/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", false},
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, ok := s.moveFromShortString(test.move)
+		if !ok {
+			t.Fatalf("%s is not legal in %s", test.move, test.fen)
+		}
+		s.MakeMove(move)
+		if s.hashcode != s.hash() {
+			t.Errorf("incremental hash wrong after %s from %s", test.move, test.fen)
+		}
+		withoutEnPassant, err := ParseFEN(test.afterFen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (s.hashcode == withoutEnPassant.hashcode) != test.same {
+			t.Errorf("after %s from %s hash equal to the position without en passant is %v, want %v", test.move, test.fen, !test.same, test.same)
+		}
+	}
+}
+
+// The reference keys from the Polyglot book format specification, reached by making the moves and
+// by parsing the resulting FEN
+func TestPolyglotKeys(t *testing.T) {
+	tests := []struct {
+		moves string
+		fen   string
+		key   uint64
+	}{
+		{"", startingFenString, 0x463B96181691FC9C},
+		{"e2e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823C9B50FD114196},
+		{"e2e4 d7d5", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756B94461C50FB0},
+		{"e2e4 d7d5 e4e5", "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662FAFB965DB29D4},
+		{"e2e4 d7d5 e4e5 f7f5", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22A48B5A8E47FF78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652A607CA3F242C1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", "rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00FDD303C946BDD9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", "rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3C8123EA7B067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", "rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5C3F9B829B279560},
+	}
+	for _, test := range tests {
+		s := StartingFen()
+		for _, field := range strings.Fields(test.moves) {
+			move, ok := s.moveFromShortString(field)
+			if !ok {
+				t.Fatalf("%s is not legal in %s", field, s.fenString())
+			}
+			s.MakeMove(move)
+		}
+		if s.hashcode != test.key {
+			t.Errorf("after %q the key is %016X, want %016X", test.moves, s.hashcode, test.key)
+		}
+		parsed, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.hashcode != test.key {
+			t.Errorf("%s has key %016X, want %016X", test.fen, parsed.hashcode, test.key)
+		}
+	}
+}
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	generated, err := format.Source(generateMagicSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := os.ReadFile("magic_data.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, existing) {
+		t.Error("magic_data.go is out of date, run \"ghobos generate\"")
+	}
+}
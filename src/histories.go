@@ -56,7 +56,11 @@ func (pH *CaptureHistory) Pop() Capture {
 }
 
 func (pH *CaptureHistory) Push(piece uint8, ply uint16) {
-	pH.slice[pH.currentIndex] = Capture{piece: piece, ply: ply}
+	if pH.currentIndex > int32(len(pH.slice)-1) {
+		pH.slice = append(pH.slice, Capture{piece: piece, ply: ply})
+	} else {
+		pH.slice[pH.currentIndex] = Capture{piece: piece, ply: ply}
+	}
 	pH.currentIndex++
 }
 
@@ -83,7 +87,11 @@ func (eH *EnPassantSquareHistory) Peek() EnPassantEntry {
 }
 
 func (eH *EnPassantSquareHistory) Push(square Square, ply uint16) {
-	eH.slice[eH.currentIndex] = EnPassantEntry{square: square, ply: ply}
+	if eH.currentIndex > int32(len(eH.slice)-1) {
+		eH.slice = append(eH.slice, EnPassantEntry{square: square, ply: ply})
+	} else {
+		eH.slice[eH.currentIndex] = EnPassantEntry{square: square, ply: ply}
+	}
 	eH.currentIndex++
 }
 
@@ -106,7 +114,11 @@ func (cH *CastleHistory) Pop() CastleHistoryEntry {
 }
 
 func (cH *CastleHistory) Push(castle uint8, ply uint16) {
-	cH.slice[cH.currentIndex] = CastleHistoryEntry{castle: castle, ply: ply}
+	if cH.currentIndex > int32(len(cH.slice)-1) {
+		cH.slice = append(cH.slice, CastleHistoryEntry{castle: castle, ply: ply})
+	} else {
+		cH.slice[cH.currentIndex] = CastleHistoryEntry{castle: castle, ply: ply}
+	}
 	cH.currentIndex++
 }
 
@@ -132,7 +144,11 @@ func (fH *FiftyMoveHistory) Peek() FiftyMoveEntry {
 }
 
 func (fH *FiftyMoveHistory) Push(lastCapOrPawn uint16, ply uint16) {
-	fH.slice[fH.currentIndex] = FiftyMoveEntry{lastCapOrPawn, ply}
+	if fH.currentIndex > int32(len(fH.slice)-1) {
+		fH.slice = append(fH.slice, FiftyMoveEntry{lastCapOrPawn, ply})
+	} else {
+		fH.slice[fH.currentIndex] = FiftyMoveEntry{lastCapOrPawn, ply}
+	}
 	fH.currentIndex++
 }
 
@@ -0,0 +1,119 @@
+package main
+
+// Reusable storage for LegalMovesInto so enumerating moves does not allocate
+type MoveBuffer struct {
+	lists MoveLists
+	moves []Move
+}
+
+func NewMoveBuffer() *MoveBuffer {
+	return &MoveBuffer{newMoveLists(), make([]Move, 0, quietMoveListSize+captureMoveListSize)}
+}
+
+// Every legal move in the state, captures first and then quiet moves, each in generation order
+func LegalMoves(s *State) []Move {
+	return LegalMovesInto(s, NewMoveBuffer())
+}
+
+// Same as LegalMoves without allocating. The result is only valid until the buffer is used again
+func LegalMovesInto(s *State, buffer *MoveBuffer) []Move {
+	s.genAllMoves(true, &buffer.lists, &emptyHistoryTable)
+	moves := buffer.moves[:0]
+	for _, capture := range buffer.lists.captureMoves.slice[0:buffer.lists.captureMoves.len()] {
+		moves = append(moves, capture.move)
+	}
+	for _, quiet := range buffer.lists.quietMoves.slice[0:buffer.lists.quietMoves.len()] {
+		moves = append(moves, quiet.move)
+	}
+	buffer.moves = moves
+	return moves
+}
+
+func IsLegal(s *State, move Move) bool {
+	if !IsPseudoLegal(s, move) {
+		return false
+	}
+	for _, legalMove := range LegalMoves(s) {
+		if legalMove == move {
+			return true
+		}
+	}
+	return false
+}
+
+// True if the move follows the movement rules of the piece on its origin square, ignoring whether
+// it leaves the king in check. Castling still requires the rights, the rook and an empty path
+func IsPseudoLegal(s *State, move Move) bool {
+	if move == NilMove || move == PassingMove {
+		return false
+	}
+	friendIndex := s.turn * 6
+	origin := move.OriginSquare()
+	destination := move.DestinationSquare()
+	destinationBoard := boardFromSquare(destination)
+	piece := s.board.getColorPieceAt(origin, s.turn)
+	specialMove := move.SpecialMove()
+	if piece == NoPiece || (specialMove != PromotionSpecialMove && move.PromotionType() != 0) {
+		return false
+	}
+	// In Chess960 the king can castle onto its own rook or stay where it is
+	if specialMove == CastleSpecialMove {
+		return piece == friendIndex+King && s.isPseudoLegalCastle(destination)
+	}
+	if s.sideOccupied[s.turn]&destinationBoard != 0 {
+		return false
+	}
+	switch piece - friendIndex {
+	case Pawn:
+		return s.isPseudoLegalPawnMove(move)
+	case King:
+		return specialMove == 0 && moveBoards[King][origin]&destinationBoard != 0
+	case Knight:
+		return specialMove == 0 && moveBoards[Knight][origin]&destinationBoard != 0
+	case Bishop:
+		return specialMove == 0 && getBishopMoves(origin, s.occupied)&destinationBoard != 0
+	case Rook:
+		return specialMove == 0 && getRookMoves(origin, s.occupied)&destinationBoard != 0
+	case Queen:
+		return specialMove == 0 && getQueenMoves(origin, s.occupied)&destinationBoard != 0
+	}
+	return false
+}
+
+func (s *State) isPseudoLegalPawnMove(move Move) bool {
+	origin := move.OriginSquare()
+	destination := move.DestinationSquare()
+	destinationBoard := boardFromSquare(destination)
+	specialMove := move.SpecialMove()
+	var moveStep Step = UpStep
+	var homeRank int8 = 1
+	var promotionRank int8 = 7
+	if s.turn == Black {
+		moveStep = DownStep
+		homeRank = 6
+		promotionRank = 0
+	}
+	if specialMove == EnPassantSpacialMove {
+		return s.canEnpassant && destination == s.enPassantSquare && pawnAttackBoards[s.turn][origin]&destinationBoard != 0
+	}
+	if (specialMove == PromotionSpecialMove) != (destination.Rank() == promotionRank) {
+		return false
+	}
+	if pawnAttackBoards[s.turn][origin]&destinationBoard != 0 {
+		return s.sideOccupied[1-s.turn]&destinationBoard != 0
+	}
+	return GetPawnMoves(origin, s.occupied, moveStep, homeRank)&destinationBoard != 0
+}
+
+// The king is known to be on its castling square while it has the right to castle
+func (s *State) isPseudoLegalCastle(destination Square) bool {
+	rankIndex := Square(s.turn * 56)
+	castle := s.turn
+	if destination == rankIndex+2 {
+		castle += 2
+	} else if destination != rankIndex+6 {
+		return false
+	}
+	rookBoard := boardFromSquare(s.castleRookSquares[castle])
+	return !s.check && s.castleAvailability[castle] && s.occupied&s.castleEmptyBoards[castle] == 0 && s.board[s.turn*6+Rook]&rookBoard != 0
+}
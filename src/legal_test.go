@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// Every origin, destination, promotion and special move encoding is tried in each perft position,
+// and only the generated moves may pass
+func TestIsLegal(t *testing.T) {
+	for _, test := range perftCases {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legal := map[Move]bool{}
+		for _, move := range LegalMoves(s) {
+			legal[move] = true
+			if !IsPseudoLegal(s, move) || !IsLegal(s, move) {
+				t.Errorf("generated move %s in %s is not legal", move.ShortString(), test.fen)
+			}
+		}
+		for origin := Square(0); origin < 64; origin++ {
+			for destination := Square(0); destination < 64; destination++ {
+				for encoding := uint16(0); encoding < 16; encoding++ {
+					move := BuildMove(origin, destination, encoding&3, encoding>>2)
+					if legal[move] {
+						continue
+					}
+					if IsLegal(s, move) {
+						t.Errorf("%s (%016b) in %s is not generated but passed as legal", move.ShortString(), move, test.fen)
+					}
+					// Apart from castling through check, the only pseudo legal moves left leave the king in check
+					if move.SpecialMove() != CastleSpecialMove && IsPseudoLegal(s, move) {
+						turn := s.turn
+						s.MakeMove(move)
+						if !s.kingAttacked(turn) {
+							t.Errorf("%s (%016b) in %s passed as pseudo legal", move.ShortString(), move, test.fen)
+						}
+						s.UnMakeMove(move)
+					}
+				}
+			}
+		}
+	}
+	// A pinned piece follows its movement rules but may not leave the line of the pin
+	s, err := ParseFEN("4k3/4r3/8/8/8/8/4B3/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := SimpleMoveFromString("e2d3")
+	if !IsPseudoLegal(s, move) || IsLegal(s, move) {
+		t.Errorf("pinned bishop move e2d3 pseudo legal %v and legal %v, want true and false", IsPseudoLegal(s, move), IsLegal(s, move))
+	}
+}
+
+// One buffer is reused for every position and every position after one move
+func TestLegalMovesInto(t *testing.T) {
+	buffer := NewMoveBuffer()
+	for _, test := range perftCases {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legalMoves := LegalMoves(s)
+		if moves := LegalMovesInto(s, buffer); !slices.Equal(moves, legalMoves) {
+			t.Errorf("%s reused buffer gave %d moves, want %d", test.fen, len(moves), len(legalMoves))
+		}
+		for _, move := range legalMoves {
+			s.MakeMove(move)
+			if moves := LegalMovesInto(s, buffer); !slices.Equal(moves, LegalMoves(s)) {
+				t.Errorf("%s after %s reused buffer gave different moves", test.fen, move.ShortString())
+			}
+			s.UnMakeMove(move)
+		}
+	}
+}
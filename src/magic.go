@@ -4,7 +4,6 @@ import (
 	"math"
 	"math/bits"
 	"math/rand"
-	"sync"
 )
 
 type Magic struct {
@@ -14,84 +13,61 @@ type Magic struct {
 	offset      uint32
 }
 
-var bishopMagics [64]Magic = [64]Magic{}
-var rookMagics [64]Magic = [64]Magic{}
-
 var bishopTable [0x1480]Bitboard = [0x1480]Bitboard{}
 var rookTable [0x19000]Bitboard = [0x19000]Bitboard{}
 
+// The magic numbers, masks and offsets are precomputed in magic_data.go so only the attack
+// tables have to be filled at startup
 func InitializeMagics() {
-	var square Square
-	var runningBishopOffset uint32 = 0
-	var runningRookOffset uint32 = 0
-	wg := sync.WaitGroup{}
-	for square = 0; square < 64; square++ {
-		wg.Add(1)
-		go findMagicSquare(square, &wg, runningBishopOffset, runningRookOffset)
-		bishopMask := moveBoards[Bishop][square] & (^(Rank0 | Rank7) | ranks[square.Rank()]) & (^(File0 | File7) | files[square.File()])
-		bishopShift := math.Pow(2, float64(bits.OnesCount64(uint64(bishopMask))))
-		rookMask := moveBoards[Rook][square] & (^(Rank0 | Rank7) | ranks[square.Rank()]) & (^(File0 | File7) | files[square.File()])
-		rookShift := math.Pow(2, float64(bits.OnesCount64(uint64(rookMask))))
-		runningBishopOffset += uint32(bishopShift)
-		runningRookOffset += uint32(rookShift)
+	for square := Square(0); square < 64; square++ {
+		fillMagicTable(square, &bishopMagics[square], &bishopSteps, bishopTable[:])
+		fillMagicTable(square, &rookMagics[square], &rookSteps, rookTable[:])
 	}
-	wg.Wait()
 }
 
-func findMagicSquare(square Square, wg *sync.WaitGroup, bishopOffset uint32, rookOffset uint32) {
-	defer wg.Done()
-	bishopMagics[square] = Magic{0, 0, 0, 0}
-	rookMagics[square] = Magic{0, 0, 0, 0}
-	squareBishopTable := FindMagic(square, &moveBoards[Bishop], &bishopMagics[square], &bishopSteps)
-	bishopMagics[square].offset = bishopOffset
-	squareRookTable := FindMagic(square, &moveBoards[Rook], &rookMagics[square], &rookSteps)
-	rookMagics[square].offset = rookOffset
-	for i, board := range *squareBishopTable {
-		bishopTable[i+int(bishopOffset)] = board
-	}
-	for i, board := range *squareRookTable {
-		rookTable[i+int(rookOffset)] = board
+func fillMagicTable(square Square, magic *Magic, pieceSteps *[4]Step, table []Bitboard) {
+	subsetIterator := NewSubsetIterator(magic.mask)
+	for {
+		subset := subsetIterator.getSubset()
+		table[GetMagicIndex(magic, subset)] = FindBlockedSlidingAttack(square, pieceSteps, subset)
+		if subsetIterator.nextSubset() == 0 {
+			break
+		}
 	}
 }
 
-func FindMagic(s Square, attacks *[64]Bitboard, magic *Magic, pieceSteps *[4]Step) *[]Bitboard {
-	attackBoard := attacks[s]
-	mask := attackBoard & (^(Rank0 | Rank7) | ranks[s.Rank()]) & (^(File0 | File7) | files[s.File()])
-	magic.mask = mask
+// Edge squares never block anything further so they are left out of the mask
+func magicMask(s Square, attacks *[64]Bitboard) Bitboard {
+	return attacks[s] & (^(Rank0 | Rank7) | ranks[s.Rank()]) & (^(File0 | File7) | files[s.File()])
+}
+
+// Searches for a magic number that maps every subset of blockers to its own index. The offset is
+// left for the caller to fill in
+func FindMagic(s Square, attacks *[64]Bitboard, random *rand.Rand) Magic {
+	mask := magicMask(s, attacks)
 	bitcount := bits.OnesCount64(uint64(mask))
-	magic.index = uint8(bitcount)
+	magic := Magic{mask: mask, index: uint8(bitcount)}
 	tableSize := uint32(math.Pow(2, float64(bitcount)))
-	table := make([]Bitboard, tableSize)
-	moveTable := make([]Bitboard, tableSize)
-	subIter := NewSubsetIterator(mask)
-	for i := range tableSize {
-		moveTable[i] = FindBlockedSlidingAttack(s, pieceSteps, subIter.getSubset())
-		subIter.nextSubset()
-	}
+	foundTable := make([]bool, tableSize)
 	for {
-		magicNum := rand.Uint64() & rand.Uint64() & rand.Uint64()
-		magic.magicNumber = magicNum
-		foundTable := make([]bool, tableSize)
+		magic.magicNumber = random.Uint64() & random.Uint64() & random.Uint64()
+		clear(foundTable)
 		subsetIterator := NewSubsetIterator(mask)
 		goodTable := true
 		for i := 0; i < int(tableSize); i++ {
-			moves := moveTable[i]
-			tableIndex := GetMagicIndex(magic, subsetIterator.getSubset())
+			tableIndex := GetMagicIndex(&magic, subsetIterator.getSubset())
 			if foundTable[tableIndex] {
 				goodTable = false
 				break
-			} else {
-				table[tableIndex] = moves
-				foundTable[tableIndex] = true
 			}
+			foundTable[tableIndex] = true
 			if subsetIterator.nextSubset() == 0 {
 				break
 			}
 		}
 		if goodTable {
-			return &table
+			return magic
 		}
-
 	}
 }
 
@@ -0,0 +1,139 @@
+// Code generated by "ghobos generate"; DO NOT EDIT.
+
+package main
+
+// Mask, magic number, index bits and offset into the attack table
+var bishopMagics [64]Magic = [64]Magic{
+	{0x0040201008040200, 0x2002100A18010820, 6, 0x0},    // a1
+	{0x0000402010080400, 0x0008080860C24100, 5, 0x40},   // b1
+	{0x0000004020100A00, 0x2008160852026120, 5, 0x60},   // c1
+	{0x0000000040221400, 0x0002208A00810000, 5, 0x80},   // d1
+	{0x0000000002442800, 0x8004242000000130, 5, 0xA0},   // e1
+	{0x0000000204085000, 0x4002020220000408, 5, 0xC0},   // f1
+	{0x0000020408102000, 0x0007414808410120, 5, 0xE0},   // g1
+	{0x0002040810204000, 0x0420450088202220, 6, 0x100},  // h1
+	{0x0020100804020000, 0x2000302102008204, 5, 0x140},  // a2
+	{0x0040201008040000, 0x04000505040B0200, 5, 0x160},  // b2
+	{0x00004020100A0000, 0x8140104102012021, 5, 0x180},  // c2
+	{0x0000004022140000, 0x0003B40400800200, 5, 0x1A0},  // d2
+	{0x0000000244280000, 0x00000848400000A4, 5, 0x1C0},  // e2
+	{0x0000020408500000, 0x000010901048000A, 5, 0x1E0},  // f2
+	{0x0002040810200000, 0x808004040C442504, 5, 0x200},  // g2
+	{0x0004081020400000, 0x0010049058080400, 5, 0x220},  // h2
+	{0x0010080402000200, 0x09100041100201C0, 5, 0x240},  // a3
+	{0x0020100804000400, 0x00280D0410440440, 5, 0x260},  // b3
+	{0x004020100A000A00, 0x0210002AC1060020, 7, 0x280},  // c3
+	{0x0000402214001400, 0x1428002082810004, 7, 0x300},  // d3
+	{0x0000024428002800, 0x0010100202104080, 7, 0x380},  // e3
+	{0x0002040850005000, 0x0052000122012040, 7, 0x400},  // f3
+	{0x0004081020002000, 0x008040209C104822, 5, 0x480},  // g3
+	{0x0008102040004000, 0x0102000080818800, 5, 0x4A0},  // h3
+	{0x0008040200020400, 0x2C04040440900440, 5, 0x4C0},  // a4
+	{0x0010080400040800, 0x0110100002440300, 5, 0x4E0},  // b4
+	{0x0020100A000A1000, 0x0C00208084010400, 7, 0x500},  // c4
+	{0x0040221400142200, 0x0010040000401020, 9, 0x580},  // d4
+	{0x0002442800284400, 0x088100400C044010, 9, 0x780},  // e4
+	{0x0004085000500800, 0x50C2108004100081, 7, 0x980},  // f4
+	{0x0008102000201000, 0x4000840406020218, 5, 0xA00},  // g4
+	{0x0010204000402000, 0x0048451040410801, 5, 0xA20},  // h4
+	{0x0004020002040800, 0x0422100404102000, 5, 0xA40},  // a5
+	{0x0008040004081000, 0x284301480A701000, 5, 0xA60},  // b5
+	{0x00100A000A102000, 0x0240206800D00088, 7, 0xA80},  // c5
+	{0x0022140014224000, 0x80004E0080180480, 9, 0xB00},  // d5
+	{0x0044280028440200, 0x0420008400108220, 9, 0xD00},  // e5
+	{0x0008500050080400, 0x8804180580007001, 7, 0xF00},  // f5
+	{0x0010200020100800, 0x8290008088220608, 5, 0xF80},  // g5
+	{0x0020400040201000, 0x0201440420010100, 5, 0xFA0},  // h5
+	{0x0002000204081000, 0xC028080211080800, 5, 0xFC0},  // a6
+	{0x0004000408102000, 0x0184028E08001000, 5, 0xFE0},  // b6
+	{0x000A000A10204000, 0x249202020600C100, 7, 0x1000}, // c6
+	{0x0014001422400000, 0x0222002C24003800, 7, 0x1080}, // d6
+	{0x0028002844020000, 0x11080208A2010401, 7, 0x1100}, // e6
+	{0x0050005008040200, 0x8986140802001088, 7, 0x1180}, // f6
+	{0x0020002010080400, 0x04A2040702200402, 5, 0x1200}, // g6
+	{0x0040004020100800, 0x0944008182000100, 5, 0x1220}, // h6
+	{0x0000020408102000, 0x1000442404408009, 5, 0x1240}, // a7
+	{0x0000040810204000, 0x0085012802021401, 5, 0x1260}, // b7
+	{0x00000A1020400000, 0x0000A6026A080800, 5, 0x1280}, // c7
+	{0x0000142240000000, 0x02088401A0880008, 5, 0x12A0}, // d7
+	{0x0000284402000000, 0x0104924008A20000, 5, 0x12C0}, // e7
+	{0x0000500804020000, 0x031020081000C810, 5, 0x12E0}, // f7
+	{0x0000201008040200, 0x404005A800810990, 5, 0x1300}, // g7
+	{0x0000402010080400, 0x02080800A8860200, 5, 0x1320}, // h7
+	{0x0002040810204000, 0x800280280206A000, 6, 0x1340}, // a8
+	{0x0004081020400000, 0x01000908490C2010, 5, 0x1380}, // b8
+	{0x000A102040000000, 0x0000000201420809, 5, 0x13A0}, // c8
+	{0x0014224000000000, 0x8081041008628808, 5, 0x13C0}, // d8
+	{0x0028440200000000, 0x0001000230020200, 5, 0x13E0}, // e8
+	{0x0050080402000000, 0x8260802284102080, 5, 0x1400}, // f8
+	{0x0020100804020000, 0x0900201430008901, 5, 0x1420}, // g8
+	{0x0040201008040200, 0x40C4210404008200, 6, 0x1440}, // h8
+}
+
+// Mask, magic number, index bits and offset into the attack table
+var rookMagics [64]Magic = [64]Magic{
+	{0x000101010101017E, 0x4480008040002012, 12, 0x0},     // a1
+	{0x000202020202027C, 0x0040004610002000, 11, 0x1000},  // b1
+	{0x000404040404047A, 0x0080200280081000, 11, 0x1800},  // c1
+	{0x0008080808080876, 0x0100100100882084, 11, 0x2000},  // d1
+	{0x001010101010106E, 0x0200040200081020, 11, 0x2800},  // e1
+	{0x002020202020205E, 0x8200081002000104, 11, 0x3000},  // f1
+	{0x004040404040403E, 0x01001200008C0100, 11, 0x3800},  // g1
+	{0x008080808080807E, 0x810012008441A100, 12, 0x4000},  // h1
+	{0x0001010101017E00, 0x000180044002806A, 11, 0x5000},  // a2
+	{0x0002020202027C00, 0x0002002902008040, 10, 0x5800},  // b2
+	{0x0004040404047A00, 0x2812806002801000, 10, 0x5C00},  // c2
+	{0x0008080808087600, 0x1141001850010022, 10, 0x6000},  // d2
+	{0x0010101010106E00, 0x0202000488102201, 10, 0x6400},  // e2
+	{0x0020202020205E00, 0x080A000410020008, 10, 0x6800},  // f2
+	{0x0040404040403E00, 0x000200280C090A00, 10, 0x6C00},  // g2
+	{0x0080808080807E00, 0x09610001470002A2, 11, 0x7000},  // h2
+	{0x00010101017E0100, 0x4000208006401082, 11, 0x7800},  // a3
+	{0x00020202027C0200, 0x0444220042010188, 10, 0x8000},  // b3
+	{0x00040404047A0400, 0x0400808020081000, 10, 0x8400},  // c3
+	{0x0008080808760800, 0x1000808010001806, 10, 0x8800},  // d3
+	{0x00101010106E1000, 0x0001818004001802, 10, 0x8C00},  // e3
+	{0x00202020205E2000, 0x2611010004004812, 10, 0x9000},  // f3
+	{0x00404040403E4000, 0x001054000110080A, 10, 0x9400},  // g3
+	{0x00808080807E8000, 0x0000720000810044, 11, 0x9800},  // h3
+	{0x000101017E010100, 0x0110803080004001, 11, 0xA000},  // a4
+	{0x000202027C020200, 0x0800400180200480, 10, 0xA800},  // b4
+	{0x000404047A040400, 0x02100400A0002800, 10, 0xAC00},  // c4
+	{0x0008080876080800, 0x0060880080100084, 10, 0xB000},  // d4
+	{0x001010106E101000, 0x5084020480080080, 10, 0xB400},  // e4
+	{0x002020205E202000, 0xA8040004800A0080, 10, 0xB800},  // f4
+	{0x004040403E404000, 0x040A681400824510, 10, 0xBC00},  // g4
+	{0x008080807E808000, 0x1002130200028144, 11, 0xC000},  // h4
+	{0x0001017E01010100, 0x0880400220800080, 11, 0xC800},  // a5
+	{0x0002027C02020200, 0x3002200082804002, 10, 0xD000},  // b5
+	{0x0004047A04040400, 0x401050A001004104, 10, 0xD400},  // c5
+	{0x0008087608080800, 0x0200803000800802, 10, 0xD800},  // d5
+	{0x0010106E10101000, 0x0001001411005800, 10, 0xDC00},  // e5
+	{0x0020205E20202000, 0x1001460080800400, 10, 0xE000},  // f5
+	{0x0040403E40404000, 0x20000810440002A1, 10, 0xE400},  // g5
+	{0x0080807E80808000, 0x60D00080C2000403, 11, 0xE800},  // h5
+	{0x00017E0101010100, 0x0202804001248010, 11, 0xF000},  // a6
+	{0x00027C0202020200, 0x00022000D0004000, 10, 0xF800},  // b6
+	{0x00047A0404040400, 0x0000200100910040, 10, 0xFC00},  // c6
+	{0x0008760808080800, 0x2981001000090022, 10, 0x10000}, // d6
+	{0x00106E1010101000, 0x890A000D200A0010, 10, 0x10400}, // e6
+	{0x00205E2020202000, 0x00020004500A0008, 10, 0x10800}, // f6
+	{0x00403E4040404000, 0x000D080102040050, 10, 0x10C00}, // g6
+	{0x00807E8080808000, 0x8262A5450882000C, 11, 0x11000}, // h6
+	{0x007E010101010100, 0x80510A0124408200, 11, 0x11800}, // a7
+	{0x007C020202020200, 0x0000822009400080, 10, 0x12000}, // b7
+	{0x007A040404040400, 0x8010011080200080, 10, 0x12400}, // c7
+	{0x0076080808080800, 0x48A0201001000900, 10, 0x12800}, // d7
+	{0x006E101010101000, 0x0041000408001100, 10, 0x12C00}, // e7
+	{0x005E202020202000, 0x0190814400020080, 10, 0x13000}, // f7
+	{0x003E404040404000, 0x410D000200144100, 10, 0x13400}, // g7
+	{0x007E808080808000, 0x201100004A008100, 11, 0x13800}, // h7
+	{0x7E01010101010100, 0x0002010180401022, 12, 0x14000}, // a8
+	{0x7C02020202020200, 0x0000B100804006A1, 11, 0x15000}, // b8
+	{0x7A04040404040400, 0xE300600300100841, 11, 0x15800}, // c8
+	{0x7608080808080800, 0xA001006210000409, 11, 0x16000}, // d8
+	{0x6E10101010101000, 0x0011000410080003, 11, 0x16800}, // e8
+	{0x5E20202020202000, 0x9001000802040015, 11, 0x17000}, // f8
+	{0x3E40404040404000, 0x0200821008228504, 11, 0x17800}, // g8
+	{0x7E80808080808000, 0xA00001004180240E, 12, 0x18000}, // h8
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Time given to an engine on top of its clock before it is considered hung
+	matchHungMargin = 5 * time.Second
+	// Used for searches without a clock, eg fixed depth
+	matchMoveTimeout = 10 * time.Minute
+)
+
+type UCIOption struct {
+	name  string
+	value string
+}
+
+type MatchEngineConfig struct {
+	name    string
+	command string
+	args    []string
+	options []UCIOption
+}
+
+type MatchTimeControl struct {
+	base      time.Duration
+	increment time.Duration
+	moveTime  time.Duration
+	depth     int
+}
+
+// Scores are in centipawns. Zero moves disables a rule
+type MatchAdjudication struct {
+	resignScore int32
+	resignMoves int
+	drawScore   int32
+	drawMoves   int
+	drawAfter   int // Full moves before draw adjudication is considered
+	maxMoves    int // Full moves before the game is declared a draw
+}
+
+type SPRTConfig struct {
+	enabled bool
+	elo0    float64
+	elo1    float64
+	alpha   float64
+	beta    float64
+}
+
+type MatchSettings struct {
+	engines      [2]MatchEngineConfig
+	openings     []string
+	games        int
+	concurrency  int
+	timeControl  MatchTimeControl
+	timeMargin   time.Duration // How far an engine may go over its clock before it loses on time
+	adjudication MatchAdjudication
+	sprt         SPRTConfig
+	pgnPath      string
+}
+
+// Wins, losses and draws from the point of view of the first engine
+type MatchStats struct {
+	wins   int
+	losses int
+	draws  int
+}
+
+type MatchGameResult struct {
+	round      int
+	whiteIndex int // Which engine had the white pieces
+	result     string
+	reason     string
+	game       *PGNGame
+	err        error // Set when the match can not continue, eg an engine failed to start
+}
+
+// Parses the command line of "ghobos match" and plays the match
+func MatchCommand(args []string) error {
+	settings, err := parseMatchFlags(args)
+	if err != nil {
+		return err
+	}
+	return RunMatch(settings)
+}
+
+func parseMatchFlags(args []string) (*MatchSettings, error) {
+	flags := flag.NewFlagSet("match", flag.ContinueOnError)
+	var engineSpecs []string
+	flags.Func("engine", "engine as comma separated key=value pairs: cmd, name, arg (repeatable) and any UCI option, eg \"name=new,Hash=64\". Given twice, cmd defaults to this binary", func(spec string) error {
+		engineSpecs = append(engineSpecs, spec)
+		return nil
+	})
+	openingsPath := flags.String("openings", "", "file with one opening FEN or EPD per line, the start position is used if empty")
+	games := flags.Int("games", 100, "number of games, each opening is played twice with colors reversed")
+	concurrency := flags.Int("concurrency", 1, "number of games played at the same time")
+	timeControl := flags.String("tc", "10+0.1", "time control as base+increment in seconds")
+	moveTime := flags.Duration("movetime", 0, "fixed time per move instead of a clock (eg 100ms)")
+	depth := flags.Int("depth", 0, "fixed depth per move instead of a clock")
+	timeMargin := flags.Duration("timemargin", 100*time.Millisecond, "how far an engine may exceed its clock before losing on time")
+	resignScore := flags.Int("resignscore", 1000, "resign adjudication score in centipawns")
+	resignMoves := flags.Int("resignmoves", 3, "consecutive moves both engines must agree on the resign score, 0 disables")
+	drawScore := flags.Int("drawscore", 10, "draw adjudication score in centipawns")
+	drawMoves := flags.Int("drawmoves", 8, "consecutive moves both engines must be within the draw score, 0 disables")
+	drawAfter := flags.Int("drawafter", 40, "full moves played before draw adjudication starts")
+	maxMoves := flags.Int("maxmoves", 300, "full moves after which the game is a draw, 0 disables")
+	sprt := flags.Bool("sprt", false, "stop as soon as the SPRT is decided")
+	elo0 := flags.Float64("elo0", 0, "SPRT null hypothesis Elo")
+	elo1 := flags.Float64("elo1", 5, "SPRT alternative hypothesis Elo")
+	alpha := flags.Float64("alpha", 0.05, "SPRT false positive rate")
+	beta := flags.Float64("beta", 0.05, "SPRT false negative rate")
+	pgnPath := flags.String("pgn", "", "file the games are appended to as PGN")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if len(engineSpecs) != 2 {
+		return nil, fmt.Errorf("exactly two -engine flags are required, found %d", len(engineSpecs))
+	}
+	settings := &MatchSettings{
+		games:       *games,
+		concurrency: max(*concurrency, 1),
+		timeControl: MatchTimeControl{moveTime: *moveTime, depth: *depth},
+		timeMargin:  *timeMargin,
+		adjudication: MatchAdjudication{
+			resignScore: int32(*resignScore),
+			resignMoves: *resignMoves,
+			drawScore:   int32(*drawScore),
+			drawMoves:   *drawMoves,
+			drawAfter:   *drawAfter,
+			maxMoves:    *maxMoves,
+		},
+		sprt:    SPRTConfig{enabled: *sprt, elo0: *elo0, elo1: *elo1, alpha: *alpha, beta: *beta},
+		pgnPath: *pgnPath,
+	}
+	for i, spec := range engineSpecs {
+		engine, err := parseMatchEngine(spec)
+		if err != nil {
+			return nil, err
+		}
+		settings.engines[i] = engine
+	}
+	if settings.engines[0].name == settings.engines[1].name {
+		settings.engines[0].name += "-1"
+		settings.engines[1].name += "-2"
+	}
+	if *moveTime == 0 && *depth == 0 {
+		base, increment, err := parseTimeControl(*timeControl)
+		if err != nil {
+			return nil, err
+		}
+		settings.timeControl.base = base
+		settings.timeControl.increment = increment
+	}
+	settings.openings = []string{startingFenString}
+	if *openingsPath != "" {
+		openings, err := readOpenings(*openingsPath)
+		if err != nil {
+			return nil, err
+		}
+		settings.openings = openings
+	}
+	return settings, nil
+}
+
+func parseMatchEngine(spec string) (MatchEngineConfig, error) {
+	engine := MatchEngineConfig{}
+	for _, pair := range strings.Split(spec, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return engine, fmt.Errorf("invalid engine setting %q in %q, expected key=value", pair, spec)
+		}
+		switch key {
+		case "cmd":
+			engine.command = value
+		case "name":
+			engine.name = value
+		case "arg":
+			engine.args = append(engine.args, value)
+		default:
+			engine.options = append(engine.options, UCIOption{key, value})
+		}
+	}
+	if engine.command == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return engine, err
+		}
+		engine.command = executable
+	}
+	if engine.name == "" {
+		engine.name = filepath.Base(engine.command)
+	}
+	return engine, nil
+}
+
+// Format is base+increment in seconds, eg 10+0.1 or 60
+func parseTimeControl(timeControl string) (time.Duration, time.Duration, error) {
+	baseString, incrementString, hasIncrement := strings.Cut(timeControl, "+")
+	base, err := strconv.ParseFloat(baseString, 64)
+	if err != nil || base <= 0 {
+		return 0, 0, fmt.Errorf("invalid time control %q", timeControl)
+	}
+	increment := 0.0
+	if hasIncrement {
+		increment, err = strconv.ParseFloat(incrementString, 64)
+		if err != nil || increment < 0 {
+			return 0, 0, fmt.Errorf("invalid time control %q", timeControl)
+		}
+	}
+	return time.Duration(base * float64(time.Second)), time.Duration(increment * float64(time.Second)), nil
+}
+
+// Lines may be full FENs or EPD lines, whose operations are ignored. Empty lines and lines
+// starting with # are skipped
+func readOpenings(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	openings := []string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		fen := strings.Join(fields[:min(len(fields), 6)], " ")
+		if _, err := ParseFEN(fen); err != nil {
+			fen = strings.Join(fields[:min(len(fields), 4)], " ")
+			if _, err := ParseFEN(fen); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+			}
+		}
+		openings = append(openings, fen)
+	}
+	if len(openings) == 0 {
+		return nil, fmt.Errorf("%s contains no openings", path)
+	}
+	return openings, nil
+}
+
+func RunMatch(settings *MatchSettings) error {
+	var pgnFile *os.File
+	if settings.pgnPath != "" {
+		var err error
+		pgnFile, err = os.OpenFile(settings.pgnPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer pgnFile.Close()
+	}
+	fmt.Printf("Match %s vs %s, %d games\n", settings.engines[0].name, settings.engines[1].name, settings.games)
+	jobs := make(chan int, settings.games)
+	for round := range settings.games {
+		jobs <- round
+	}
+	close(jobs)
+	results := make(chan MatchGameResult)
+	var stop atomic.Bool
+	workers := sync.WaitGroup{}
+	for range settings.concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			matchWorker(settings, jobs, results, &stop)
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+	stats := MatchStats{}
+	var matchErr error
+	for result := range results {
+		if result.err != nil {
+			stop.Store(true)
+			matchErr = errors.Join(matchErr, result.err)
+			continue
+		}
+		stats.add(result)
+		white, black := settings.engines[result.whiteIndex].name, settings.engines[1-result.whiteIndex].name
+		fmt.Printf("Game %d (%s vs %s): %s {%s}\n", result.round+1, white, black, result.result, result.reason)
+		fmt.Println(stats.summary(settings))
+		if pgnFile != nil {
+			if err := result.game.Write(pgnFile); err != nil {
+				fmt.Println("Error writing PGN:", err)
+			}
+		}
+		if settings.sprt.enabled && !stop.Load() {
+			llr := stats.llr(settings.sprt)
+			lower, upper := settings.sprt.bounds()
+			if llr >= upper {
+				fmt.Printf("SPRT: H1 accepted, %s is at least %.1f Elo stronger\n", settings.engines[0].name, settings.sprt.elo1)
+				stop.Store(true)
+			} else if llr <= lower {
+				fmt.Printf("SPRT: H0 accepted, %s is not %.1f Elo stronger\n", settings.engines[0].name, settings.sprt.elo1)
+				stop.Store(true)
+			}
+		}
+	}
+	fmt.Println("Finished match:", stats.summary(settings))
+	return matchErr
+}
+
+// Plays games from the job queue with its own pair of engine processes
+func matchWorker(settings *MatchSettings, jobs <-chan int, results chan<- MatchGameResult, stop *atomic.Bool) {
+	clients := [2]*UCIClient{}
+	defer func() {
+		for _, client := range clients {
+			if client != nil {
+				client.Quit()
+			}
+		}
+	}()
+	for round := range jobs {
+		if stop.Load() {
+			continue
+		}
+		for i := range clients {
+			if clients[i] != nil {
+				continue
+			}
+			engine := settings.engines[i]
+			client, err := StartUCIClient(engine.name, engine.command, engine.args, engine.options)
+			if err != nil {
+				results <- MatchGameResult{err: err}
+				return
+			}
+			clients[i] = client
+		}
+		result, failed := playMatchGame(settings, clients, round)
+		// An engine that crashed or hung is replaced so it can not affect the next game
+		for i, engineFailed := range failed {
+			if engineFailed {
+				clients[i].Quit()
+				clients[i] = nil
+			}
+		}
+		results <- result
+	}
+}
+
+// Returns the result and which engines failed during the game
+func playMatchGame(settings *MatchSettings, clients [2]*UCIClient, round int) (MatchGameResult, [2]bool) {
+	failed := [2]bool{}
+	opening := settings.openings[(round/2)%len(settings.openings)]
+	whiteIndex := round % 2
+	result := MatchGameResult{round: round, whiteIndex: whiteIndex}
+	game := NewPGNGame()
+	game.SetTag("Event", EngineName+" match")
+	game.SetTag("Date", time.Now().Format("2006.01.02"))
+	game.SetTag("Round", strconv.Itoa(round+1))
+	game.SetTag("White", settings.engines[whiteIndex].name)
+	game.SetTag("Black", settings.engines[1-whiteIndex].name)
+	if opening != startingFenString {
+		game.SetStartFen(opening)
+	}
+	timeControl := settings.timeControl
+	if timeControl.base != 0 {
+		game.SetTag("TimeControl", fmt.Sprintf("%g+%g", timeControl.base.Seconds(), timeControl.increment.Seconds()))
+	}
+	result.game = game
+	finish := func(gameResult string, reason string) (MatchGameResult, [2]bool) {
+		result.result = gameResult
+		result.reason = reason
+		game.SetResult(gameResult)
+		if len(game.moves) > 0 {
+			game.moves[len(game.moves)-1].comment = reason
+		} else {
+			game.startComment = reason
+		}
+		return result, failed
+	}
+	// Both sides are indexed by color from here on
+	engineIndices := [2]int{whiteIndex, 1 - whiteIndex}
+	for color, engineIndex := range engineIndices {
+		if err := clients[engineIndex].NewGame(); err != nil {
+			failed[engineIndex] = true
+			return finish(lossFor(uint8(color)), err.Error())
+		}
+	}
+	state, _ := ParseFEN(opening)
+	clocks := [2]time.Duration{timeControl.base, timeControl.base}
+	moveStrings := []string{}
+	adjudicator := newMatchAdjudicator(settings.adjudication)
+	colorNames := [2]string{"White", "Black"}
+	for {
+		if gameResult, reason, over := state.gameResult(); over {
+			return finish(gameResult, reason)
+		}
+		if insufficientMaterial(state) {
+			return finish("1/2-1/2", "Insufficient material")
+		}
+		if gameResult, reason, over := adjudicator.maxMovesReached(state); over {
+			return finish(gameResult, reason)
+		}
+		turn := state.turn
+		engineIndex := engineIndices[turn]
+		client := clients[engineIndex]
+		position := "position fen " + opening
+		if len(moveStrings) > 0 {
+			position += " moves " + strings.Join(moveStrings, " ")
+		}
+		timeout := matchMoveTimeout
+		if timeControl.base != 0 {
+			timeout = clocks[turn] + settings.timeMargin + matchHungMargin
+		} else if timeControl.moveTime != 0 {
+			timeout = timeControl.moveTime + matchHungMargin
+		}
+		engineMove, err := client.Go(position, timeControl.goCommand(clocks), timeout)
+		if err != nil {
+			failed[engineIndex] = true
+			return finish(lossFor(turn), fmt.Sprintf("%s %v", colorNames[turn], err))
+		}
+		if timeControl.base != 0 {
+			clocks[turn] -= engineMove.elapsed
+			if clocks[turn] < -settings.timeMargin {
+				return finish(lossFor(turn), colorNames[turn]+" loses on time")
+			}
+			clocks[turn] += timeControl.increment
+		}
+		move, ok := state.moveFromShortString(engineMove.move)
+		if !ok {
+			return finish(lossFor(turn), fmt.Sprintf("%s makes an illegal move: %s", colorNames[turn], engineMove.move))
+		}
+		// The move is recorded before adjudicating so the PGN ends with it
+		game.AddMove(move)
+		if gameResult, reason, over := adjudicator.update(state, engineMove); over {
+			return finish(gameResult, reason)
+		}
+		state.MakeMove(move)
+		moveStrings = append(moveStrings, engineMove.move)
+	}
+}
+
+func lossFor(side uint8) string {
+	if side == White {
+		return "0-1"
+	}
+	return "1-0"
+}
+
+func (timeControl *MatchTimeControl) goCommand(clocks [2]time.Duration) string {
+	if timeControl.moveTime != 0 {
+		return fmt.Sprintf("go movetime %d", timeControl.moveTime.Milliseconds())
+	} else if timeControl.depth != 0 {
+		return fmt.Sprintf("go depth %d", timeControl.depth)
+	}
+	return fmt.Sprintf("go wtime %d btime %d winc %d binc %d", max(clocks[White].Milliseconds(), 1), max(clocks[Black].Milliseconds(), 1),
+		timeControl.increment.Milliseconds(), timeControl.increment.Milliseconds())
+}
+
+// Only positions where neither side can possibly mate, a lone king against at most one minor piece
+func insufficientMaterial(s *State) bool {
+	heavyPieces := s.board[WhitePawn] | s.board[BlackPawn] | s.board[WhiteRook] | s.board[BlackRook] | s.board[WhiteQueen] | s.board[BlackQueen]
+	minorPieces := s.board[WhiteBishop] | s.board[BlackBishop] | s.board[WhiteKnight] | s.board[BlackKnight]
+	return heavyPieces == 0 && BitCount(minorPieces) <= 1
+}
+
+// Tracks how many moves in a row each side has reported a score past the adjudication limits
+type MatchAdjudicator struct {
+	settings      MatchAdjudication
+	losingStreak  [2]int
+	winningStreak [2]int
+	drawStreak    [2]int
+}
+
+func newMatchAdjudicator(settings MatchAdjudication) *MatchAdjudicator {
+	return &MatchAdjudicator{settings: settings}
+}
+
+func (adjudicator *MatchAdjudicator) maxMovesReached(s *State) (string, string, bool) {
+	if adjudicator.settings.maxMoves != 0 && int(s.ply/2) >= adjudicator.settings.maxMoves {
+		return "1/2-1/2", "Draw by adjudication, maximum game length", true
+	}
+	return "", "", false
+}
+
+// Called with the move an engine is about to play in the state
+func (adjudicator *MatchAdjudicator) update(s *State, engineMove UCIClientMove) (string, string, bool) {
+	settings := &adjudicator.settings
+	turn := s.turn
+	if !engineMove.hasScore {
+		adjudicator.losingStreak[turn] = 0
+		adjudicator.winningStreak[turn] = 0
+		adjudicator.drawStreak[turn] = 0
+		return "", "", false
+	}
+	adjudicator.losingStreak[turn] = streak(adjudicator.losingStreak[turn], engineMove.score <= -settings.resignScore)
+	adjudicator.winningStreak[turn] = streak(adjudicator.winningStreak[turn], engineMove.score >= settings.resignScore)
+	adjudicator.drawStreak[turn] = streak(adjudicator.drawStreak[turn], engineMove.score >= -settings.drawScore && engineMove.score <= settings.drawScore)
+	if settings.resignMoves != 0 {
+		for side := uint8(White); side <= Black; side++ {
+			if adjudicator.losingStreak[side] >= settings.resignMoves && adjudicator.winningStreak[1-side] >= settings.resignMoves {
+				return lossFor(side), "Win by adjudication, both engines agree on the score", true
+			}
+		}
+	}
+	if settings.drawMoves != 0 && int(s.ply/2) >= settings.drawAfter &&
+		adjudicator.drawStreak[White] >= settings.drawMoves && adjudicator.drawStreak[Black] >= settings.drawMoves {
+		return "1/2-1/2", "Draw by adjudication, both engines agree on the score", true
+	}
+	return "", "", false
+}
+
+func streak(current int, condition bool) int {
+	if condition {
+		return current + 1
+	}
+	return 0
+}
+
+func (stats *MatchStats) add(result MatchGameResult) {
+	firstEngineWhite := result.whiteIndex == 0
+	switch {
+	case result.result == "1/2-1/2":
+		stats.draws++
+	case (result.result == "1-0") == firstEngineWhite:
+		stats.wins++
+	default:
+		stats.losses++
+	}
+}
+
+func (stats *MatchStats) games() int {
+	return stats.wins + stats.losses + stats.draws
+}
+
+func (stats *MatchStats) score() float64 {
+	return (float64(stats.wins) + float64(stats.draws)/2) / float64(stats.games())
+}
+
+// Variance of the score of a single game
+func (stats *MatchStats) variance() float64 {
+	score := stats.score()
+	games := float64(stats.games())
+	return (float64(stats.wins)*math.Pow(1-score, 2) + float64(stats.losses)*math.Pow(score, 2) + float64(stats.draws)*math.Pow(0.5-score, 2)) / games
+}
+
+func scoreToElo(score float64) float64 {
+	score = min(max(score, 1e-6), 1-1e-6)
+	return 400 * math.Log10(score/(1-score))
+}
+
+func eloToScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// Elo difference and its 95% confidence margin
+func (stats *MatchStats) elo() (float64, float64) {
+	score := stats.score()
+	standardError := math.Sqrt(stats.variance() / float64(stats.games()))
+	low := scoreToElo(score - 1.959964*standardError)
+	high := scoreToElo(score + 1.959964*standardError)
+	return scoreToElo(score), (high - low) / 2
+}
+
+// Log likelihood ratio of the generalized SPRT using the normal approximation of the score
+func (stats *MatchStats) llr(sprt SPRTConfig) float64 {
+	variance := stats.variance()
+	if stats.games() == 0 || variance == 0 {
+		return 0
+	}
+	score0 := eloToScore(sprt.elo0)
+	score1 := eloToScore(sprt.elo1)
+	return float64(stats.games()) * (score1 - score0) * (2*stats.score() - score0 - score1) / (2 * variance)
+}
+
+func (sprt *SPRTConfig) bounds() (float64, float64) {
+	return math.Log(sprt.beta / (1 - sprt.alpha)), math.Log((1 - sprt.beta) / sprt.alpha)
+}
+
+func (stats *MatchStats) summary(settings *MatchSettings) string {
+	if stats.games() == 0 {
+		return "no games played"
+	}
+	elo, margin := stats.elo()
+	summary := fmt.Sprintf("Score of %s vs %s: %d - %d - %d [%.3f] %d, Elo: %.1f +/- %.1f", settings.engines[0].name, settings.engines[1].name,
+		stats.wins, stats.losses, stats.draws, stats.score(), stats.games(), elo, margin)
+	if settings.sprt.enabled {
+		lower, upper := settings.sprt.bounds()
+		summary += fmt.Sprintf(", LLR: %.2f (%.2f, %.2f)", stats.llr(settings.sprt), lower, upper)
+	}
+	return summary
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Expected values follow the formulas cutechess uses for the Elo margin and fishtest used for the
+// trinomial SPRT, worked out separately from this code
+func TestMatchStats(t *testing.T) {
+	eloTests := []struct {
+		stats  MatchStats
+		elo    float64
+		margin float64
+	}{
+		{MatchStats{wins: 60, losses: 40}, 70.44, 70.57},
+		{MatchStats{wins: 30, losses: 30, draws: 40}, 0, 53.16},
+		{MatchStats{wins: 120, losses: 80, draws: 300}, 27.85, 19.25},
+		{MatchStats{wins: 10, losses: 20, draws: 30}, -58.45, 62.87},
+	}
+	for _, test := range eloTests {
+		elo, margin := test.stats.elo()
+		if math.Abs(elo-test.elo) > 0.01 || math.Abs(margin-test.margin) > 0.01 {
+			t.Errorf("%+v has Elo %.2f +/- %.2f, want %.2f +/- %.2f", test.stats, elo, margin, test.elo, test.margin)
+		}
+	}
+	sprt := SPRTConfig{enabled: true, elo0: 0, elo1: 5, alpha: 0.05, beta: 0.05}
+	llrTests := []struct {
+		stats MatchStats
+		llr   float64
+	}{
+		{MatchStats{wins: 300, losses: 250, draws: 450}, 1.125},
+		{MatchStats{wins: 250, losses: 300, draws: 450}, -1.503},
+		{MatchStats{wins: 1200, losses: 1100, draws: 2700}, 2.005},
+		{MatchStats{wins: 500, losses: 500, draws: 1000}, -0.414},
+		{MatchStats{draws: 10}, 0}, // No variance
+		{MatchStats{}, 0},
+	}
+	for _, test := range llrTests {
+		if llr := test.stats.llr(sprt); math.Abs(llr-test.llr) > 0.001 {
+			t.Errorf("%+v has LLR %.3f, want %.3f", test.stats, llr, test.llr)
+		}
+	}
+	if lower, upper := sprt.bounds(); math.Abs(lower+2.944) > 0.001 || math.Abs(upper-2.944) > 0.001 {
+		t.Errorf("SPRT bounds (%.3f, %.3f), want (-2.944, 2.944)", lower, upper)
+	}
+}
+
+func TestMatchAdjudicator(t *testing.T) {
+	const noScore = math.MaxInt32
+	resign := MatchAdjudication{resignScore: 600, resignMoves: 3}
+	draw := MatchAdjudication{drawScore: 10, drawMoves: 2, drawAfter: 20}
+	tests := []struct {
+		name     string
+		settings MatchAdjudication
+		startPly uint16
+		scores   []int32 // Alternating from white, relative to the side to move
+		over     int     // Index of the score that ends the game, -1 if it goes on
+		result   string
+	}{
+		{"white wins", resign, 0, []int32{700, -700, 650, -800, 600, -600}, 5, "1-0"},
+		{"black wins", resign, 0, []int32{-600, 600, -700, 700, -900, 900}, 5, "0-1"},
+		{"losing streak broken", resign, 0, []int32{700, -700, 700, -500, 700, -700, 700, -700}, -1, ""},
+		{"missing score", resign, 0, []int32{700, -700, noScore, -700, 700, -700}, -1, ""},
+		{"winner not sure", resign, 0, []int32{500, -700, 500, -700, 500, -700}, -1, ""},
+		{"resigning off", MatchAdjudication{resignScore: 600}, 0, []int32{700, -700, 700, -700, 700, -700}, -1, ""},
+		{"draw", draw, 40, []int32{5, -10, 0, 10}, 3, "1/2-1/2"},
+		{"draw streak broken", draw, 40, []int32{5, -10, 11, 10, 0}, -1, ""},
+		{"draw too early", draw, 30, []int32{5, -10, 0, 10}, -1, ""},
+		{"draw once early moves are played", draw, 36, []int32{0, 0, 0, 0, 0, 0}, 4, "1/2-1/2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adjudicator := newMatchAdjudicator(test.settings)
+			s := StartingFen()
+			for i, score := range test.scores {
+				s.ply = test.startPly + uint16(i)
+				s.turn = uint8(i % 2)
+				engineMove := UCIClientMove{move: "0000", score: score, hasScore: score != noScore}
+				result, _, over := adjudicator.update(s, engineMove)
+				if over != (i == test.over) || result != test.result && over {
+					t.Fatalf("score %d gave %q (%v), want the game over at score %d with %q", i, result, over, test.over, test.result)
+				}
+				if over {
+					return
+				}
+			}
+			if test.over != -1 {
+				t.Errorf("not adjudicated, want %q at score %d", test.result, test.over)
+			}
+		})
+	}
+}
@@ -26,6 +26,8 @@ const (
 	BishopPromotion = 3
 )
 
+var promotionRunes [4]rune = [4]rune{'q', 'r', 'n', 'b'}
+
 func (m Move) OriginSquare() Square {
 	return Square(m & Move(BitMask6))
 }
@@ -66,6 +68,10 @@ func (m Move) String() string {
 	return result
 }
 
+// Coordinate notation as used by UCI (eg e2e4, e7e8q)
 func (m Move) ShortString() string {
+	if m.SpecialMove() == PromotionSpecialMove {
+		return m.OriginSquare().String() + m.DestinationSquare().String() + string(promotionRunes[m.PromotionType()])
+	}
 	return m.OriginSquare().String() + m.DestinationSquare().String()
 }
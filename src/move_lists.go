@@ -2,6 +2,12 @@ package main
 
 import "sort"
 
+const (
+	// Large enough for the most moves possible in a legal position (218)
+	quietMoveListSize   = 256
+	captureMoveListSize = 128
+)
+
 type QuietMoveList struct {
 	slice      []QuietMove
 	firstEmpty uint16
@@ -12,6 +18,12 @@ type CaptureMoveList struct {
 	firstEmpty uint16
 }
 
+// Filled by genAllMoves
+type MoveLists struct {
+	quietMoves   QuietMoveList
+	captureMoves CaptureMoveList
+}
+
 func newQuietMoveList(size uint16) QuietMoveList {
 	return QuietMoveList{make([]QuietMove, size), 0}
 }
@@ -20,6 +32,10 @@ func newCaptureMoveList(size uint16) CaptureMoveList {
 	return CaptureMoveList{make([]CaptureMove, size), 0}
 }
 
+func newMoveLists() MoveLists {
+	return MoveLists{newQuietMoveList(quietMoveListSize), newCaptureMoveList(captureMoveListSize)}
+}
+
 func (moveList *QuietMoveList) size() uint16 {
 	return uint16(len(moveList.slice))
 }
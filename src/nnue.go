@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Network files are little endian:
+//
+//	magic           4 bytes "GHNN"
+//	version         uint32, NNUEVersion
+//	feature set     uint32, 1 for HalfKA
+//	hidden size     uint32, N
+//	output scale    int32, centipawns per unit of the dequantised output
+//	feature weights int16 [nnueInputs][N], quantised by nnueQA
+//	feature biases  int16 [N], quantised by nnueQA
+//	output weights  int16 [2][N], side to move half first, quantised by nnueQB
+//	output bias     int32, quantised by nnueQA*nnueQB
+//
+// A HalfKA feature is (king square * 12 + piece) * 64 + square from one side's point of view.
+// Black's view flips the board vertically and swaps the colours so pieces 0-5 are always the
+// viewing side's own, in the same order as the board
+const (
+	nnueMagic            = "GHNN"
+	NNUEVersion   uint32 = 1
+	nnueHalfKA    uint32 = 1
+	nnueInputs           = 64 * 12 * 64
+	nnueMaxHidden        = 4096
+	nnueQA               = 255
+	nnueQB               = 64
+	nnueMaxEval          = 10000 // Centipawns, kept well below mate scores
+)
+
+type Network struct {
+	path           string
+	hidden         int
+	outputScale    int32
+	featureWeights []int16
+	featureBiases  []int16
+	outputWeights  []int16
+	outputBias     int32
+}
+
+// Nil when no network is loaded. The handcrafted evaluation is used unless useNNUE is also set
+var evalNetwork *Network
+var useNNUE bool
+
+// Accumulated hidden layer before activation for both perspectives
+type Accumulator struct {
+	values [2][]int16
+	kings  [2]Square
+	valid  bool
+}
+
+// One accumulator per ply made since the stack was created. Popping below the first entry marks
+// it invalid so the position is refreshed from the board the next time it is evaluated
+type AccumulatorStack struct {
+	network *Network
+	entries []Accumulator
+	top     int
+}
+
+func LoadNetwork(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	network, err := ReadNetwork(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	network.path = path
+	return network, nil
+}
+
+func ReadNetwork(reader io.Reader) (*Network, error) {
+	var header struct {
+		Magic       [4]byte
+		Version     uint32
+		FeatureSet  uint32
+		Hidden      uint32
+		OutputScale int32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, errors.New("not a network file, the header is truncated")
+	}
+	if string(header.Magic[:]) != nnueMagic {
+		return nil, errors.New("not a network file, the magic is wrong")
+	}
+	if header.Version != NNUEVersion {
+		return nil, fmt.Errorf("network version %d is not supported, expected %d", header.Version, NNUEVersion)
+	}
+	if header.FeatureSet != nnueHalfKA {
+		return nil, fmt.Errorf("feature set %d is not supported", header.FeatureSet)
+	}
+	if header.Hidden == 0 || header.Hidden > nnueMaxHidden {
+		return nil, fmt.Errorf("hidden size %d is not between 1 and %d", header.Hidden, nnueMaxHidden)
+	}
+	hidden := int(header.Hidden)
+	network := &Network{
+		hidden:         hidden,
+		outputScale:    header.OutputScale,
+		featureWeights: make([]int16, nnueInputs*hidden),
+		featureBiases:  make([]int16, hidden),
+		outputWeights:  make([]int16, 2*hidden),
+	}
+	for _, data := range []any{network.featureWeights, network.featureBiases, network.outputWeights, &network.outputBias} {
+		if err := binary.Read(reader, binary.LittleEndian, data); err != nil {
+			return nil, errors.New("network file is truncated")
+		}
+	}
+	if _, err := reader.Read(make([]byte, 1)); err != io.EOF {
+		return nil, errors.New("network file is longer than its header says")
+	}
+	return network, nil
+}
+
+// Writes the network in the format read by ReadNetwork
+func (network *Network) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	writer.WriteString(nnueMagic)
+	for _, data := range []any{NNUEVersion, nnueHalfKA, uint32(network.hidden), network.outputScale,
+		network.featureWeights, network.featureBiases, network.outputWeights, network.outputBias} {
+		if err := binary.Write(writer, binary.LittleEndian, data); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// An empty path unloads the current network
+func SetEvalFile(path string) error {
+	if path == "" {
+		evalNetwork = nil
+		return nil
+	}
+	network, err := LoadNetwork(path)
+	if err != nil {
+		return err
+	}
+	evalNetwork = network
+	return nil
+}
+
+func nnueFeature(perspective uint8, king Square, piece int, square Square) int {
+	if perspective == Black {
+		king ^= 56
+		square ^= 56
+		piece = (piece + 6) % 12
+	}
+	return (int(king)*12+piece)*64 + int(square)
+}
+
+func newAccumulatorStack(network *Network) *AccumulatorStack {
+	return &AccumulatorStack{network: network, entries: []Accumulator{newAccumulator(network)}}
+}
+
+func newAccumulator(network *Network) Accumulator {
+	return Accumulator{values: [2][]int16{make([]int16, network.hidden), make([]int16, network.hidden)}}
+}
+
+// Recomputes one perspective from the pieces on the board
+func (accumulator *Accumulator) refresh(s *State, network *Network, perspective uint8) {
+	values := accumulator.values[perspective]
+	copy(values, network.featureBiases)
+	kingBoard := s.board[perspective*6+King]
+	king := PopLSB(&kingBoard)
+	accumulator.kings[perspective] = king
+	for piece := 0; piece < 12; piece++ {
+		pieceBoard := s.board[piece]
+		for pieceBoard != 0 {
+			square := PopLSB(&pieceBoard)
+			weights := network.featureWeights[nnueFeature(perspective, king, piece, square)*network.hidden:]
+			for i := range values {
+				values[i] += weights[i]
+			}
+		}
+	}
+}
+
+// Nil safe so MakeMove does not have to check whether the state is evaluated by a network
+func (stack *AccumulatorStack) push() {
+	if stack == nil {
+		return
+	}
+	stack.top++
+	if stack.top == len(stack.entries) {
+		stack.entries = append(stack.entries, newAccumulator(stack.network))
+	}
+	parent := &stack.entries[stack.top-1]
+	current := &stack.entries[stack.top]
+	current.valid = parent.valid
+	if parent.valid {
+		copy(current.values[White], parent.values[White])
+		copy(current.values[Black], parent.values[Black])
+		current.kings = parent.kings
+	}
+}
+
+func (stack *AccumulatorStack) pop() {
+	if stack == nil {
+		return
+	}
+	if stack.top == 0 {
+		stack.entries[0].valid = false
+		return
+	}
+	stack.top--
+}
+
+func (stack *AccumulatorStack) addPiece(piece int, square Square) {
+	stack.updatePiece(piece, square, 1)
+}
+
+func (stack *AccumulatorStack) removePiece(piece int, square Square) {
+	stack.updatePiece(piece, square, -1)
+}
+
+func (stack *AccumulatorStack) updatePiece(piece int, square Square, sign int16) {
+	if stack == nil || !stack.entries[stack.top].valid {
+		return
+	}
+	network := stack.network
+	current := &stack.entries[stack.top]
+	for perspective := uint8(0); perspective < 2; perspective++ {
+		values := current.values[perspective]
+		weights := network.featureWeights[nnueFeature(perspective, current.kings[perspective], piece, square)*network.hidden:]
+		for i := range values {
+			values[i] += sign * weights[i]
+		}
+	}
+}
+
+// Every feature of a side depends on its king so a king move refreshes that side
+func (stack *AccumulatorStack) kingMoved(s *State, perspective uint8) {
+	if stack == nil || !stack.entries[stack.top].valid {
+		return
+	}
+	stack.entries[stack.top].refresh(s, stack.network, perspective)
+}
+
+func (stack *AccumulatorStack) current(s *State) *Accumulator {
+	current := &stack.entries[stack.top]
+	if !current.valid {
+		current.refresh(s, stack.network, White)
+		current.refresh(s, stack.network, Black)
+		current.valid = true
+	}
+	return current
+}
+
+// A copy only needs the accumulator of the current position
+func (stack *AccumulatorStack) copyTop() *AccumulatorStack {
+	if stack == nil {
+		return nil
+	}
+	copied := newAccumulatorStack(stack.network)
+	current := &stack.entries[stack.top]
+	if current.valid {
+		copy(copied.entries[0].values[White], current.values[White])
+		copy(copied.entries[0].values[Black], current.values[Black])
+		copied.entries[0].kings = current.kings
+		copied.entries[0].valid = true
+	}
+	return copied
+}
+
+func nnueEnabled() bool {
+	return useNNUE && evalNetwork != nil
+}
+
+// Network evaluation in the same units as the handcrafted one. The accumulators are created the
+// first time a state is evaluated and are replaced when a different network is loaded
+func (s *State) nnueEval(perspective uint8) int32 {
+	network := evalNetwork
+	if s.accumulators == nil || s.accumulators.network != network {
+		s.accumulators = newAccumulatorStack(network)
+	}
+	accumulator := s.accumulators.current(s)
+	output := int64(network.outputBias)
+	for side, values := range [2][]int16{accumulator.values[s.turn], accumulator.values[1-s.turn]} {
+		weights := network.outputWeights[side*network.hidden:]
+		for i, value := range values {
+			output += int64(min(max(value, 0), nnueQA)) * int64(weights[i])
+		}
+	}
+	centiPawns := output * int64(network.outputScale) / (nnueQA * nnueQB)
+	eval := int32(min(max(centiPawns, -nnueMaxEval), nnueMaxEval)) * CentiPawn
+	if perspective != s.turn {
+		return -eval
+	}
+	return eval
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func randomNetwork(hidden int, seed int64) *Network {
+	random := rand.New(rand.NewSource(seed))
+	network := &Network{
+		hidden:         hidden,
+		outputScale:    400,
+		featureWeights: make([]int16, nnueInputs*hidden),
+		featureBiases:  make([]int16, hidden),
+		outputWeights:  make([]int16, 2*hidden),
+		outputBias:     int32(random.Intn(2001) - 1000),
+	}
+	for _, weights := range [][]int16{network.featureWeights, network.featureBiases, network.outputWeights} {
+		for i := range weights {
+			weights[i] = int16(random.Intn(129) - 64)
+		}
+	}
+	return network
+}
+
+// Installs a random network for the duration of the test
+func useRandomNetwork(t *testing.T) *Network {
+	network := randomNetwork(16, 1)
+	evalNetwork, useNNUE = network, true
+	t.Cleanup(func() { evalNetwork, useNNUE = nil, false })
+	return network
+}
+
+// Same position with the colours swapped and the board flipped vertically
+func flipFEN(fen string) string {
+	fields := strings.Fields(fen)
+	ranks := strings.Split(fields[0], "/")
+	slices.Reverse(ranks)
+	swapCase := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r - 'a' + 'A'
+			} else if r >= 'A' && r <= 'Z' {
+				return r - 'A' + 'a'
+			}
+			return r
+		}, text)
+	}
+	fields[0] = swapCase(strings.Join(ranks, "/"))
+	fields[1] = map[string]string{"w": "b", "b": "w"}[fields[1]]
+	if fields[2] != "-" {
+		fields[2] = swapCase(fields[2])
+	}
+	if fields[3] != "-" {
+		fields[3] = fields[3][:1] + map[byte]string{'3': "6", '6': "3"}[fields[3][1]]
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestNetworkFile(t *testing.T) {
+	network := randomNetwork(8, 2)
+	path := filepath.Join(t.TempDir(), "random.nnue")
+	if err := network.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNetwork(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.hidden != network.hidden || loaded.outputScale != network.outputScale || loaded.outputBias != network.outputBias ||
+		!slices.Equal(loaded.featureWeights, network.featureWeights) || !slices.Equal(loaded.featureBiases, network.featureBiases) ||
+		!slices.Equal(loaded.outputWeights, network.outputWeights) {
+		t.Error("loaded network differs from the saved one")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badFiles := map[string][]byte{
+		"magic":     append([]byte("XXNN"), data[4:]...),
+		"version":   append(append(slices.Clone(data[:4]), 2, 0, 0, 0), data[8:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(slices.Clone(data), 0),
+	}
+	for name, badData := range badFiles {
+		badPath := filepath.Join(t.TempDir(), name+".nnue")
+		if err := os.WriteFile(badPath, badData, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadNetwork(badPath); err == nil {
+			t.Errorf("network with a bad %s loaded without an error", name)
+		}
+	}
+}
+
+// The incrementally updated accumulator has to match one computed from scratch after every make
+// and unmake
+func TestAccumulatorIncremental(t *testing.T) {
+	network := useRandomNetwork(t)
+	check := func(s *State, context string) {
+		current := s.accumulators.current(s)
+		fresh := newAccumulator(network)
+		fresh.refresh(s, network, White)
+		fresh.refresh(s, network, Black)
+		if !slices.Equal(current.values[White], fresh.values[White]) || !slices.Equal(current.values[Black], fresh.values[Black]) {
+			t.Fatalf("accumulator differs from a refresh %s in %s", context, s.fenString())
+		}
+	}
+	var walk func(s *State, depth int)
+	walk = func(s *State, depth int) {
+		check(s, "after make")
+		if depth == 0 {
+			return
+		}
+		for _, move := range LegalMoves(s) {
+			s.MakeMove(move)
+			walk(s, depth-1)
+			s.UnMakeMove(move)
+			check(s, "after unmake of "+move.ShortString())
+		}
+	}
+	for _, test := range perftCases {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.EvalState(s.turn)
+			walk(s, 2)
+			copied := s.Copy()
+			check(copied, "after a copy")
+		})
+	}
+}
+
+// HalfKA views both sides the same way so flipping the colours leaves the evaluation unchanged
+func TestNNUESymmetry(t *testing.T) {
+	useRandomNetwork(t)
+	for _, test := range perftCases {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flipped, err := ParseFEN(flipFEN(test.fen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eval, flippedEval := s.EvalState(s.turn), flipped.EvalState(flipped.turn); eval != flippedEval {
+			t.Errorf("%s: eval %d but %d with the colours flipped", test.name, eval/CentiPawn, flippedEval/CentiPawn)
+		}
+	}
+}
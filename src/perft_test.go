@@ -0,0 +1,193 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+type perftCase struct {
+	name  string
+	fen   string
+	depth int64
+	nodes int64
+}
+
+// Depths are kept shallow so the whole suite runs in a few seconds
+var perftCases []perftCase = []perftCase{
+	{"start position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3, 8902},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, 43238},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, 9467},
+	{"position 4 mirrored", "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1", 3, 9467},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+	{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", 3, 89890},
+
+	// Castling
+	{"both sides castle", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", 4, 314346},
+	{"rook off its corner", "r3k2r/8/8/8/8/8/8/1R2K2R w Kkq - 0 1", 4, 328965},
+	{"white castles", "4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", 4, 17945},
+	{"black castles", "r3k2r/8/8/8/8/8/8/4K3 b kq - 0 1", 4, 17945},
+	{"castling rights lost to captures", "r3k2r/1b4bq/8/8/8/8/7B/R3K2R w KQkq - 0 1", 3, 27826},
+	{"castling prevented", "r3k2r/8/3Q4/8/8/5q2/8/R3K2R b KQkq - 0 1", 3, 50509},
+	{"short castling gives check", "5k2/8/8/8/8/8/8/4K2R w K - 0 1", 5, 120330},
+	{"long castling gives check", "3k4/8/8/8/8/8/8/R3K3 w Q - 0 1", 5, 141077},
+
+	// En passant
+	{"illegal en passant with pinned pawn", "3k4/3p4/8/K1P4r/8/8/8/8 b - - 0 1", 5, 185429},
+	{"illegal en passant with bishop", "8/8/4k3/8/2p5/8/B2P2K1/8 w - - 0 1", 5, 135655},
+	{"en passant gives check", "8/8/1k6/2b5/2pP4/8/5K2/8 b - d3 0 1", 5, 206379},
+	{"en passant exposes king on rank", "8/8/8/KPp4r/8/8/8/7k w - c6 0 1", 4, 4225},
+
+	// Promotions, checks and mates
+	{"promote out of check", "2K2r2/4P3/8/8/8/8/8/3k4 w - - 0 1", 4, 19174},
+	{"discovered check", "8/8/1P2K3/8/2n5/1q6/8/5k2 b - - 0 1", 4, 31961},
+	{"promote to give check", "4k3/1P6/8/8/8/8/K7/8 w - - 0 1", 5, 38983},
+	{"underpromote to check", "8/P1k5/K7/8/8/8/8/8 w - - 0 1", 5, 18135},
+	{"self stalemate", "K1k5/8/P7/8/8/8/8/8 w - - 0 1", 6, 2217},
+	{"stalemate and checkmate", "8/k1P5/8/1K6/8/8/8/8 w - - 0 1", 6, 43261},
+	{"double check", "8/8/2k5/5q2/5n2/8/5K2/8 b - - 0 1", 4, 23527},
+	{"promotions both sides", "8/Pk6/8/8/8/8/6Kp/8 w - - 0 1", 4, 8048},
+	{"promotion captures", "n1n5/1Pk5/8/8/8/8/5Kp1/5N1N w - - 0 1", 4, 124608},
+	{"many promotions", "8/PPPk4/8/8/8/8/4Kppp/8 w - - 0 1", 4, 79355},
+	{"many promotion captures", "n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", 4, 182838},
+
+	// Chess960, with the castling rights written as X-FEN
+	{"chess960 1", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", 4, 326672},
+	{"chess960 2", "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w KQkq - 1 9", 3, 18002},
+	{"chess960 3", "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w KQ - 1 9", 4, 273318},
+	{"chess960 4", "qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w kq - 0 9", 3, 13440},
+	{"chess960 5", "1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w KQkq - 0 9", 3, 31058},
+	{"chess960 6", "qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w KQkq - 1 9", 3, 26578},
+	{"chess960 without castling rights", "nrbbqknr/pppppppp/8/8/8/8/PPPPPPPP/NRBBQKNR w - - 0 1", 3, 7775},
+	{"chess960 without castling rights 2", "qnr1bkrb/pppp2pp/3np3/5p2/8/P2P2P1/NPP1PP1P/QN1RBKRB w - - 3 9", 3, 24258},
+}
+
+func TestPerft(t *testing.T) {
+	for _, test := range perftCases {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nodes int64 = 0
+			Perft(test.depth, &nodes, s)
+			if nodes != test.nodes {
+				t.Errorf("perft(%d) of %s = %d, want %d", test.depth, test.fen, nodes, test.nodes)
+			}
+			if fen := s.fenString(); fen != test.fen {
+				t.Errorf("position not restored after perft: got %s", fen)
+			}
+		})
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	s := StartingFen()
+	entries := PerftDivide(3, s)
+	if len(entries) != 20 {
+		t.Fatalf("divide has %d moves, want 20", len(entries))
+	}
+	var total int64 = 0
+	for _, entry := range entries {
+		total += entry.nodes
+	}
+	if total != 8902 {
+		t.Errorf("divide total = %d, want 8902", total)
+	}
+}
+
+// Walks the tree checking that the incremental hash matches one computed from scratch and that
+// unmaking every move restores the position exactly
+func TestMakeUnMakeConsistency(t *testing.T) {
+	for i, test := range perftCases {
+		if i >= 8 && !strings.HasPrefix(test.name, "chess960") {
+			continue
+		}
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMakeUnMake(t, s, 3)
+		})
+	}
+}
+
+func checkMakeUnMake(t *testing.T, s *State, depth int) {
+	if depth == 0 || t.Failed() {
+		return
+	}
+	for _, move := range LegalMoves(s) {
+		fen := s.fenString()
+		hash := s.hashcode
+		s.MakeMove(move)
+		if s.hashcode != s.hash() {
+			t.Errorf("incremental hash wrong after %s from %s", move.ShortString(), fen)
+		}
+		checkMakeUnMake(t, s, depth-1)
+		s.UnMakeMove(move)
+		if s.fenString() != fen || s.hashcode != hash {
+			t.Errorf("unmaking %s from %s gave %s", move.ShortString(), fen, s.fenString())
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		standard string // The king's move, as written without UCI_Chess960 and ambiguous in Chess960
+		chess960 string // The king capturing its own rook
+		after    string
+	}{
+		{"king stays", "1r4kr/8/8/8/8/8/8/1R4KR w KQkq - 0 1", "g1g1", "g1h1", "1r4kr/8/8/8/8/8/8/1R3RK1 b kq - 1 1"},
+		{"king and rook swap", "4k3/8/8/8/8/8/8/5KR1 w K - 0 1", "f1g1", "f1g1", "4k3/8/8/8/8/8/8/5RK1 b - - 1 1"},
+		{"king onto the rook", "7k/8/8/8/8/8/8/1R2RK2 w E - 0 1", "f1c1", "f1e1", "7k/8/8/8/8/8/8/1RKR4 b - - 1 1"},
+		{"black long castling", "rk5r/8/8/8/8/8/8/4K3 b q - 0 1", "b8c8", "b8a8", "2kr3r/8/8/8/8/8/8/4K3 w - - 1 2"},
+	}
+	defer func() { chess960 = false }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			move := NilMove
+			for _, legalMove := range LegalMoves(s) {
+				// King side castling is generated first
+				if legalMove.SpecialMove() == CastleSpecialMove && move == NilMove {
+					move = legalMove
+				}
+			}
+			chess960 = false
+			if moveString := s.uciMoveString(move); moveString != test.standard {
+				t.Fatalf("castling written as %s, want %s", moveString, test.standard)
+			}
+			chess960 = true
+			if moveString := s.uciMoveString(move); moveString != test.chess960 {
+				t.Errorf("castling written as %s, want %s", moveString, test.chess960)
+			}
+			if parsed, ok := s.moveFromShortString(test.chess960); !ok || parsed != move {
+				t.Errorf("%s read as %s", test.chess960, parsed.ShortString())
+			}
+			s.MakeMove(move)
+			if fen := s.fenString(); fen != test.after || s.hashcode != s.hash() {
+				t.Errorf("castling gave %s, want %s", fen, test.after)
+			}
+			s.UnMakeMove(move)
+			if fen := s.fenString(); fen != test.fen {
+				t.Errorf("unmaking castling gave %s", fen)
+			}
+		})
+	}
+	// The rook on b1 shields c1 from the queen until it moves
+	s, err := ParseFEN("4k3/8/8/8/8/8/8/qR3K2 w Q - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range LegalMoves(s) {
+		if move.SpecialMove() == CastleSpecialMove {
+			t.Errorf("castled with %s into the queen's attack", move.ShortString())
+		}
+	}
+}
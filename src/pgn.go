@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const pgnLineLength = 80
+
+// Always written first and in this order
+var pgnSevenTagRoster [7]string = [7]string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+var pgnRosterDefaults [7]string = [7]string{"?", "?", "????.??.??", "?", "?", "?", "*"}
+
+// Move suffix annotations and the NAGs they stand for
+var pgnSuffixNags map[string]int = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+type PGNTag struct {
+	name  string
+	value string
+}
+
+type PGNMove struct {
+	move       Move
+	nags       []int
+	comment    string      // Comment that follows the move
+	variations [][]PGNMove // Lines played instead of the move, each may have its own variations
+}
+
+type PGNGame struct {
+	tags         []PGNTag
+	startComment string // Comment before the first move
+	moves        []PGNMove
+	result       string
+}
+
+func NewPGNGame() *PGNGame {
+	game := &PGNGame{result: "*"}
+	for i, name := range pgnSevenTagRoster {
+		game.SetTag(name, pgnRosterDefaults[i])
+	}
+	return game
+}
+
+func (g *PGNGame) Tag(name string) string {
+	for _, tag := range g.tags {
+		if tag.name == name {
+			return tag.value
+		}
+	}
+	return ""
+}
+
+func (g *PGNGame) SetTag(name string, value string) {
+	for i := range g.tags {
+		if g.tags[i].name == name {
+			g.tags[i].value = value
+			return
+		}
+	}
+	g.tags = append(g.tags, PGNTag{name, value})
+}
+
+func (g *PGNGame) SetStartFen(fen string) {
+	g.SetTag("SetUp", "1")
+	g.SetTag("FEN", fen)
+}
+
+// One of "1-0", "0-1", "1/2-1/2" or "*"
+func (g *PGNGame) SetResult(result string) {
+	g.result = result
+	g.SetTag("Result", result)
+}
+
+func (g *PGNGame) AddMove(move Move) {
+	g.moves = append(g.moves, PGNMove{move: move})
+}
+
+func (g *PGNGame) StartState() (*State, error) {
+	if fen := g.Tag("FEN"); fen != "" {
+		return ParseFEN(fen)
+	}
+	return StartingFen(), nil
+}
+
+// The position before every move followed by the final position
+func (g *PGNGame) States() ([]*State, error) {
+	s, err := g.StartState()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*State, 0, len(g.moves)+1)
+	states = append(states, s.Copy())
+	for _, pgnMove := range g.moves {
+		if !IsLegal(s, pgnMove.move) {
+			return nil, fmt.Errorf("illegal move %s at ply %d", pgnMove.move.ShortString(), len(states))
+		}
+		s.MakeMove(pgnMove.move)
+		states = append(states, s.Copy())
+	}
+	return states, nil
+}
+
+func (g *PGNGame) Write(w io.Writer) error {
+	var builder strings.Builder
+	for i, name := range pgnSevenTagRoster {
+		value := g.Tag(name)
+		if value == "" {
+			value = pgnRosterDefaults[i]
+		}
+		writePGNTag(&builder, name, value)
+	}
+	for _, tag := range g.tags {
+		if !isSevenTagRoster(tag.name) {
+			writePGNTag(&builder, tag.name, tag.value)
+		}
+	}
+	builder.WriteString("\n")
+	tokens, err := g.movetextTokens()
+	if err != nil {
+		return err
+	}
+	lineLength := 0
+	for _, token := range tokens {
+		if lineLength > 0 && lineLength+1+len(token) > pgnLineLength {
+			builder.WriteString("\n")
+			lineLength = 0
+		} else if lineLength > 0 {
+			builder.WriteString(" ")
+			lineLength++
+		}
+		builder.WriteString(token)
+		lineLength += len(token)
+	}
+	builder.WriteString("\n\n")
+	_, err = io.WriteString(w, builder.String())
+	return err
+}
+
+func writePGNTag(builder *strings.Builder, name string, value string) {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	fmt.Fprintf(builder, "[%s \"%s\"]\n", name, value)
+}
+
+func isSevenTagRoster(name string) bool {
+	for _, rosterName := range pgnSevenTagRoster {
+		if name == rosterName {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *PGNGame) movetextTokens() ([]string, error) {
+	s, err := g.StartState()
+	if err != nil {
+		return nil, err
+	}
+	tokens := []string{}
+	if g.startComment != "" {
+		tokens = append(tokens, "{"+g.startComment+"}")
+	}
+	tokens, err = appendMovetextTokens(tokens, s, g.moves)
+	if err != nil {
+		return nil, err
+	}
+	result := g.result
+	if result == "" {
+		result = "*"
+	}
+	return append(tokens, result), nil
+}
+
+// Adds the moves of a line played from s, with their variations in parentheses
+func appendMovetextTokens(tokens []string, s *State, moves []PGNMove) ([]string, error) {
+	// Black moves need their number when they do not directly follow a white move
+	needNumber := true
+	for _, pgnMove := range moves {
+		if !IsLegal(s, pgnMove.move) {
+			return nil, fmt.Errorf("illegal move %s at ply %d", pgnMove.move.ShortString(), s.ply)
+		}
+		// Numbers are kept in the same token as their move so a line never ends between them
+		moveNumber := s.ply/2 + 1
+		if s.turn == White {
+			tokens = append(tokens, fmt.Sprintf("%d. %s", moveNumber, s.SAN(pgnMove.move)))
+		} else if needNumber {
+			tokens = append(tokens, fmt.Sprintf("%d... %s", moveNumber, s.SAN(pgnMove.move)))
+		} else {
+			tokens = append(tokens, s.SAN(pgnMove.move))
+		}
+		needNumber = false
+		for _, nag := range pgnMove.nags {
+			tokens = append(tokens, "$"+strconv.Itoa(nag))
+			needNumber = true
+		}
+		if pgnMove.comment != "" {
+			tokens = append(tokens, "{"+pgnMove.comment+"}")
+			needNumber = true
+		}
+		for _, variation := range pgnMove.variations {
+			if len(variation) == 0 {
+				continue
+			}
+			start := len(tokens)
+			var err error
+			if tokens, err = appendMovetextTokens(tokens, s.Copy(), variation); err != nil {
+				return nil, err
+			}
+			// The parentheses stay attached to the first and last tokens like move numbers
+			tokens[start] = "(" + tokens[start]
+			tokens[len(tokens)-1] += ")"
+			needNumber = true
+		}
+		s.MakeMove(pgnMove.move)
+	}
+	return tokens, nil
+}
+
+// Reads games one at a time so large databases never have to be held in memory
+type PGNReader struct {
+	reader   *bufio.Reader
+	line     int
+	lastRune rune
+}
+
+// The main line or a variation being read. Moves is nil for variations of a game that already
+// failed, which are only read to find where they end
+type pgnLine struct {
+	moves        *[]PGNMove
+	state        *State // Position after the moves read so far, nil until the first main line move
+	startComment string // Comment before the first move
+}
+
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{reader: bufio.NewReader(r), line: 1, lastRune: '\n'}
+}
+
+// Returns io.EOF when there are no games left. A game with an illegal or unreadable move is
+// skipped up to its end and returned as an error so the caller can carry on with the next one
+func (pr *PGNReader) Next() (*PGNGame, error) {
+	game := &PGNGame{}
+	// The innermost variation is last
+	lines := []*pgnLine{{moves: &game.moves}}
+	var gameErr error
+	started := false
+	inMovetext := false
+	for {
+		line := lines[len(lines)-1]
+		atLineStart := pr.lastRune == '\n'
+		r, err := pr.readRune()
+		if err == io.EOF {
+			if !started {
+				return nil, io.EOF
+			}
+			return pr.finishGame(game, lines, gameErr)
+		} else if err != nil {
+			return nil, err
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		started = true
+		switch {
+		case r == '%' && atLineStart:
+			pr.readUntil('\n')
+		case r == '[':
+			if inMovetext {
+				// The previous game ended without a result
+				pr.unreadRune()
+				return pr.finishGame(game, lines, gameErr)
+			}
+			if err := pr.readTag(game); err != nil {
+				return nil, err
+			}
+		case r == '{':
+			line.addComment(strings.TrimSpace(pr.readUntil('}')))
+			inMovetext = true
+		case r == ';':
+			line.addComment(strings.TrimSpace(pr.readUntil('\n')))
+			inMovetext = true
+		case r == '(':
+			inMovetext = true
+			variation := &pgnLine{}
+			if gameErr == nil && len(*line.moves) == 0 {
+				gameErr = fmt.Errorf("PGN line %d: variation before any move", pr.line)
+			} else if gameErr == nil {
+				// The variation replaces the last move so it starts from the position before it
+				lastMove := &(*line.moves)[len(*line.moves)-1]
+				lastMove.variations = append(lastMove.variations, nil)
+				variation.moves = &lastMove.variations[len(lastMove.variations)-1]
+				variation.state = line.state.Copy()
+				variation.state.UnMakeMove(lastMove.move)
+			}
+			lines = append(lines, variation)
+		case r == ')':
+			if len(lines) == 1 {
+				gameErr = firstError(gameErr, fmt.Errorf("PGN line %d: unexpected )", pr.line))
+			} else {
+				lines = lines[:len(lines)-1]
+			}
+		case r == '$':
+			nag, err := strconv.Atoi(pr.readToken())
+			if err != nil || line.moves == nil || len(*line.moves) == 0 {
+				gameErr = firstError(gameErr, fmt.Errorf("PGN line %d: invalid NAG", pr.line))
+			} else {
+				lastMove := &(*line.moves)[len(*line.moves)-1]
+				lastMove.nags = append(lastMove.nags, nag)
+			}
+		default:
+			pr.unreadRune()
+			token := pr.readToken()
+			inMovetext = true
+			if token == "1-0" || token == "0-1" || token == "1/2-1/2" || token == "*" {
+				game.result = token
+				if game.Tag("Result") == "" {
+					game.SetTag("Result", token)
+				}
+				return pr.finishGame(game, lines, gameErr)
+			}
+			if gameErr != nil {
+				continue
+			}
+			// Move numbers may be attached to the move that follows them (eg 1.e4 or 12...Nf6)
+			san := token
+			digits := len(token) - len(strings.TrimLeft(token, "0123456789"))
+			if digits == len(token) {
+				continue
+			} else if digits > 0 && token[digits] == '.' {
+				san = strings.TrimLeft(token[digits:], ".")
+				if san == "" {
+					continue
+				}
+			}
+			if line.state == nil {
+				line.state, err = game.StartState()
+				if err != nil {
+					gameErr = fmt.Errorf("PGN line %d: %w", pr.line, err)
+					continue
+				}
+			}
+			san, nag := splitSuffixAnnotation(san)
+			move, err := line.state.ParseSAN(san)
+			if err != nil {
+				gameErr = fmt.Errorf("PGN line %d: %w", pr.line, err)
+				continue
+			}
+			line.state.MakeMove(move)
+			*line.moves = append(*line.moves, PGNMove{move: move})
+			if len(lines) > 1 && len(*line.moves) == 1 {
+				(*line.moves)[0].comment = line.startComment
+			}
+			if nag != 0 {
+				(*line.moves)[len(*line.moves)-1].nags = []int{nag}
+			}
+		}
+	}
+}
+
+func (pr *PGNReader) finishGame(game *PGNGame, lines []*pgnLine, gameErr error) (*PGNGame, error) {
+	if len(lines) > 1 {
+		gameErr = firstError(gameErr, fmt.Errorf("PGN line %d: unterminated variation", pr.line))
+	}
+	if gameErr != nil {
+		return nil, gameErr
+	}
+	game.startComment = lines[0].startComment
+	if game.result == "" {
+		game.result = "*"
+	}
+	return game, nil
+}
+
+func firstError(current error, next error) error {
+	if current != nil {
+		return current
+	}
+	return next
+}
+
+// Comments before the first move of a variation have nowhere else to go, so they end up after
+// that move
+func (line *pgnLine) addComment(comment string) {
+	if comment == "" || line.moves == nil {
+		return
+	}
+	target := &line.startComment
+	if len(*line.moves) > 0 {
+		target = &(*line.moves)[len(*line.moves)-1].comment
+	}
+	if *target != "" {
+		*target += " "
+	}
+	*target += comment
+}
+
+// Splits a move like e4!? into the move and its NAG, zero if there is none
+func splitSuffixAnnotation(san string) (string, int) {
+	trimmed := strings.TrimRight(san, "!?")
+	return trimmed, pgnSuffixNags[san[len(trimmed):]]
+}
+
+func (pr *PGNReader) readRune() (rune, error) {
+	r, _, err := pr.reader.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r == '\n' {
+		pr.line++
+	}
+	pr.lastRune = r
+	return r, nil
+}
+
+// Only the rune that was just read can be unread
+func (pr *PGNReader) unreadRune() {
+	if pr.lastRune == '\n' {
+		pr.line--
+	}
+	pr.reader.UnreadRune()
+}
+
+// Reads up to and including the delimiter and returns everything before it
+func (pr *PGNReader) readUntil(delimiter rune) string {
+	var builder strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil || r == delimiter {
+			return builder.String()
+		}
+		builder.WriteRune(r)
+	}
+}
+
+// Reads until whitespace or a character that starts a different kind of token
+func (pr *PGNReader) readToken() string {
+	var builder strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return builder.String()
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune("{}()[];$", r) {
+			pr.unreadRune()
+			return builder.String()
+		}
+		builder.WriteRune(r)
+	}
+}
+
+func (pr *PGNReader) readTag(game *PGNGame) error {
+	var builder strings.Builder
+	inQuotes, escaped := false, false
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return fmt.Errorf("PGN line %d: unterminated tag", pr.line)
+		}
+		if r == ']' && !inQuotes {
+			break
+		}
+		if escaped {
+			escaped = false
+		} else if r == '\\' && inQuotes {
+			escaped = true
+		} else if r == '"' {
+			inQuotes = !inQuotes
+		}
+		builder.WriteRune(r)
+	}
+	content := builder.String()
+	name, value, found := strings.Cut(strings.TrimSpace(content), " ")
+	value = strings.TrimSpace(value)
+	if !found || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return fmt.Errorf("PGN line %d: invalid tag [%s]", pr.line, content)
+	}
+	value = strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(value[1 : len(value)-1])
+	game.SetTag(name, value)
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readPGNGames(t *testing.T, pgn string) ([]*PGNGame, []error) {
+	t.Helper()
+	reader := NewPGNReader(strings.NewReader(pgn))
+	games, errs := []*PGNGame{}, []error{}
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return games, errs
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		games = append(games, game)
+	}
+}
+
+func pgnMoveStrings(moves []PGNMove) []string {
+	strs := []string{}
+	for _, pgnMove := range moves {
+		strs = append(strs, pgnMove.move.ShortString())
+	}
+	return strs
+}
+
+func TestPGNRead(t *testing.T) {
+	pgn := `% Escaped line [Event "Not a tag"]
+[Event "Test \"quoted\" \\ event"]
+[White "Someone"]
+[FEN "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"]
+[SetUp "1"]
+
+{Before the first move} 1. O-O-O!? $14 O-O?? {Short castling}
+; Rest of line comment
+2. Rd7 $4 $19 1-0
+`
+	games, errs := readPGNGames(t, pgn)
+	if len(errs) != 0 || len(games) != 1 {
+		t.Fatalf("read %d games with errors %v, want 1 game", len(games), errs)
+	}
+	game := games[0]
+	if game.Tag("Event") != `Test "quoted" \ event` || game.Tag("White") != "Someone" || game.Tag("Result") != "1-0" || game.result != "1-0" {
+		t.Errorf("read tags %v and result %s", game.tags, game.result)
+	}
+	if game.startComment != "Before the first move" {
+		t.Errorf("start comment %q", game.startComment)
+	}
+	if moves := pgnMoveStrings(game.moves); !slices.Equal(moves, []string{"e1c1", "e8g8", "d1d7"}) {
+		t.Fatalf("read moves %v", moves)
+	}
+	// Suffix annotations come before the NAGs written after them
+	wantNags := [][]int{{5, 14}, {4}, {4, 19}}
+	wantComments := []string{"", "Short castling Rest of line comment", ""}
+	for i, pgnMove := range game.moves {
+		if !slices.Equal(pgnMove.nags, wantNags[i]) || pgnMove.comment != wantComments[i] {
+			t.Errorf("move %d has NAGs %v and comment %q, want %v and %q", i+1, pgnMove.nags, pgnMove.comment, wantNags[i], wantComments[i])
+		}
+	}
+}
+
+func TestPGNVariations(t *testing.T) {
+	pgn := `1. e4 e5 (1... c5 2. Nf3 (2. c3 {Alapin} d5) 2... d6 $1) (1... e6) 2. Nf3 Nc6 *`
+	games, errs := readPGNGames(t, pgn)
+	if len(errs) != 0 || len(games) != 1 {
+		t.Fatalf("read %d games with errors %v, want 1 game", len(games), errs)
+	}
+	moves := games[0].moves
+	if got := pgnMoveStrings(moves); !slices.Equal(got, []string{"e2e4", "e7e5", "g1f3", "b8c6"}) {
+		t.Fatalf("main line %v", got)
+	}
+	if len(moves[1].variations) != 2 {
+		t.Fatalf("found %d variations for 1... e5, want 2", len(moves[1].variations))
+	}
+	sicilian := moves[1].variations[0]
+	if got := pgnMoveStrings(sicilian); !slices.Equal(got, []string{"c7c5", "g1f3", "d7d6"}) || !slices.Equal(sicilian[2].nags, []int{1}) {
+		t.Errorf("first variation %v", got)
+	}
+	if len(sicilian[1].variations) != 1 {
+		t.Fatalf("found %d variations for 2. Nf3, want 1", len(sicilian[1].variations))
+	}
+	alapin := sicilian[1].variations[0]
+	if got := pgnMoveStrings(alapin); !slices.Equal(got, []string{"c2c3", "d7d5"}) || alapin[0].comment != "Alapin" {
+		t.Errorf("nested variation %v", got)
+	}
+	if got := pgnMoveStrings(moves[1].variations[1]); !slices.Equal(got, []string{"e7e6"}) {
+		t.Errorf("second variation %v", got)
+	}
+}
+
+// A bad game is reported and skipped and the games after it are still read
+func TestPGNBadGames(t *testing.T) {
+	pgn := `[Event "Illegal"]
+
+1. e4 e5 2. Ke3 Nf6 1-0
+
+[Event "Unterminated"]
+
+1. e4 (1. d4 d5 *
+
+[Event "Stray parenthesis"]
+
+1. d4 ) d5 *
+
+[Event "Good"]
+
+1. d4 d5 (1... Nf6 2. c4) 1/2-1/2
+`
+	games, errs := readPGNGames(t, pgn)
+	if len(errs) != 3 {
+		t.Errorf("got errors %v, want 3", errs)
+	}
+	if len(games) != 1 || games[0].Tag("Event") != "Good" || games[0].result != "1/2-1/2" {
+		t.Fatalf("read %d games, want only the good one", len(games))
+	}
+	if got := pgnMoveStrings(games[0].moves); !slices.Equal(got, []string{"d2d4", "d7d5"}) {
+		t.Errorf("good game moves %v", got)
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	pgn := `[Event "Round trip"]
+[Site "?"]
+[Date "2024.01.02"]
+[Round "1"]
+[White "A"]
+[Black "B"]
+[Result "0-1"]
+[Annotator "C"]
+
+{Start} 1. e4 e5 (1... c5 {Sicilian} 2. Nf3 (2. c3 d5) 2... d6 $1) (1... e6)
+2. Nf3 $1 2... Nc6 $2 {Knight} 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3
+d6 8. c3 O-O 9. h3 Nb8 10. d4 Nbd7 11. c4 c6 12. cxb5 axb5 13. Nc3 Bb7 14. Bg5
+b4 15. Nb1 h6 16. Bh4 c5 17. dxe5 Nxe4 18. Bxe7 Qxe7 19. exd6 Qf6 20. Nbd2 Nxd6
+21. Nc4 Nxc4 22. Bxc4 Nb6 23. Ne5 Rae8 24. Bxf7+ Rxf7 25. Nxf7 Rxe1+ 26. Qxe1
+Kxf7 27. Qe3 Qg5 28. Qxg5 hxg5 29. b3 Ke6 30. a3 Kd6 31. axb4 cxb4 32. Ra5 Nd5
+33. f3 Bc8 34. Kf2 Bf5 35. Ra7 g6 36. Ra6+ Kc5 37. Ke1 Nf4 38. g3 Nxh3 39. Kd2
+Kb5 40. Rd6 Kc5 41. Ra6 Nf2 42. g4 Bd3 43. Re6 0-1
+
+`
+	games, errs := readPGNGames(t, pgn)
+	if len(errs) != 0 || len(games) != 1 {
+		t.Fatalf("read %d games with errors %v, want 1 game", len(games), errs)
+	}
+	var written strings.Builder
+	if err := games[0].Write(&written); err != nil {
+		t.Fatal(err)
+	}
+	if written.String() != pgn {
+		t.Errorf("wrote\n%s\nwant\n%s", written.String(), pgn)
+	}
+}
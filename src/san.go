@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var sanPieceLetters [6]string = [6]string{"K", "Q", "R", "B", "N", ""}
+
+// Indexed by promotion type
+var sanPromotionLetters [4]string = [4]string{"Q", "R", "N", "B"}
+
+// Standard Algebraic Notation (eg Nf3, exd5, O-O, e8=Q+, Qxf7#) for a legal move in the state
+func (s *State) SAN(move Move) string {
+	san := s.sanWithoutSuffix(move, LegalMoves(s))
+	s.MakeMove(move)
+	if s.check {
+		if len(LegalMoves(s)) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	s.UnMakeMove(move)
+	return san
+}
+
+func (s *State) sanWithoutSuffix(move Move, legalMoves []Move) string {
+	origin := move.OriginSquare()
+	destination := move.DestinationSquare()
+	if move.SpecialMove() == CastleSpecialMove {
+		if destination.File() == 6 {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+	piece := s.board.getColorPieceAt(origin, s.turn) % 6
+	isCapture := s.sideOccupied[1-s.turn]&boardFromSquare(destination) != 0 || move.SpecialMove() == EnPassantSpacialMove
+	san := sanPieceLetters[piece]
+	if piece == Pawn {
+		if isCapture {
+			san += string(rune('a' + origin.File()))
+		}
+	} else {
+		// Only add as much of the origin square as is needed to tell apart identical pieces
+		ambiguous, sameFile, sameRank := false, false, false
+		for _, other := range legalMoves {
+			otherOrigin := other.OriginSquare()
+			if other.DestinationSquare() != destination || otherOrigin == origin || s.board.getColorPieceAt(otherOrigin, s.turn)%6 != piece {
+				continue
+			}
+			ambiguous = true
+			sameFile = sameFile || otherOrigin.File() == origin.File()
+			sameRank = sameRank || otherOrigin.Rank() == origin.Rank()
+		}
+		if ambiguous {
+			if !sameFile {
+				san += string(rune('a' + origin.File()))
+			} else if !sameRank {
+				san += string(rune('1' + origin.Rank()))
+			} else {
+				san += origin.String()
+			}
+		}
+	}
+	if isCapture {
+		san += "x"
+	}
+	san += destination.String()
+	if move.SpecialMove() == PromotionSpecialMove {
+		san += "=" + sanPromotionLetters[move.PromotionType()]
+	}
+	return san
+}
+
+// Resolves a SAN string to a legal move in the state. Check and annotation suffixes, a missing
+// capture mark, a missing "=" before the promotion piece, extra disambiguation and castling
+// written with zeros are all accepted
+func (s *State) ParseSAN(san string) (Move, error) {
+	input := san
+	san = strings.TrimSuffix(strings.TrimSpace(san), "e.p.")
+	san = strings.TrimRight(san, "+#!? ")
+	legalMoves := LegalMoves(s)
+	if castle := strings.ReplaceAll(san, "0", "O"); castle == "O-O" || castle == "O-O-O" {
+		for _, move := range legalMoves {
+			if move.SpecialMove() == CastleSpecialMove && s.sanWithoutSuffix(move, legalMoves) == castle {
+				return move, nil
+			}
+		}
+		return NilMove, fmt.Errorf("illegal move %q", input)
+	}
+	piece := uint8(Pawn)
+	if len(san) > 0 && strings.Contains("KQRBN", san[:1]) {
+		piece = uint8(strings.Index("KQRBN", san[:1]))
+		san = san[1:]
+	}
+	promotion := -1
+	if len(san) > 0 && strings.Contains("QRNBqrnb", san[len(san)-1:]) {
+		promotion = strings.Index("QRNB", strings.ToUpper(san[len(san)-1:]))
+		san = strings.TrimSuffix(san[:len(san)-1], "=")
+	}
+	san = strings.NewReplacer("x", "", ":", "", "-", "").Replace(san)
+	if len(san) < 2 || !isSquareString(san[len(san)-2:]) {
+		return NilMove, fmt.Errorf("invalid SAN %q", input)
+	}
+	destination := SFS(san[len(san)-2:])
+	originFile, originRank := int8(-1), int8(-1)
+	for _, c := range san[:len(san)-2] {
+		if c >= 'a' && c <= 'h' && originFile == -1 {
+			originFile = int8(c - 'a')
+		} else if c >= '1' && c <= '8' && originRank == -1 {
+			originRank = int8(c - '1')
+		} else {
+			return NilMove, fmt.Errorf("invalid SAN %q", input)
+		}
+	}
+	found := NilMove
+	for _, move := range legalMoves {
+		origin := move.OriginSquare()
+		if move.DestinationSquare() != destination || s.board.getColorPieceAt(origin, s.turn)%6 != piece || move.SpecialMove() == CastleSpecialMove {
+			continue
+		}
+		if (originFile != -1 && origin.File() != originFile) || (originRank != -1 && origin.Rank() != originRank) {
+			continue
+		}
+		if move.SpecialMove() == PromotionSpecialMove {
+			if promotion == -1 {
+				return NilMove, fmt.Errorf("missing promotion piece in %q", input)
+			}
+			if int(move.PromotionType()) != promotion {
+				continue
+			}
+		} else if promotion != -1 {
+			continue
+		}
+		if found != NilMove {
+			return NilMove, fmt.Errorf("ambiguous move %q", input)
+		}
+		found = move
+	}
+	if found == NilMove {
+		return NilMove, fmt.Errorf("illegal move %q", input)
+	}
+	return found, nil
+}
+
+func isSquareString(squareString string) bool {
+	return len(squareString) == 2 && squareString[0] >= 'a' && squareString[0] <= 'h' && squareString[1] >= '1' && squareString[1] <= '8'
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		san  string
+	}{
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "b8d7", "Nbd7"},
+		{"k7/8/8/8/8/4R3/8/4R1K1 w - - 0 1", "e1e2", "R1e2"},
+		{"2k5/8/8/8/4Q2Q/8/8/K6Q w - - 0 1", "h4e1", "Qh4e1"},
+		{"2k5/8/8/8/4Q2Q/8/8/K6Q w - - 0 1", "e4e1", "Qee1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"k7/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7e8q", "e8=Q+"},
+		{"r7/1P5R/pkp5/ppp5/8/8/8/7K w - - 0 1", "b7a8n", "bxa8=N#"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", "e5d6", "exd6"},
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, ok := s.moveFromShortString(test.move)
+		if !ok {
+			t.Fatalf("%s is not legal in %s", test.move, test.fen)
+		}
+		if san := s.SAN(move); san != test.san {
+			t.Errorf("%s in %s written as %s, want %s", test.move, test.fen, san, test.san)
+		}
+		if parsed, err := s.ParseSAN(test.san); err != nil || parsed != move {
+			t.Errorf("%s in %s parsed as %s (%v), want %s", test.san, test.fen, parsed.ShortString(), err, test.move)
+		}
+	}
+}
+
+func TestParseSANVariants(t *testing.T) {
+	tests := []struct {
+		fen  string
+		san  string
+		move string
+	}{
+		{startingFenString, "Ng1f3", "g1f3"},
+		{startingFenString, "e4!?", "e2e4"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1"},
+		{"k7/4P3/8/8/8/8/8/4K3 w - - 0 1", "e8N", "e7e8n"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", "ed6 e.p.", "e5d6"},
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move, err := s.ParseSAN(test.san); err != nil || move.ShortString() != test.move {
+			t.Errorf("%s in %s parsed as %s (%v), want %s", test.san, test.fen, move.ShortString(), err, test.move)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	tests := []struct {
+		fen string
+		san string
+		err string
+	}{
+		{"1n2k3/8/5n2/8/8/8/8/4K3 b - - 0 1", "Nd7", "ambiguous move"},
+		{"k7/8/8/8/8/4R3/8/4R1K1 w - - 0 1", "Re2", "ambiguous move"},
+		{"2k5/8/8/8/4Q2Q/8/8/K6Q w - - 0 1", "Qhe1", "ambiguous move"},
+		{startingFenString, "Nf6", "illegal move"},
+		{startingFenString, "e5", "illegal move"},
+		{startingFenString, "O-O", "illegal move"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Kf1=Q", "illegal move"},
+		{"k7/4P3/8/8/8/8/8/4K3 w - - 0 1", "e8", "missing promotion piece"},
+		{startingFenString, "Nz3", "invalid SAN"},
+		{startingFenString, "", "invalid SAN"},
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move, err := s.ParseSAN(test.san); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q in %s parsed as %s (%v), want an error containing %q", test.san, test.fen, move.ShortString(), err, test.err)
+		}
+	}
+}
+
+// Every legal move in the perft positions survives being written and read back
+func TestSANRoundTrip(t *testing.T) {
+	for _, test := range perftCases {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range LegalMoves(s) {
+			san := s.SAN(move)
+			parsed, err := s.ParseSAN(san)
+			if err != nil || parsed != move {
+				t.Errorf("%s in %s written as %s and read back as %s (%v)", move.ShortString(), test.fen, san, parsed.ShortString(), err)
+			} else if again := s.SAN(parsed); again != san {
+				t.Errorf("%s in %s written as %s and then %s", move.ShortString(), test.fen, san, again)
+			}
+		}
+	}
+}
@@ -2,11 +2,34 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type HistoryTable [12][64]uint64
 
+// Zero values mean the search is not limited in that dimension
+type SearchLimits struct {
+	maxTime     time.Duration
+	maxDepth    int32
+	maxNodes    uint64       // Counted by the main thread only
+	timeManager *TimeManager // Used for clock based games instead of a fixed maxTime
+}
+
+// Sent after every completed iteration of the iterative deepening loop
+type SearchReport struct {
+	depth   int32
+	score   int32 // Relative to the side to move
+	nodes   uint64
+	tbHits  uint64
+	elapsed time.Duration
+	pv      []Move
+}
+
+type SearchReporter func(report SearchReport)
+
 const (
 	min32 int32 = -2147483646
 	max32 int32 = 2147483647
@@ -25,19 +48,79 @@ const (
 	NullMoveReduction = 2
 
 	FutilityCutoff int32 = CentiPawn * 300
-)
 
-var nodesSearched uint64 = 0
+	MaxSearchDepth int32 = 100
+
+	stopPollMask uint64 = 1023 // How often in nodes the search checks if it should stop
+)
 
 var lastMoveScore int32 = startingEval
 
-var historyTable HistoryTable = HistoryTable{}
+// Set from outside the search to end it as soon as possible
+var stopSearch atomic.Bool
 
 func (s *State) IterativeDeepiningSearch(maxTime time.Duration, debugPrint bool) Move {
-	totalNodes := uint64(0)
+	return s.Search(SearchLimits{maxTime: maxTime}, debugPrint, nil)
+}
+
+// Runs the main thread on the given state and a helper thread on a copy of it for every other
+// search worker. Helpers only contribute by filling the shared transposition table
+func (s *State) Search(limits SearchLimits, debugPrint bool, reporter SearchReporter) Move {
 	startTime := time.Now()
+	if len(searchWorkers) == 0 {
+		SetSearchThreads(DefaultSearchThreads)
+	}
+	scoreGuess := lastMoveScore
+	var rootMoves []Move
+	if tablebases != nil {
+		rootMoves = tablebases.RootMoves(s)
+	}
+	helpersStop.Store(false)
+	helpers := sync.WaitGroup{}
+	for _, helper := range searchWorkers[1:] {
+		helper.prepare(s.Copy(), SearchLimits{maxDepth: limits.maxDepth}, startTime, &helpersStop)
+		helper.rootMoves = rootMoves
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			helper.iterativeDeepening(scoreGuess, false, nil)
+		}()
+	}
+	// Reports count the nodes of every thread, not just the main one
+	var poolReporter SearchReporter
+	if reporter != nil {
+		poolReporter = func(report SearchReport) {
+			report.nodes = totalNodesSearched()
+			report.tbHits = totalTablebaseHits()
+			reporter(report)
+		}
+	}
+	mainWorker := searchWorkers[0]
+	mainWorker.prepare(s, limits, startTime, &stopSearch)
+	mainWorker.rootMoves = rootMoves
+	bestMove, bestScore := mainWorker.iterativeDeepening(scoreGuess, debugPrint, poolReporter)
+	helpersStop.Store(true)
+	helpers.Wait()
+	if debugPrint {
+		totalNodes := totalNodesSearched()
+		fmt.Println("Best Move:", bestMove.ShortString())
+		fmt.Println("Move Evaluation:", prettyEval(bestScore, s.turn))
+		fmt.Println("Expected Moves:", s.getPV())
+		fmt.Println("Total Nodes Searched:", totalNodes)
+		fmt.Println("Total Search Time:", time.Since(startTime))
+		fmt.Printf("Million Nodes per Second: %.2f\n", float64(totalNodes)/time.Since(startTime).Seconds()/1_000_000.0)
+	}
+	lastMoveScore = bestScore
+	return bestMove
+}
+
+// Returns the best move and score of the last completed iteration
+func (w *SearchWorker) iterativeDeepening(scoreGuess int32, debugPrint bool, reporter SearchReporter) (Move, int32) {
+	s := w.state
+	limits := &w.limits
+	startTime := w.startTime
 	result, found := transpositionTable.SearchState(s)
-	stateEvalGuess := lastMoveScore
+	stateEvalGuess := scoreGuess
 	if found {
 		stateEvalGuess = EvalLowToHigh(result.eval)
 	}
@@ -45,11 +128,13 @@ func (s *State) IterativeDeepiningSearch(maxTime time.Duration, debugPrint bool)
 	aspirationWindowHigh := stateEvalGuess + startingAsperationWindowOffset
 	aspirationDelta := startingAsperationWindowOffset
 	bestFoundMove := Move(0)
-	currentDepth := int32(1)
+	bestFoundScore := stateEvalGuess
+	// Half the helpers search one ply deeper so the threads spread out over the tree
+	currentDepth := int32(1 + w.id%2)
 	contendingMove := NilMove
 	stateScore := stateEvalGuess
 	lastSearchNodes := uint64(1)
-	for time.Since(startTime) < maxTime {
+	for bestFoundMove == Move(0) || (!w.stopRequested() && limits.withinTime(startTime) && limits.withinDepth(currentDepth) && limits.withinNodes(w.nodesSearched.Load())) {
 		if stateScore > mateValueCutoff {
 			aspirationWindowLow = mateValueCutoff
 			aspirationWindowHigh = max32
@@ -58,14 +143,21 @@ func (s *State) IterativeDeepiningSearch(maxTime time.Duration, debugPrint bool)
 			aspirationWindowHigh = -mateValueCutoff
 		}
 		if debugPrint {
-			fmt.Println("Search time left: ", maxTime-time.Since(startTime))
+			fmt.Println("Search time left: ", limits.maxTime-time.Since(startTime))
 			fmt.Printf("Searching next depth with window [%f, %f]\n", NormalizeEval(aspirationWindowLow), NormalizeEval(aspirationWindowHigh))
 		}
-		nodesSearched = 0
-		stateScore, contendingMove = s.NegaMax(currentDepth, aspirationWindowLow, aspirationWindowHigh, true, true, true)
-		totalNodes += nodesSearched
+		iterationStartNodes := w.nodesSearched.Load()
+		stateScore, contendingMove = w.NegaMax(currentDepth, aspirationWindowLow, aspirationWindowHigh, true, true, true)
+		iterationNodes := w.nodesSearched.Load() - iterationStartNodes
+		if w.aborted {
+			if debugPrint {
+				fmt.Println("Search aborted during depth", currentDepth)
+			}
+			stateScore = bestFoundScore
+			break
+		}
 		if debugPrint {
-			effectiveBranchFactor := float64(nodesSearched) / float64(lastSearchNodes)
+			effectiveBranchFactor := float64(iterationNodes) / float64(lastSearchNodes)
 			fmt.Printf("Searched to Depth: %d, Best Move: %s, Score: %s, EBF: %.2f\n", currentDepth, contendingMove.ShortString(), prettyEval(stateScore, s.turn), effectiveBranchFactor)
 		}
 		// Check if returned score was at bounds of aspiration window
@@ -96,7 +188,11 @@ func (s *State) IterativeDeepiningSearch(maxTime time.Duration, debugPrint bool)
 				fmt.Println("Searched Succeeded")
 			}
 			bestFoundMove = contendingMove
-			lastMoveScore = stateScore
+			bestFoundScore = stateScore
+			w.abortAllowed = true
+			if limits.timeManager != nil {
+				limits.timeManager.update(bestFoundMove, stateScore)
+			}
 			if currentDepth >= 5 {
 				aspirationDelta = startingAsperationWindowOffset / 2
 			} else {
@@ -104,35 +200,68 @@ func (s *State) IterativeDeepiningSearch(maxTime time.Duration, debugPrint bool)
 			}
 			aspirationWindowLow = stateScore - aspirationDelta
 			aspirationWindowHigh = stateScore + aspirationDelta
+			if reporter != nil {
+				reporter(SearchReport{currentDepth, stateScore, w.nodesSearched.Load(), w.tbHits.Load(), time.Since(startTime), s.getPVMoves()})
+			}
 			currentDepth += 1
-			lastSearchNodes = nodesSearched
+			lastSearchNodes = iterationNodes
 		}
 	}
-	fmt.Println("Best Move:", bestFoundMove.ShortString())
-	fmt.Println("Move Evaluation:", prettyEval(stateScore, s.turn))
-	fmt.Println("Expected Moves:", s.getPV())
-	fmt.Println("Total Nodes Searched:", totalNodes)
-	fmt.Println("Total Search Time:", time.Since(startTime))
-	fmt.Printf("Million Nodes per Second: %.2f\n", float64(totalNodes)/time.Since(startTime).Seconds()/1_000_000.0)
-	return bestFoundMove
+	return bestFoundMove, bestFoundScore
 }
 
-func (s *State) NegaMax(depth int32, alpha int32, beta int32, skipIID bool, skipNull bool, forceSearch bool) (int32, Move) {
-	s.searchParameters.trueDepth += 1
-	nodesSearched++
+func (limits *SearchLimits) withinTime(startTime time.Time) bool {
+	if limits.timeManager != nil && limits.timeManager.shouldStop(time.Since(startTime)) {
+		return false
+	}
+	return limits.maxTime == 0 || time.Since(startTime) < limits.maxTime
+}
+
+func (limits *SearchLimits) withinDepth(depth int32) bool {
+	if limits.maxDepth == 0 {
+		return depth <= MaxSearchDepth
+	}
+	return depth <= limits.maxDepth
+}
+
+func (limits *SearchLimits) withinNodes(nodes uint64) bool {
+	return limits.maxNodes == 0 || nodes < limits.maxNodes
+}
+
+func (w *SearchWorker) NegaMax(depth int32, alpha int32, beta int32, skipIID bool, skipNull bool, forceSearch bool) (int32, Move) {
+	s := w.state
+	w.trueDepth += 1
+	if w.pollStop(w.nodesSearched.Add(1)) {
+		w.trueDepth--
+		return 0, NilMove
+	}
 	if s.lastCapOrPawn >= 100 || s.repetitionMap.get(s.hashcode) >= 3 {
-		s.searchParameters.trueDepth--
+		w.trueDepth--
 		return 0, NilMove
 	}
+	if w.trueDepth >= MaxSearchPly-1 {
+		w.trueDepth--
+		return clampInt32(s.EvalState(s.turn), alpha, beta), NilMove
+	}
+	// Only probed right after a capture or pawn move as the tables do not know the fifty move count
+	if tablebases != nil && w.trueDepth > 0 && s.lastCapOrPawn == 0 && tablebases.CanProbe(s) {
+		if wdl, ok := tablebases.ProbeWDL(s); ok {
+			w.tbHits.Add(1)
+			score := tablebaseScore(wdl, w.trueDepth)
+			w.trueDepth--
+			return clampInt32(score, alpha, beta), NilMove
+		}
+	}
+	plyData := &w.plies[w.trueDepth]
 	result, found := transpositionTable.SearchState(s)
 	projectedBestMove := NilMove
 	if found {
 		ttEval := EvalLowToHigh(result.eval)
 		ttDepth, ttNodeType := result.depthAndNode.parseDepthandNode()
 		if ttNodeType == TerminalNode {
-			s.searchParameters.trueDepth--
+			w.trueDepth--
 			if ttEval == mateTranspositionValue {
-				return clampInt32(LowestEval+int32(s.searchParameters.trueDepth), alpha, beta), NilMove
+				return clampInt32(LowestEval+int32(w.trueDepth), alpha, beta), NilMove
 			} else {
 				return clampInt32(0, alpha, beta), NilMove
 			}
@@ -140,68 +269,79 @@ func (s *State) NegaMax(depth int32, alpha int32, beta int32, skipIID bool, skip
 		projectedBestMove = result.bestMove
 		if !forceSearch && ttDepth >= uint16(depth) {
 			if ttNodeType == AllNode && ttEval <= alpha {
-				s.searchParameters.trueDepth--
+				w.trueDepth--
 				return alpha, projectedBestMove
 			} else if ttNodeType == CutNode && ttEval >= beta {
-				s.searchParameters.trueDepth--
+				w.trueDepth--
 				return beta, projectedBestMove
 			} else if ttNodeType == pVNode && ttEval >= alpha && ttEval <= beta {
-				s.searchParameters.trueDepth--
+				w.trueDepth--
 				return ttEval, projectedBestMove
 			}
 		}
 	} else if depth > 5 && !skipIID {
-		_, projectedBestMove = s.NegaMax(depth/2, alpha, beta, true, true, true)
+		_, projectedBestMove = w.NegaMax(depth/2, alpha, beta, true, true, true)
+		if w.aborted {
+			w.trueDepth--
+			return 0, NilMove
+		}
 	}
 	if depth == 0 {
-		s.searchParameters.trueDepth--
-		qScore, qMove := s.QuiescenceSearch(alpha, beta)
+		w.trueDepth--
+		qScore, qMove := w.QuiescenceSearch(alpha, beta)
 		return qScore, qMove
 	}
 	futileNode := false
 	if depth == 1 && !s.check && alpha > -asperationMateSearchCutoff && beta < asperationMateSearchCutoff {
 		staticEval := s.EvalState(s.turn)
 		if staticEval < alpha-FutilityCutoff {
-			s.genAllMoves(false)
+			s.genAllMoves(false, &plyData.moveLists, &w.historyTable)
 			futileNode = true
 		} else {
-			s.genAllMoves(true)
+			s.genAllMoves(true, &plyData.moveLists, &w.historyTable)
 		}
 	} else {
-		s.genAllMoves(true)
+		s.genAllMoves(true, &plyData.moveLists, &w.historyTable)
 	}
-	if captureMoves.len() == 0 && quietMoves.len() == 0 && !futileNode {
+	if plyData.moveLists.captureMoves.len() == 0 && plyData.moveLists.quietMoves.len() == 0 && !futileNode {
 		if s.check {
-			eval := LowestEval + int32(s.searchParameters.trueDepth)
+			eval := LowestEval + int32(w.trueDepth)
 			transpositionTable.AddState(s, mateTranspositionValue, NilMove, uint16(depth), TerminalNode)
-			s.searchParameters.trueDepth--
+			w.trueDepth--
 			return clampInt32(eval, alpha, beta), NilMove
 		} else {
 			transpositionTable.AddState(s, stalemateTranpositionValue, NilMove, uint16(depth), TerminalNode)
-			s.searchParameters.trueDepth--
+			w.trueDepth--
 			return clampInt32(0, alpha, beta), NilMove
 		}
 	}
 	var moves []Move
 	if !futileNode {
-		moves = s.orderMoves(projectedBestMove)
+		moves = w.orderMoves(plyData, projectedBestMove)
 	} else {
-		moves = s.orderCaptureMoves()
-		if len(moves) == 0 {
-			s.searchParameters.trueDepth--
-			return alpha, NilMove
-		}
+		moves = w.orderCaptureMoves(plyData)
+	}
+	if w.trueDepth == 0 && w.rootMoves != nil {
+		moves = slices.DeleteFunc(moves, func(move Move) bool { return !slices.Contains(w.rootMoves, move) })
+	}
+	if len(moves) == 0 {
+		w.trueDepth--
+		return alpha, NilMove
 	}
 	if !s.check && depth > 2 && !skipNull {
 		friendIndex := s.turn * 6
 		hasNonPawn := s.sideOccupied[s.turn] & ^(s.board[friendIndex+King]|s.board[friendIndex+Pawn]) != 0
 		if hasNonPawn {
 			s.MakeMove(PassingMove)
-			score, _ := s.NegaMax(max(depth-NullMoveReduction-1, 1), -beta, -beta+1, false, false, false)
+			score, _ := w.NegaMax(max(depth-NullMoveReduction-1, 1), -beta, -beta+1, false, false, false)
 			score *= -1
 			s.UnMakeMove(PassingMove)
+			if w.aborted {
+				w.trueDepth--
+				return 0, NilMove
+			}
 			if score >= beta {
-				s.searchParameters.trueDepth--
+				w.trueDepth--
 				return beta, PassingMove
 			}
 		}
@@ -220,26 +360,30 @@ func (s *State) NegaMax(depth int32, alpha int32, beta int32, skipIID bool, skip
 		s.MakeMove(move)
 		score := int32(0)
 		if i == 0 {
-			score, _ = s.NegaMax(max(depth-reduction-1, 0), -beta, -alpha, false, false, false)
+			score, _ = w.NegaMax(max(depth-reduction-1, 0), -beta, -alpha, false, false, false)
 			score *= -1
 		} else {
-			score, _ = s.NegaMax(max(depth-reduction-1, 0), -alpha-1, -alpha, false, false, false)
+			score, _ = w.NegaMax(max(depth-reduction-1, 0), -alpha-1, -alpha, false, false, false)
 			score *= -1
 			if score > alpha && beta-alpha > 1 {
-				score, _ = s.NegaMax(depth-1, -beta, -alpha, false, false, false)
+				score, _ = w.NegaMax(depth-1, -beta, -alpha, false, false, false)
 				score *= -1
 			}
 		}
 		s.UnMakeMove(move)
+		if w.aborted {
+			w.trueDepth--
+			return 0, NilMove
+		}
 		if score >= beta {
 			transpositionTable.AddState(s, beta, move, uint16(depth), CutNode)
 			friendPiece := s.board.getColorPieceAt(move.OriginSquare(), s.turn)
 			enemyPiece := s.board.getColorPieceAt(move.DestinationSquare(), 1-s.turn)
 			if enemyPiece == NoPiece {
-				historyTable[friendPiece][move.DestinationSquare()] += uint64(depth * depth)
-				s.addKiller(move)
+				w.historyTable[friendPiece][move.DestinationSquare()] += uint64(depth * depth)
+				w.addKiller(move)
 			}
-			s.searchParameters.trueDepth--
+			w.trueDepth--
 			return beta, move
 		}
 		if score > alpha {
@@ -253,12 +397,15 @@ func (s *State) NegaMax(depth int32, alpha int32, beta int32, skipIID bool, skip
 	} else {
 		transpositionTable.AddState(s, alpha, bestMove, uint16(depth), pVNode)
 	}
-	s.searchParameters.trueDepth--
+	w.trueDepth--
 	return alpha, bestMove
 }
 
-func (s *State) QuiescenceSearch(alpha int32, beta int32) (int32, Move) {
-	nodesSearched++
+func (w *SearchWorker) QuiescenceSearch(alpha int32, beta int32) (int32, Move) {
+	s := w.state
+	if w.pollStop(w.nodesSearched.Add(1)) {
+		return 0, NilMove
+	}
 	standingPat := s.EvalState(s.turn)
 	if standingPat >= beta {
 		return beta, NilMove
@@ -266,20 +413,25 @@ func (s *State) QuiescenceSearch(alpha int32, beta int32) (int32, Move) {
 	if alpha < standingPat {
 		alpha = standingPat
 	}
-	s.genAllMoves(false)
-	captureMoves.sort()
-	moves := make([]Move, captureMoves.len())
-	for i, capture := range captureMoves.slice[0:captureMoves.len()] {
-		moves[i] = capture.move
+	w.trueDepth += 1
+	if w.trueDepth >= MaxSearchPly-1 {
+		w.trueDepth--
+		return alpha, NilMove
 	}
+	plyData := &w.plies[w.trueDepth]
+	s.genAllMoves(false, &plyData.moveLists, &w.historyTable)
 	bestMove := NilMove
-	for _, move := range moves {
+	for _, move := range w.orderCaptureMoves(plyData) {
 		s.MakeMove(move)
-		score, _ := s.QuiescenceSearch(-beta, -alpha)
+		score, _ := w.QuiescenceSearch(-beta, -alpha)
 		score *= -1
 		s.UnMakeMove(move)
-
+		if w.aborted {
+			w.trueDepth--
+			return 0, NilMove
+		}
 		if score >= beta {
+			w.trueDepth--
 			return beta, move
 		}
 		if score > alpha {
@@ -287,78 +439,61 @@ func (s *State) QuiescenceSearch(alpha int32, beta int32) (int32, Move) {
 			bestMove = move
 		}
 	}
+	w.trueDepth--
 	return alpha, bestMove
 }
 
-func (s *State) orderMoves(ttMove Move) []Move {
-	sortedMoves := make([]Move, captureMoves.len()+quietMoves.len())
-	totalIndex := 0
+// The returned slice belongs to the ply and stays valid until moves are ordered at the same ply again
+func (w *SearchWorker) orderMoves(plyData *PlyData, ttMove Move) []Move {
+	quietMoves := &plyData.moveLists.quietMoves
+	captureMoves := &plyData.moveLists.captureMoves
+	killers := plyData.killers
+	sortedMoves := plyData.orderedMoves[:0]
 	captureMoves.sort()
 	quietMoves.sort()
 	if ttMove != NilMove && ttMove != PassingMove {
-		sortedMoves[0] = ttMove
-		totalIndex++
+		sortedMoves = append(sortedMoves, ttMove)
 	}
 	badCutoff := captureMoves.len()
 	for i := uint16(0); i < captureMoves.len(); i++ {
 		if captureMoves.slice[i].captureValue >= 0 && captureMoves.slice[i].move != ttMove {
-			sortedMoves[totalIndex] = captureMoves.slice[i].move
-			totalIndex++
+			sortedMoves = append(sortedMoves, captureMoves.slice[i].move)
 		} else {
 			badCutoff = i
 			break
 		}
 	}
 	skipIndex := [2]int{-1, -1}
-	if int16(len(s.searchParameters.killerTable)) > s.searchParameters.trueDepth {
-		for i := 0; i < int(quietMoves.len()); i++ {
-			if quietMoves.slice[i].move == s.searchParameters.killerTable[s.searchParameters.trueDepth][0] && quietMoves.slice[i].move != ttMove {
-				sortedMoves[totalIndex] = s.searchParameters.killerTable[s.searchParameters.trueDepth][0]
-				skipIndex[0] = i
-				totalIndex++
-			} else if quietMoves.slice[i].move == s.searchParameters.killerTable[s.searchParameters.trueDepth][1] && quietMoves.slice[i].move != ttMove {
-				sortedMoves[totalIndex] = s.searchParameters.killerTable[s.searchParameters.trueDepth][1]
-				skipIndex[1] = i
-				totalIndex++
-			}
+	for i := 0; i < int(quietMoves.len()); i++ {
+		if quietMoves.slice[i].move == killers[0] && quietMoves.slice[i].move != ttMove {
+			sortedMoves = append(sortedMoves, killers[0])
+			skipIndex[0] = i
+		} else if quietMoves.slice[i].move == killers[1] && quietMoves.slice[i].move != ttMove {
+			sortedMoves = append(sortedMoves, killers[1])
+			skipIndex[1] = i
 		}
 	}
 	for i := 0; i < int(quietMoves.len()); i++ {
 		if i != skipIndex[0] && i != skipIndex[1] && quietMoves.slice[i].move != ttMove {
-			sortedMoves[totalIndex] = quietMoves.slice[i].move
-			totalIndex++
+			sortedMoves = append(sortedMoves, quietMoves.slice[i].move)
 		}
 	}
 	for i := badCutoff; i < captureMoves.len(); i++ {
 		if captureMoves.slice[i].move != ttMove {
-			sortedMoves[totalIndex] = captureMoves.slice[i].move
-			totalIndex++
+			sortedMoves = append(sortedMoves, captureMoves.slice[i].move)
 		}
 	}
+	plyData.orderedMoves = sortedMoves
 	return sortedMoves
 }
 
-func (s *State) orderCaptureMoves() []Move {
-	sortedMoves := make([]Move, captureMoves.len())
+func (w *SearchWorker) orderCaptureMoves(plyData *PlyData) []Move {
+	captureMoves := &plyData.moveLists.captureMoves
+	sortedMoves := plyData.orderedMoves[:0]
 	captureMoves.sort()
-	for i, capture := range captureMoves.slice[0:captureMoves.len()] {
-		sortedMoves[i] = capture.move
+	for _, capture := range captureMoves.slice[0:captureMoves.len()] {
+		sortedMoves = append(sortedMoves, capture.move)
 	}
+	plyData.orderedMoves = sortedMoves
 	return sortedMoves
-
-}
-
-func (s *State) addKiller(move Move) {
-	if s.searchParameters.trueDepth > int16(len(s.searchParameters.killerTable)-1) {
-		killerTable := make(KillerTable, len(s.searchParameters.killerTable)*2)
-		for i := range len(s.searchParameters.killerTable) {
-			killerTable[i][0] = s.searchParameters.killerTable[i][0]
-			killerTable[i][1] = s.searchParameters.killerTable[i][1]
-			killerTable[len(s.searchParameters.killerTable)+i][0] = NilMove
-			killerTable[len(s.searchParameters.killerTable)+i][1] = NilMove
-		}
-		s.searchParameters.killerTable = killerTable
-	}
-	s.searchParameters.killerTable[s.searchParameters.trueDepth][1] = s.searchParameters.killerTable[s.searchParameters.trueDepth][0]
-	s.searchParameters.killerTable[s.searchParameters.trueDepth][0] = move
 }
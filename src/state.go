@@ -2,18 +2,11 @@ package main
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
-	"strings"
 )
 
-type KillerTable [][2]Move
-
-// TODO migrate to worker when added instead of being linked to a state
-type SearchParameters struct {
-	killerTable KillerTable
-	trueDepth   int16 // The true depth from the root node
-}
-
 type PinInfo struct {
 	pinnedBoards [2]Bitboard
 	pinners      [2][8]Square
@@ -37,12 +30,15 @@ type State struct {
 	lastCapOrPawn          uint16
 	ply                    uint16
 	castleAvailability     CastleAvailability
+	castleRookSquares      [4]Square   // Starting squares of the castling rooks, the corners unless playing Chess960
+	castleEmptyBoards      [4]Bitboard // Squares the king and rook cross that must be empty to castle
+	castleSafeBoards       [4]Bitboard // Squares the king crosses that must not be attacked
 	castleHistory          CastleHistory
 	fiftyMoveHistory       FiftyMoveHistory
 	repetitionMap          *RepetitionMap
 	hashcode               uint64
 	hashHistory            *HashHistory
-	searchParameters       SearchParameters
+	accumulators           *AccumulatorStack // Nil unless the state is evaluated by a network
 }
 
 type SafetyCheckBoards struct {
@@ -64,17 +60,17 @@ type CaptureMove struct {
 	captureValue int32
 }
 
-// To be added to eventual worker struct
-var quietMoves QuietMoveList = newQuietMoveList(100)
-var captureMoves CaptureMoveList = newCaptureMoveList(50)
+// Never written to so it can be shared by every caller that does not need history ordering
+var emptyHistoryTable HistoryTable = HistoryTable{}
 
 func (s *State) MakeMove(move Move) {
 	s.lastCapOrPawn += 1
 	friendIndex := s.turn * 6
 	enemyIndex := (1 - s.turn) * 6
-	if s.canEnpassant {
+	if s.enPassantHashed() {
 		s.hashcode ^= enPassantHashes[s.enPassantSquare%8]
 	}
+	s.accumulators.push()
 	if move != PassingMove {
 		startSquare := move.OriginSquare()
 		startBoard := boardFromSquare(startSquare)
@@ -104,56 +100,77 @@ func (s *State) MakeMove(move Move) {
 		*startBoardPtr |= desBoard
 		s.sideOccupied[s.turn] ^= startBoard
 		s.sideOccupied[s.turn] |= desBoard
+		s.accumulators.removePiece(startBoardIndex, startSquare)
+		s.accumulators.addPiece(startBoardIndex, desSquare)
 		isCapture := false
 		if desBoardPtr != nil {
 			*desBoardPtr ^= desBoard
+			s.accumulators.removePiece(desBoardIndex, desSquare)
 			s.sideOccupied[1-s.turn] ^= desBoard
 			s.captureHistory.Push(uint8(desBoardIndex), s.ply)
 			s.fiftyMoveHistory.Push(s.lastCapOrPawn-1, s.ply)
 			s.lastCapOrPawn = 0
 			isCapture = true
 			s.hashcode ^= squareHashes[desBoardIndex][desSquare]
+			// Capturing a rook that has not moved takes away the enemy's castling on that side
+			if desBoardIndex == int(enemyIndex)+Rook {
+				enemy := 1 - s.turn
+				if desSquare == s.castleRookSquares[enemy] && s.castleAvailability[enemy] {
+					s.castleAvailability[enemy] = false
+					s.hashcode ^= castleHashes[enemy]
+					s.castleHistory.Push(enemy, s.ply)
+				}
+				if desSquare == s.castleRookSquares[enemy+2] && s.castleAvailability[enemy+2] {
+					s.castleAvailability[enemy+2] = false
+					s.hashcode ^= castleHashes[enemy+2]
+					s.castleHistory.Push(enemy+2, s.ply)
+				}
+			}
 		}
 		specialMove := move.SpecialMove()
 		if specialMove == CastleSpecialMove {
 			rankIndex := Square(s.turn * 56)
 			rookSquare := Square(5 + rankIndex)
-			startingRookSquare := Square(7 + rankIndex)
+			castle := s.turn
 			if desSquare == Square(2) || desSquare == Square(58) {
-				s.castleAvailability[s.turn+2] = false
-				s.hashcode ^= castleHashes[s.turn+2]
-				s.castleHistory.Push(s.turn+2, s.ply)
 				rookSquare = Square(3 + rankIndex)
-				startingRookSquare = Square(0 + rankIndex)
-			} else {
-				s.castleAvailability[s.turn] = false
-				s.hashcode ^= castleHashes[s.turn]
-				s.castleHistory.Push(s.turn, s.ply)
+				castle = s.turn + 2
 			}
+			startingRookSquare := s.castleRookSquares[castle]
+			s.castleAvailability[castle] = false
+			s.hashcode ^= castleHashes[castle]
+			s.castleHistory.Push(castle, s.ply)
 			startRookBoard := boardFromSquare(startingRookSquare)
 			endRookBoard := boardFromSquare(rookSquare)
 			s.board[friendIndex+Rook] ^= startRookBoard
 			s.board[friendIndex+Rook] |= endRookBoard
-			s.sideOccupied[s.turn] ^= startRookBoard
-			s.sideOccupied[s.turn] |= endRookBoard
+			// In Chess960 the king can land on the rook's starting square and the rook on the king's
+			s.sideOccupied[s.turn] = s.sideOccupied[s.turn]&^startRookBoard | endRookBoard | desBoard
 			s.hashcode ^= squareHashes[friendIndex+Rook][startingRookSquare] ^ squareHashes[friendIndex+Rook][rookSquare]
+			s.accumulators.removePiece(int(friendIndex)+Rook, startingRookSquare)
+			s.accumulators.addPiece(int(friendIndex)+Rook, rookSquare)
 		} else if specialMove == PromotionSpecialMove {
 			s.hashcode ^= squareHashes[startBoardIndex][desSquare]
 			promotionType := move.PromotionType()
 			if promotionType == QueenPromotion {
 				s.board[friendIndex+Queen] |= desBoard
 				s.hashcode ^= squareHashes[friendIndex+Queen][desSquare]
+				s.accumulators.addPiece(int(friendIndex)+Queen, desSquare)
 			} else if promotionType == RookPromotion {
 				s.board[friendIndex+Rook] |= desBoard
 				s.hashcode ^= squareHashes[friendIndex+Rook][desSquare]
+				s.accumulators.addPiece(int(friendIndex)+Rook, desSquare)
 			} else if promotionType == KnightPromotion {
 				s.board[friendIndex+Knight] |= desBoard
 				s.hashcode ^= squareHashes[friendIndex+Knight][desSquare]
+				s.accumulators.addPiece(int(friendIndex)+Knight, desSquare)
 			} else if promotionType == BishopPromotion {
 				s.board[friendIndex+Bishop] |= desBoard
 				s.hashcode ^= squareHashes[friendIndex+Bishop][desSquare]
+				s.accumulators.addPiece(int(friendIndex)+Bishop, desSquare)
 			}
 			*startBoardPtr ^= desBoard
+			s.accumulators.removePiece(startBoardIndex, desSquare)
 		} else if specialMove == EnPassantSpacialMove {
 			enemyPawnBoard := &s.board[enemyIndex+Pawn]
 			relativeDownStep := DownStep
@@ -165,6 +182,7 @@ func (s *State) MakeMove(move Move) {
 			*enemyPawnBoard ^= enPassantCaptureBoard
 			s.sideOccupied[1-s.turn] ^= enPassantCaptureBoard
 			s.hashcode ^= squareHashes[enemyIndex+Pawn][enPassantCaptureSquare]
+			s.accumulators.removePiece(int(enemyIndex)+Pawn, enPassantCaptureSquare)
 			s.captureHistory.Push(enemyIndex+Pawn, s.ply)
 		}
 		s.canEnpassant = false
@@ -178,7 +196,9 @@ func (s *State) MakeMove(move Move) {
 					s.enPassantSquare = startSquare.Step(DownStep)
 				}
 				s.enPassantSquareHistory.Push(s.enPassantSquare, s.ply)
-				s.hashcode ^= enPassantHashes[s.enPassantSquare%8]
+				if pawnAttackBoards[s.turn][s.enPassantSquare]&s.board[enemyIndex+Pawn] != 0 {
+					s.hashcode ^= enPassantHashes[s.enPassantSquare%8]
+				}
 				s.canEnpassant = true
 			}
 			if !isCapture {
@@ -197,17 +217,20 @@ func (s *State) MakeMove(move Move) {
 				s.castleHistory.Push(s.turn+2, s.ply)
 			}
 		} else if startBoardIndex == int(friendIndex)+Rook {
-			if startSquare == Square(7+(8*s.turn)) && s.castleAvailability[s.turn] {
+			if startSquare == s.castleRookSquares[s.turn] && s.castleAvailability[s.turn] {
 				s.castleAvailability[s.turn] = false
 				s.hashcode ^= castleHashes[s.turn]
 				s.castleHistory.Push(s.turn, s.ply)
 			}
-			if startSquare == Square(8*s.turn) && s.castleAvailability[s.turn+2] {
+			if startSquare == s.castleRookSquares[s.turn+2] && s.castleAvailability[s.turn+2] {
 				s.castleAvailability[s.turn+2] = false
 				s.hashcode ^= castleHashes[s.turn+2]
 				s.castleHistory.Push(s.turn+2, s.ply)
 			}
 		}
+		if startBoardIndex == int(friendIndex)+King {
+			s.accumulators.kingMoved(s, s.turn)
+		}
 		s.occupied = s.sideOccupied[0] | s.sideOccupied[1]
 		s.notOccupied = ^s.occupied
 		s.pinInfo.pinsSet[0] = false
@@ -217,7 +240,7 @@ func (s *State) MakeMove(move Move) {
 		s.enPassantSquare = Square(100)
 	}
 	s.turn = 1 - s.turn
-	s.hashcode ^= blackHash
+	s.hashcode ^= turnHash
 	s.hashHistory.Push(s.hashcode)
 	enemyKingBoard := s.board[enemyIndex+King]
 	enemyBoard := enemyKingBoard | s.board[enemyIndex+Queen] | s.board[enemyIndex+Rook] | s.board[enemyIndex+Bishop] | s.board[enemyIndex+Knight] | s.board[enemyIndex+Pawn]
@@ -235,6 +258,7 @@ func (s *State) MakeMove(move Move) {
 
 func (s *State) UnMakeMove(move Move) {
 	s.repetitionMap.remove(s.hashcode)
+	s.accumulators.pop()
 	friendIndex := s.turn * 6
 	enemyIndex := (1 - s.turn) * 6
 	s.lastCapOrPawn -= 1
@@ -250,10 +274,11 @@ func (s *State) UnMakeMove(move Move) {
 				desBoardPtr = board
 			}
 		}
-		*desBoardPtr |= startBoard
+		// Cleared before the origin is set as a Chess960 king can castle without moving
 		*desBoardPtr ^= desBoard
-		s.sideOccupied[1-s.turn] |= startBoard
+		*desBoardPtr |= startBoard
 		s.sideOccupied[1-s.turn] ^= desBoard
+		s.sideOccupied[1-s.turn] |= startBoard
 		if s.ply-1 == s.captureHistory.MostRecentCapturePly() {
 			capture := s.captureHistory.Pop()
 			capturedPiece := capture.piece
@@ -265,17 +290,16 @@ func (s *State) UnMakeMove(move Move) {
 		if specialMove == CastleSpecialMove {
 			rankIndex := Square((1 - s.turn) * 56)
 			startingRookSquare := Square(5 + rankIndex)
-			endingRookSquare := Square(7 + rankIndex)
+			endingRookSquare := s.castleRookSquares[1-s.turn]
 			if desSquare == Square(2) || desSquare == Square(58) {
 				startingRookSquare = Square(3 + rankIndex)
-				endingRookSquare = Square(0 + rankIndex)
+				endingRookSquare = s.castleRookSquares[3-s.turn]
 			}
 			startingRookBoard := boardFromSquare(startingRookSquare)
 			endingRookBoard := boardFromSquare(endingRookSquare)
 			s.board[enemyIndex+Rook] ^= startingRookBoard
 			s.board[enemyIndex+Rook] |= endingRookBoard
-			s.sideOccupied[1-s.turn] ^= startingRookBoard
-			s.sideOccupied[1-s.turn] |= endingRookBoard
+			s.sideOccupied[1-s.turn] = s.sideOccupied[1-s.turn]&^startingRookBoard | endingRookBoard | startBoard
 		} else if specialMove == EnPassantSpacialMove {
 			relativeUpStep := UpStep
 			if s.turn == Black {
@@ -394,38 +418,13 @@ func (s *State) ensurePins(perspective uint8) {
 	}
 }
 
-func (s *State) quickGenMoves() *[]Move {
-	s.genAllMoves(true)
-	moves := make([]Move, captureMoves.len()+quietMoves.len())
-	totalIndex := 0
-	badCutoff := captureMoves.len()
-	for i := uint16(0); i < captureMoves.len(); i++ {
-		if captureMoves.slice[i].captureValue >= 0 {
-			moves[totalIndex] = captureMoves.slice[i].move
-			totalIndex++
-		} else {
-			badCutoff = i
-			break
-		}
-	}
-	for i := 0; i < int(quietMoves.len()); i++ {
-		moves[totalIndex] = quietMoves.slice[i].move
-		totalIndex++
-	}
-	for i := badCutoff; i < captureMoves.len(); i++ {
-		moves[totalIndex] = captureMoves.slice[i].move
-		totalIndex++
-	}
-	return &moves
-}
-
-func (s *State) genAllMoves(includeQuiets bool) {
+func (s *State) genAllMoves(includeQuiets bool, lists *MoveLists, historyTable *HistoryTable) {
 	// We want the pop function to pop the the bits at the top of the board relative to whos turn
 	// it is. So when it's white's turn we pop the most significant bit first and with black
 	// we pop the least significant bit first
 	s.ensurePins(s.turn)
-	quietMoves.reset()
-	captureMoves.reset()
+	lists.quietMoves.reset()
+	lists.captureMoves.reset()
 	var friendIndex uint8 = s.turn * 6
 	var enemyIndex uint8 = (1 - s.turn) * 6
 	friendBoard := s.sideOccupied[s.turn]
@@ -453,13 +452,13 @@ func (s *State) genAllMoves(includeQuiets bool) {
 			for sliderAttacks != 0 {
 				attackSquare := PopLSB(&sliderAttacks)
 				attackedPiece := s.board.getPieceAt(attackSquare)
-				captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Bishop]})
+				lists.captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Bishop]})
 			}
 			if includeQuiets {
 				sliderQuiets := sliderMoves & s.notOccupied & safeSquares & checkBlockerSquares
 				for sliderQuiets != 0 {
 					quietSquare := PopLSB(&sliderQuiets)
-					quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Bishop][quietSquare]})
+					lists.quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Bishop][quietSquare]})
 				}
 			}
 		}
@@ -477,13 +476,13 @@ func (s *State) genAllMoves(includeQuiets bool) {
 				for knightAttacks != 0 {
 					attackSquare := PopLSB(&knightAttacks)
 					attackedPiece := s.board.getColorPieceAt(attackSquare, 1-s.turn)
-					captureMoves.addMove(CaptureMove{BuildSimpleMove(knightSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Knight]})
+					lists.captureMoves.addMove(CaptureMove{BuildSimpleMove(knightSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Knight]})
 				}
 				if includeQuiets {
 					knightQuiets := knightMoves & notOccupied & checkBlockerSquares
 					for knightQuiets != 0 {
 						quietSquare := PopLSB(&knightQuiets)
-						quietMoves.addMove(QuietMove{BuildSimpleMove(knightSquare, quietSquare), historyTable[pieceIndex][quietSquare]})
+						lists.quietMoves.addMove(QuietMove{BuildSimpleMove(knightSquare, quietSquare), historyTable[pieceIndex][quietSquare]})
 					}
 				}
 			}
@@ -501,13 +500,13 @@ func (s *State) genAllMoves(includeQuiets bool) {
 			for sliderAttacks != 0 {
 				attackSquare := PopLSB(&sliderAttacks)
 				attackedPiece := s.board.getPieceAt(attackSquare)
-				captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Queen]})
+				lists.captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Queen]})
 			}
 			if includeQuiets {
 				sliderQuiets := sliderMoves & s.notOccupied & safeSquares & checkBlockerSquares
 				for sliderQuiets != 0 {
 					quietSquare := PopLSB(&sliderQuiets)
-					quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Queen][quietSquare]})
+					lists.quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Queen][quietSquare]})
 				}
 			}
 		}
@@ -524,13 +523,13 @@ func (s *State) genAllMoves(includeQuiets bool) {
 			for sliderAttacks != 0 {
 				attackSquare := PopLSB(&sliderAttacks)
 				attackedPiece := s.board.getPieceAt(attackSquare)
-				captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Rook]})
+				lists.captureMoves.addMove(CaptureMove{BuildSimpleMove(sliderSquare, attackSquare), valueTable[attackedPiece%6] - valueTable[Rook]})
 			}
 			if includeQuiets {
 				sliderQuiets := sliderMoves & s.notOccupied & safeSquares & checkBlockerSquares
 				for sliderQuiets != 0 {
 					quietSquare := PopLSB(&sliderQuiets)
-					quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Rook][quietSquare]})
+					lists.quietMoves.addMove(QuietMove{BuildSimpleMove(sliderSquare, quietSquare), historyTable[friendIndex+Rook][quietSquare]})
 				}
 			}
 		}
@@ -558,18 +557,18 @@ func (s *State) genAllMoves(includeQuiets bool) {
 				if attackSquare == s.enPassantSquare {
 					if s.canEnpassant {
 						if s.EnPassantSafetyCheck(pawnSquare, attackSquare, friendIndex, enemyIndex, occupied) {
-							captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, EnPassantSpacialMove), 0})
+							lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, EnPassantSpacialMove), 0})
 						}
 					}
 				} else {
 					attackValue := valueTable[attackedPiece%6] - valueTable[Pawn]
 					if attackSquare.Rank() == promotionRank {
-						captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, PromotionSpecialMove), attackValue})
-						captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 1, PromotionSpecialMove), attackValue})
-						captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 2, PromotionSpecialMove), attackValue})
-						captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 3, PromotionSpecialMove), attackValue})
+						lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, PromotionSpecialMove), attackValue})
+						lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 1, PromotionSpecialMove), attackValue})
+						lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 2, PromotionSpecialMove), attackValue})
+						lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 3, PromotionSpecialMove), attackValue})
 					} else {
-						captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, 0), attackValue})
+						lists.captureMoves.addMove(CaptureMove{BuildMove(pawnSquare, attackSquare, 0, 0), attackValue})
 					}
 				}
 			}
@@ -579,12 +578,12 @@ func (s *State) genAllMoves(includeQuiets bool) {
 					desSquare := PopLSB(&pawnMoves)
 					historyValue := historyTable[Pawn+friendIndex][desSquare]
 					if desSquare.Rank() == promotionRank {
-						quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 0, PromotionSpecialMove), historyValue})
-						quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 1, PromotionSpecialMove), historyValue})
-						quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 2, PromotionSpecialMove), historyValue})
-						quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 3, PromotionSpecialMove), historyValue})
+						lists.quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 0, PromotionSpecialMove), historyValue})
+						lists.quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 1, PromotionSpecialMove), historyValue})
+						lists.quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 2, PromotionSpecialMove), historyValue})
+						lists.quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 3, PromotionSpecialMove), historyValue})
 					} else {
-						quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 0, 0), historyValue})
+						lists.quietMoves.addMove(QuietMove{BuildMove(pawnSquare, desSquare, 0, 0), historyValue})
 					}
 				}
 			}
@@ -601,7 +600,7 @@ func (s *State) genAllMoves(includeQuiets bool) {
 		desSquare := PopLSB(&kingAttacks)
 		attackedPiece := s.board.getColorPieceAt(desSquare, 1-s.turn)
 		if isSquareSafe(desSquare, noKingFriendBoard, safetyCheckBoard, s.turn) {
-			captureMoves.addMove(CaptureMove{BuildSimpleMove(kingSquare, desSquare), valueTable[attackedPiece%6] - valueTable[King]})
+			lists.captureMoves.addMove(CaptureMove{BuildSimpleMove(kingSquare, desSquare), valueTable[attackedPiece%6] - valueTable[King]})
 		}
 	}
 	if includeQuiets {
@@ -609,33 +608,33 @@ func (s *State) genAllMoves(includeQuiets bool) {
 		for kingQuiets != 0 {
 			desSquare := PopLSB(&kingQuiets)
 			if isSquareSafe(desSquare, noKingFriendBoard, safetyCheckBoard, s.turn) {
-				quietMoves.addMove(QuietMove{BuildSimpleMove(kingSquare, desSquare), historyTable[King+friendIndex][desSquare]})
+				lists.quietMoves.addMove(QuietMove{BuildSimpleMove(kingSquare, desSquare), historyTable[King+friendIndex][desSquare]})
 			}
 		}
 	}
 	// Castle Start
 	if !s.check && includeQuiets {
-		rankIndex := s.turn * 56
-		// King Castle Start
-		if s.castleAvailability[s.turn] {
-			if occupied&Bitboard(0x60<<rankIndex) == 0 && s.board[friendIndex+Rook]&Bitboard(0x80<<rankIndex) != 0 {
-				desSquare := kingSquare + 2
-				if isSquareSafe(Square(5+rankIndex), noKingFriendBoard, safetyCheckBoard, s.turn) && isSquareSafe(desSquare, noKingFriendBoard, safetyCheckBoard, s.turn) {
-					quietMoves.addMove(QuietMove{BuildMove(kingSquare, desSquare, 0, CastleSpecialMove), historyTable[King+friendIndex][desSquare]})
-				}
-			}
-		}
-		// King Castle End
-		// Queen Castle Start
-		if s.castleAvailability[s.turn+2] {
-			if occupied&Bitboard(0xE<<rankIndex) == 0 && s.board[friendIndex+Rook]&Bitboard(0x1<<rankIndex) != 0 {
-				desSquare := kingSquare - 2
-				if isSquareSafe(Square(3+rankIndex), noKingFriendBoard, safetyCheckBoard, s.turn) && isSquareSafe(desSquare, noKingFriendBoard, safetyCheckBoard, s.turn) {
-					quietMoves.addMove(QuietMove{BuildMove(kingSquare, desSquare, 0, CastleSpecialMove), historyTable[King+friendIndex][desSquare]})
+		rankIndex := Square(s.turn * 56)
+		// King side first, then queen side
+		for _, castle := range [2]uint8{s.turn, s.turn + 2} {
+			rookBoard := boardFromSquare(s.castleRookSquares[castle])
+			if !s.castleAvailability[castle] || occupied&s.castleEmptyBoards[castle] != 0 || s.board[friendIndex+Rook]&rookBoard == 0 {
+				continue
+			}
+			// Once the rook moves it no longer shields the king's path, which matters in Chess960
+			castleFriendBoard := noKingFriendBoard &^ rookBoard
+			safe := true
+			for path := s.castleSafeBoards[castle]; path != 0 && safe; {
+				safe = isSquareSafe(PopLSB(&path), castleFriendBoard, safetyCheckBoard, s.turn)
+			}
+			if safe {
+				desSquare := rankIndex + 6
+				if castle >= 2 {
+					desSquare = rankIndex + 2
 				}
+				lists.quietMoves.addMove(QuietMove{BuildMove(kingSquare, desSquare, 0, CastleSpecialMove), historyTable[King+friendIndex][desSquare]})
 			}
 		}
-		// Queen Castle End
 	}
 	// Castle End
 	// End King
@@ -824,125 +823,60 @@ func (s *State) GetCheckBlockerSquares(square Square, friendBoard Bitboard, enem
 	return safeSquares, enPassantCheckBlockerSquares
 }
 
+// Panics on invalid input, positions from users or files should go through ParseFEN instead
 func FenState(fenString string) *State {
-	pieceMap := map[rune]int{'K': 0, 'Q': 1, 'R': 2, 'B': 3, 'N': 4, 'P': 5, 'k': 6, 'q': 7, 'r': 8, 'b': 9, 'n': 10, 'p': 11}
-	splitFenString := strings.Split(fenString, " ")
-	boardString := strings.Split(splitFenString[0], "/")
-	board := Board{}
-	for i := 0; i < 8; i++ {
-		r := boardString[7-i]
-		column := 0
-		for _, c := range r {
-			_, ok := pieceMap[c]
-			if ok {
-				board[pieceMap[c]] |= 1 << (i*8 + column)
-			} else {
-				num, err := strconv.Atoi(string(c))
-				if err != nil {
-					panic("Invalid Fen String (Invalid Piece Structure)")
-				}
-				column += num - 1
-			}
-			column++
-		}
-	}
-	sideOccupied := [2]Bitboard{EmptyBitboard, EmptyBitboard}
-	occupied := EmptyBitboard
-	for i := 0; i < 6; i++ {
-		sideOccupied[0] |= board[i]
-		sideOccupied[1] |= board[6+i]
-	}
-	occupied = sideOccupied[0] | sideOccupied[1]
-	turnString := splitFenString[1]
-	turn := uint8(0)
-	if turnString == "b" {
-		turn = 1
-	} else if turnString != "w" {
-		panic("Invalid Fen String (Invalid Turn)")
-	}
-	castleAvailability := CastleAvailability{}
-	castleString := splitFenString[2]
-	if castleString != "-" {
-		castleOptions := [4]rune{'K', 'k', 'Q', 'q'}
-		for i, r := range castleOptions {
-			for _, c := range castleString {
-				if r == c {
-					castleAvailability[i] = true
-				}
-			}
-		}
-	}
-	var enPassantSquare Square
-	enpassantString := splitFenString[3]
-	canEnpassant := false
-	if enpassantString != "-" {
-		canEnpassant = true
-		rankMap := map[rune]int{'a': 0, 'b': 1, 'c': 2, 'd': 3, 'e': 4, 'f': 5, 'g': 6, 'h': 7}
-		fileRune := enpassantString[0]
-		file, ok := rankMap[rune(fileRune)]
-		if !ok {
-			panic("Invalid Fen String (Invalid En Passant Square)")
-		}
-		rankRune := enpassantString[1]
-		rank, err := strconv.Atoi(string(rankRune))
-		if err != nil {
-			panic("Invalid Fen String (Invalid En Passant Square)")
-		}
-		enPassantSquare = Square(rank*8 + file)
-
-	}
-	halfMoveClock, err := strconv.Atoi(splitFenString[4])
-	if err != nil {
-		panic("Invalid Fen String (Invalid half move clock)")
-	}
-	fullMoveNumber, err := strconv.Atoi(splitFenString[5])
+	s, err := ParseFEN(fenString)
 	if err != nil {
-		panic("Invalid Fen String (Invalid full move number)")
-	}
-	ply := uint16((fullMoveNumber - 1) * 2)
-	if turn == Black {
-		ply += 1
-	}
-	killerTable := make(KillerTable, 5)
-	for i := range len(killerTable) {
-		killerTable[i][0] = NilMove
-		killerTable[i][1] = NilMove
-	}
-	searchParameters := SearchParameters{killerTable: killerTable, trueDepth: -1}
-	fiftyMoveRule := newFiftyMoveRuleHistory(104)
-	repetitionMap := make(RepetitionMap, 50)
-	hashHistory := NewHashHistory(5)
-	pinInfo := PinInfo{pinnedBoards: [2]Bitboard{}, pinners: [2][8]Square{}, pinsSet: [2]bool{false, false}}
-	s := &State{
-		board:                  board,
-		sideOccupied:           sideOccupied,
-		occupied:               occupied,
-		notOccupied:            ^occupied,
-		pinInfo:                pinInfo,
-		turn:                   turn,
-		enPassantSquare:        enPassantSquare,
-		check:                  false,
-		captureHistory:         NewCaptureHistory(32),
-		canEnpassant:           canEnpassant,
-		enPassantSquareHistory: NewEnpassantHistory(16),
-		lastCapOrPawn:          uint16(halfMoveClock),
-		ply:                    ply,
-		castleAvailability:     castleAvailability,
-		castleHistory:          NewCastleHistory(4),
-		fiftyMoveHistory:       fiftyMoveRule,
-		repetitionMap:          &repetitionMap,
-		hashHistory:            hashHistory,
-		searchParameters:       searchParameters,
+		panic(err)
 	}
-	s.hashcode = s.hash()
-	s.hashHistory.Push(s.hashcode)
-	s.ensurePins(White)
-	s.ensurePins(Black)
 	return s
 }
 
+// Deep copy so the result can be searched independently of the original
+func (s *State) Copy() *State {
+	copied := *s
+	copied.captureHistory.slice = slices.Clone(s.captureHistory.slice)
+	copied.enPassantSquareHistory.slice = slices.Clone(s.enPassantSquareHistory.slice)
+	copied.castleHistory.slice = slices.Clone(s.castleHistory.slice)
+	copied.fiftyMoveHistory.slice = slices.Clone(s.fiftyMoveHistory.slice)
+	repetitionMap := maps.Clone(*s.repetitionMap)
+	copied.repetitionMap = &repetitionMap
+	copied.hashHistory = &HashHistory{slice: slices.Clone(s.hashHistory.slice), currentIndex: s.hashHistory.currentIndex}
+	copied.accumulators = s.accumulators.copyTop()
+	return &copied
+}
+
+// Returns the result ("1-0", "0-1" or "1/2-1/2") and the reason if the game is over
+func (s *State) gameResult() (string, string, bool) {
+	if len(LegalMoves(s)) == 0 {
+		if !s.check {
+			return "1/2-1/2", "Stalemate", true
+		} else if s.turn == White {
+			return "0-1", "Black mates", true
+		}
+		return "1-0", "White mates", true
+	} else if s.lastCapOrPawn >= 100 {
+		return "1/2-1/2", "Draw by 50 move rule", true
+	} else if s.repetitionMap.get(s.hashcode) >= 3 {
+		return "1/2-1/2", "Draw by 3 fold repetition", true
+	}
+	return "", "", false
+}
+
+const startingFenString = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
 func StartingFen() *State {
-	return FenState("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	return FenState(startingFenString)
+}
+
+// Starting square of the rook moved by a castling move of either side, which the king captures in
+// Chess960 notation
+func (s *State) castleRookSquare(move Move) Square {
+	castle := move.OriginSquare().Rank() / 7
+	if move.DestinationSquare().File() == 2 {
+		castle += 2
+	}
+	return s.castleRookSquares[castle]
 }
 
 func (s *State) fenString() string {
@@ -978,17 +912,10 @@ func (s *State) fenString() string {
 		output += " b "
 	}
 	castleString := ""
-	if s.castleAvailability[0] {
-		castleString += "K"
-	}
-	if s.castleAvailability[2] {
-		castleString += "Q"
-	}
-	if s.castleAvailability[1] {
-		castleString += "k"
-	}
-	if s.castleAvailability[3] {
-		castleString += "q"
+	for _, castle := range [4]int{0, 2, 1, 3} {
+		if s.castleAvailability[castle] {
+			castleString += s.castleRightString(castle)
+		}
 	}
 	if castleString != "" {
 		output += castleString + " "
@@ -1027,6 +954,14 @@ func (s *State) String() string {
 
 func (s *State) getPV() string {
 	pvString := ""
+	for _, move := range s.getPVMoves() {
+		pvString += move.ShortString() + " "
+	}
+	return pvString
+}
+
+// Follows best moves stored in the transposition table from the current state
+func (s *State) getPVMoves() []Move {
 	moveStack := make([]Move, 0, 30)
 	var result TableData
 	var found bool
@@ -1036,8 +971,7 @@ func (s *State) getPV() string {
 		_, dupFound := seenBoards[s.hashcode]
 		if found && !dupFound {
 			bestMove := result.bestMove
-			if bestMove != NilMove {
-				pvString += bestMove.ShortString() + " "
+			if bestMove != NilMove && bestMove != PassingMove {
 				moveStack = append(moveStack, bestMove)
 				seenBoards[s.hashcode] = s.hashcode
 				s.MakeMove(bestMove)
@@ -1053,5 +987,5 @@ func (s *State) getPV() string {
 		s.UnMakeMove(moveStack[stackPointer])
 		stackPointer--
 	}
-	return pvString
+	return moveStack
 }
@@ -0,0 +1,1203 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	syzygyMaxPieces = 7
+	syzygyMaxDTZ    = 1 << 18
+
+	// Results from the point of view of the side to move. Cursed wins and blessed losses are
+	// wins and losses that the fifty move rule turns into draws
+	WDLLoss        = -2
+	WDLBlessedLoss = -1
+	WDLDraw        = 0
+	WDLCursedWin   = 1
+	WDLWin         = 2
+
+	// Far enough from mate scores that a mate found by the search is always preferred
+	tablebaseWinValue int32 = CentiPawn * 20000
+)
+
+type syzygyProbeResult int
+
+const (
+	syzygyOK syzygyProbeResult = iota
+	syzygyFail
+	syzygyChangeSideToMove // DTZ tables only store one side to move
+	syzygyZeroingBestMove  // The best move resets the fifty move counter so DTZ can not be read from the table
+)
+
+// Flags of the compressed data for one side to move and leading pawn file
+const (
+	syzygyFlagSideToMove  = 1
+	syzygyFlagMapped      = 2
+	syzygyFlagWinPlies    = 4
+	syzygyFlagLossPlies   = 8
+	syzygyFlagWide        = 16
+	syzygyFlagSingleValue = 128
+)
+
+var syzygyWDLMagic = []byte{0x71, 0xE8, 0x23, 0x5D}
+var syzygyDTZMagic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+
+// Tables use their own piece codes: pawn to king are 1 to 6 with 8 added for black
+var syzygyPieceCodes [12]uint8 = [12]uint8{6, 5, 4, 3, 2, 1, 14, 13, 12, 11, 10, 9}
+
+// Index encoding tables, filled once by setupSyzygyIndexTables
+var (
+	syzygyMapA1D1D4       [64]int     // Squares of the a1-d1-d4 triangle to 0..9, the diagonal last
+	syzygyMapB1H1H7       [64]int     // Squares below the a1-h8 diagonal to 0..27
+	syzygyMapKK           [10][64]int // The 462 placements of two kings with the first in the triangle
+	syzygyMapPawns        [64]int     // a2-h7 to 0..47, the leading pawn has the highest value
+	syzygyBinomial        [6][64]uint64
+	syzygyLeadPawnIndex   [6][64]uint64
+	syzygyLeadPawnsSize   [6][4]uint64
+	syzygyIndexTablesOnce sync.Once
+)
+
+// Decoding information for one side to move and leading pawn file of a table. Offsets point into
+// the table header except for data, which is a file offset as the compressed blocks are read on
+// demand
+type syzygyPairsData struct {
+	flags           uint8
+	maxSymLen       int
+	minSymLen       int // The stored value for single value tables
+	numBlocks       uint32
+	blockSize       uint64
+	span            uint64 // About every span values there is a sparse index entry
+	lowestSym       int
+	btree           int
+	blockLength     int
+	blockLengthSize uint32
+	sparseIndex     int
+	sparseIndexSize uint64
+	data            int64
+	base64          []uint64 // base64[l - minSymLen] is the lowest symbol of length l padded to 64 bits
+	symlen          []uint8  // Number of values minus one that each symbol expands to
+	pieces          [syzygyMaxPieces]uint8
+	groupIndex      [syzygyMaxPieces + 1]uint64
+	groupLength     [syzygyMaxPieces + 1]int
+	mapIndex        [4]int // Start of the DTZ value maps for wins, losses, cursed wins and blessed losses
+}
+
+// A WDL or DTZ file. Tables are named and stored with the stronger side as white, key is the
+// material of that position and key2 the material with the colors swapped
+type syzygyTable struct {
+	path            string
+	dtz             bool
+	key             uint64
+	key2            uint64
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // Leading color and the other color
+	loadOnce        sync.Once
+	loadErr         error
+	data            []byte // The whole file, mapped into memory on the first probe
+	dtzMap          int
+	pairs           [2][4]*syzygyPairsData // [side to move][leading pawn file]
+}
+
+type syzygyTablePair struct {
+	wdl *syzygyTable
+	dtz *syzygyTable // Nil when only the WDL file was found
+}
+
+type Tablebases struct {
+	path      string
+	tables    map[uint64]*syzygyTablePair // By material key, a table is found with either coloring
+	count     int
+	maxPieces int
+}
+
+// Nil when no tablebases are loaded
+var tablebases *Tablebases
+
+// Paths are separated like the PATH environment variable. An empty path unloads the tablebases
+func SetSyzygyPath(path string) error {
+	if tablebases != nil {
+		tablebases.Close()
+		tablebases = nil
+	}
+	if path == "" {
+		return nil
+	}
+	loaded, err := LoadTablebases(path)
+	if err != nil {
+		return err
+	}
+	tablebases = loaded
+	return nil
+}
+
+// Finds the WDL files in the directories and pairs them with their DTZ files. The files themselves
+// are opened on their first probe
+func LoadTablebases(path string) (*Tablebases, error) {
+	syzygyIndexTablesOnce.Do(setupSyzygyIndexTables)
+	directories := filepath.SplitList(path)
+	tb := &Tablebases{path: path, tables: map[uint64]*syzygyTablePair{}}
+	dtzPaths := map[string]string{}
+	wdlPaths := map[string]string{}
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			code, extension, _ := strings.Cut(name, ".")
+			if extension == "rtbw" && wdlPaths[code] == "" {
+				wdlPaths[code] = filepath.Join(directory, name)
+			} else if extension == "rtbz" && dtzPaths[code] == "" {
+				dtzPaths[code] = filepath.Join(directory, name)
+			}
+		}
+	}
+	for code, wdlPath := range wdlPaths {
+		wdl, err := newSyzygyTable(code, wdlPath, false)
+		if err != nil {
+			continue
+		}
+		pair := &syzygyTablePair{wdl: wdl}
+		if dtzPath, ok := dtzPaths[code]; ok {
+			pair.dtz, _ = newSyzygyTable(code, dtzPath, true)
+		}
+		tb.tables[wdl.key] = pair
+		tb.tables[wdl.key2] = pair
+		tb.count++
+		tb.maxPieces = max(tb.maxPieces, wdl.pieceCount)
+	}
+	if tb.count == 0 {
+		return nil, fmt.Errorf("no Syzygy tablebases found in %s", path)
+	}
+	return tb, nil
+}
+
+func (tb *Tablebases) Close() {
+	for _, pair := range tb.tables {
+		for _, table := range []*syzygyTable{pair.wdl, pair.dtz} {
+			if table != nil {
+				table.close()
+			}
+		}
+	}
+}
+
+func (tb *Tablebases) Count() int     { return tb.count }
+func (tb *Tablebases) MaxPieces() int { return tb.maxPieces }
+
+// Codes are the pieces of each side starting with the king, eg "KRPvKR"
+func newSyzygyTable(code string, path string, dtz bool) (*syzygyTable, error) {
+	strong, weak, found := strings.Cut(code, "v")
+	if !found || !strings.HasPrefix(strong, "K") || !strings.HasPrefix(weak, "K") || len(strong)+len(weak) > syzygyMaxPieces {
+		return nil, fmt.Errorf("%s is not a Syzygy table name", code)
+	}
+	counts := [12]int{}
+	for side, pieces := range []string{strong, weak} {
+		for _, letter := range pieces {
+			piece := strings.IndexRune("KQRBN", letter)
+			if letter == 'P' {
+				piece = Pawn
+			} else if piece == -1 {
+				return nil, fmt.Errorf("%s is not a Syzygy table name", code)
+			}
+			counts[side*6+piece]++
+		}
+	}
+	if counts[King] != 1 || counts[6+King] != 1 {
+		return nil, fmt.Errorf("%s is not a Syzygy table name", code)
+	}
+	table := &syzygyTable{path: path, dtz: dtz, pieceCount: len(strong) + len(weak)}
+	table.key = syzygyMaterialKey(counts)
+	table.key2 = syzygyMaterialKey([12]int(slices.Concat(counts[6:], counts[:6])))
+	whitePawns, blackPawns := counts[Pawn], counts[6+Pawn]
+	table.hasPawns = whitePawns+blackPawns > 0
+	for piece := Queen; piece <= Pawn; piece++ {
+		if counts[piece] == 1 || counts[6+piece] == 1 {
+			table.hasUniquePieces = true
+		}
+	}
+	// The side with fewer pawns leads as that compresses better
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		table.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		table.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+	return table, nil
+}
+
+// Piece counts packed 4 bits each, in board order
+func syzygyMaterialKey(counts [12]int) uint64 {
+	key := uint64(0)
+	for piece, count := range counts {
+		key |= uint64(count) << (4 * piece)
+	}
+	return key
+}
+
+func (s *State) syzygyMaterialKey() uint64 {
+	counts := [12]int{}
+	for piece := range counts {
+		counts[piece] = BitCount(s.board[piece])
+	}
+	return syzygyMaterialKey(counts)
+}
+
+func setupSyzygyIndexTables() {
+	offDiagonal := func(square int) int { return square/8 - square%8 }
+	code := 0
+	for square := range 64 {
+		if offDiagonal(square) < 0 {
+			syzygyMapB1H1H7[square] = code
+			code++
+		}
+	}
+	code = 0
+	diagonal := []int{}
+	for _, square := range []int{0, 1, 2, 3, 8, 9, 10, 11, 16, 17, 18, 19, 24, 25, 26, 27} {
+		if offDiagonal(square) < 0 {
+			syzygyMapA1D1D4[square] = code
+			code++
+		} else if offDiagonal(square) == 0 {
+			diagonal = append(diagonal, square)
+		}
+	}
+	for _, square := range diagonal {
+		syzygyMapA1D1D4[square] = code
+		code++
+	}
+	// With the first king on the diagonal the second one is never above it
+	type kingPair struct{ index, square int }
+	bothOnDiagonal := []kingPair{}
+	code = 0
+	for index := range 10 {
+		for first := 0; first <= 27; first++ {
+			// b1 is the square mapped to 0
+			if syzygyMapA1D1D4[first] != index || (index == 0 && first != 1) {
+				continue
+			}
+			for second := range 64 {
+				fileDistance := max(first%8-second%8, second%8-first%8)
+				rankDistance := max(first/8-second/8, second/8-first/8)
+				if fileDistance <= 1 && rankDistance <= 1 {
+					continue
+				} else if offDiagonal(first) == 0 && offDiagonal(second) > 0 {
+					continue
+				} else if offDiagonal(first) == 0 && offDiagonal(second) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, kingPair{index, second})
+				} else {
+					syzygyMapKK[index][second] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, pair := range bothOnDiagonal {
+		syzygyMapKK[pair.index][pair.square] = code
+		code++
+	}
+	syzygyBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				syzygyBinomial[k][n] += syzygyBinomial[k-1][n-1]
+			}
+			if k < n {
+				syzygyBinomial[k][n] += syzygyBinomial[k][n-1]
+			}
+		}
+	}
+	// Pawns nearer the edge and lower down come first, the leading pawn is the one with the highest
+	// value and the others can only be on the squares after it
+	availableSquares := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for file := range 4 {
+			index := uint64(0)
+			for rank := 1; rank <= 6; rank++ {
+				square := rank*8 + file
+				if leadPawns == 1 {
+					syzygyMapPawns[square] = availableSquares
+					availableSquares--
+					syzygyMapPawns[square^7] = availableSquares
+					availableSquares--
+				}
+				syzygyLeadPawnIndex[leadPawns][square] = index
+				index += syzygyBinomial[leadPawns-1][syzygyMapPawns[square]]
+			}
+			syzygyLeadPawnsSize[leadPawns][file] = index
+		}
+	}
+}
+
+func (table *syzygyTable) sides() int {
+	if !table.dtz && table.key != table.key2 {
+		return 2
+	}
+	return 1
+}
+
+func (table *syzygyTable) get(sideToMove int, file int) *syzygyPairsData {
+	if !table.hasPawns {
+		file = 0
+	}
+	return table.pairs[sideToMove%table.sides()][file]
+}
+
+func (table *syzygyTable) ensureLoaded() error {
+	table.loadOnce.Do(func() {
+		table.loadErr = table.load()
+		if table.loadErr != nil {
+			table.close()
+		}
+	})
+	return table.loadErr
+}
+
+func (table *syzygyTable) load() error {
+	file, err := os.Open(table.path)
+	if err != nil {
+		return err
+	}
+	// The mapping stays valid once the file is closed
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if table.data, err = mapSyzygyFile(file, info.Size()); err != nil {
+		return err
+	}
+	if err := table.need(5); err != nil {
+		return err
+	}
+	magic := syzygyWDLMagic
+	if table.dtz {
+		magic = syzygyDTZMagic
+	}
+	if !slices.Equal(table.data[:4], magic) {
+		return fmt.Errorf("%s is not a Syzygy table", table.path)
+	}
+	if (table.data[4]&2 != 0) != table.hasPawns {
+		return fmt.Errorf("%s does not match its name", table.path)
+	}
+	return table.parse()
+}
+
+// Checks the file is long enough to hold end bytes
+func (table *syzygyTable) need(end int) error {
+	if end > len(table.data) {
+		return fmt.Errorf("%s is truncated", table.path)
+	}
+	return nil
+}
+
+func (table *syzygyTable) close() {
+	if table.data != nil {
+		unmapSyzygyFile(table.data)
+		table.data = nil
+	}
+}
+
+// The layout is the piece order of every side and file, then the Huffman tables, the DTZ value
+// maps, the sparse indexes, the block lengths and finally the 64 byte aligned compressed blocks
+func (table *syzygyTable) parse() error {
+	position := 5
+	sides := table.sides()
+	maxFile := 0
+	if table.hasPawns {
+		maxFile = 3
+	}
+	bothSidesPawns := table.hasPawns && table.pawnCount[1] != 0
+	for file := 0; file <= maxFile; file++ {
+		for side := range sides {
+			table.pairs[side][file] = &syzygyPairsData{}
+		}
+		if err := table.need(position + 2 + table.pieceCount); err != nil {
+			return err
+		}
+		order := [2][2]int{{int(table.data[position] & 0xF), 0xF}, {int(table.data[position] >> 4), 0xF}}
+		if bothSidesPawns {
+			order[0][1] = int(table.data[position+1] & 0xF)
+			order[1][1] = int(table.data[position+1] >> 4)
+			position++
+		}
+		position++
+		for k := 0; k < table.pieceCount; k, position = k+1, position+1 {
+			for side := range sides {
+				if side == 0 {
+					table.pairs[side][file].pieces[k] = table.data[position] & 0xF
+				} else {
+					table.pairs[side][file].pieces[k] = table.data[position] >> 4
+				}
+			}
+		}
+		for side := range sides {
+			table.setGroups(table.pairs[side][file], order[side], file)
+		}
+	}
+	position += position & 1
+	var err error
+	for file := 0; file <= maxFile; file++ {
+		for side := range sides {
+			if position, err = table.setSizes(table.pairs[side][file], position); err != nil {
+				return err
+			}
+		}
+	}
+	if table.dtz {
+		if position, err = table.setDTZMap(position, maxFile); err != nil {
+			return err
+		}
+	}
+	for file := 0; file <= maxFile; file++ {
+		for side := range sides {
+			pairs := table.pairs[side][file]
+			pairs.sparseIndex = position
+			position += int(pairs.sparseIndexSize) * 6
+		}
+	}
+	for file := 0; file <= maxFile; file++ {
+		for side := range sides {
+			pairs := table.pairs[side][file]
+			pairs.blockLength = position
+			position += int(pairs.blockLengthSize) * 2
+		}
+	}
+	if err := table.need(position); err != nil {
+		return err
+	}
+	dataPosition := int64(position)
+	for file := 0; file <= maxFile; file++ {
+		for side := range sides {
+			pairs := table.pairs[side][file]
+			dataPosition = (dataPosition + 0x3F) &^ 0x3F
+			pairs.data = dataPosition
+			dataPosition += int64(pairs.numBlocks) * int64(pairs.blockSize)
+			if pairs.numBlocks > 0 && dataPosition > int64(len(table.data)) {
+				return fmt.Errorf("%s is truncated", table.path)
+			}
+		}
+	}
+	return nil
+}
+
+// Pieces of the same kind next to each other in the piece order form a group, except that the
+// leading group is the leading pawns or the first two or three pieces. A position's index is
+// built from the index of each group's placement in the order given by the table
+func (table *syzygyTable) setGroups(pairs *syzygyPairsData, order [2]int, file int) {
+	n := 0
+	firstLength := 2
+	if table.hasPawns {
+		firstLength = 0
+	} else if table.hasUniquePieces {
+		firstLength = 3
+	}
+	pairs.groupLength[n] = 1
+	for i := 1; i < table.pieceCount; i++ {
+		firstLength--
+		if firstLength > 0 || pairs.pieces[i] == pairs.pieces[i-1] {
+			pairs.groupLength[n]++
+		} else {
+			n++
+			pairs.groupLength[n] = 1
+		}
+	}
+	n++
+	pairs.groupLength[n] = 0
+	bothSidesPawns := table.hasPawns && table.pawnCount[1] != 0
+	next := 1
+	freeSquares := 64 - pairs.groupLength[0]
+	if bothSidesPawns {
+		next = 2
+		freeSquares -= pairs.groupLength[1]
+	}
+	index := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		if k == order[0] {
+			pairs.groupIndex[0] = index
+			if table.hasPawns {
+				index *= syzygyLeadPawnsSize[pairs.groupLength[0]][file]
+			} else if table.hasUniquePieces {
+				index *= 31332
+			} else {
+				index *= 462
+			}
+		} else if k == order[1] {
+			pairs.groupIndex[1] = index
+			index *= syzygyBinomial[pairs.groupLength[1]][48-pairs.groupLength[0]]
+		} else {
+			pairs.groupIndex[next] = index
+			index *= syzygyBinomial[pairs.groupLength[next]][freeSquares]
+			freeSquares -= pairs.groupLength[next]
+			next++
+		}
+	}
+	pairs.groupIndex[n] = index
+}
+
+// Reads the Huffman code description and returns the position after it
+func (table *syzygyTable) setSizes(pairs *syzygyPairsData, position int) (int, error) {
+	if err := table.need(position + 2); err != nil {
+		return 0, err
+	}
+	header := table.data
+	pairs.flags = header[position]
+	position++
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		pairs.minSymLen = int(header[position])
+		return position + 1, nil
+	}
+	if err := table.need(position + 10); err != nil {
+		return 0, err
+	}
+	tableSize := pairs.groupIndex[slices.Index(pairs.groupLength[:], 0)]
+	pairs.blockSize = 1 << header[position]
+	pairs.span = 1 << header[position+1]
+	pairs.sparseIndexSize = (tableSize + pairs.span - 1) / pairs.span
+	padding := uint32(header[position+2])
+	pairs.numBlocks = binary.LittleEndian.Uint32(header[position+3:])
+	pairs.blockLengthSize = pairs.numBlocks + padding
+	pairs.maxSymLen = int(header[position+7])
+	pairs.minSymLen = int(header[position+8])
+	position += 9
+	if pairs.maxSymLen < pairs.minSymLen || pairs.maxSymLen > 64 {
+		return 0, fmt.Errorf("%s has invalid symbol lengths", table.path)
+	}
+	pairs.lowestSym = position
+	pairs.base64 = make([]uint64, pairs.maxSymLen-pairs.minSymLen+1)
+	if err := table.need(position + 2*len(pairs.base64) + 2); err != nil {
+		return 0, err
+	}
+	// Longer symbols have lower values so base64[i] >= base64[i+1]
+	for i := len(pairs.base64) - 2; i >= 0; i-- {
+		pairs.base64[i] = (pairs.base64[i+1] + uint64(table.lowestSym(pairs, i)) - uint64(table.lowestSym(pairs, i+1))) / 2
+	}
+	for i := range pairs.base64 {
+		pairs.base64[i] <<= 64 - i - pairs.minSymLen
+	}
+	position += 2 * len(pairs.base64)
+	symbols := int(binary.LittleEndian.Uint16(header[position:]))
+	position += 2
+	pairs.btree = position
+	if err := table.need(position + 3*symbols + symbols&1); err != nil {
+		return 0, err
+	}
+	// Symbols are pairs of smaller symbols, recursively, down to the stored values
+	pairs.symlen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for symbol := range symbols {
+		if !visited[symbol] {
+			pairs.symlen[symbol] = table.setSymlen(pairs, symbol, visited)
+		}
+	}
+	return position + 3*symbols + symbols&1, nil
+}
+
+func (table *syzygyTable) setSymlen(pairs *syzygyPairsData, symbol int, visited []bool) uint8 {
+	visited[symbol] = true
+	right := table.rightSymbol(pairs, symbol)
+	if right == 0xFFF {
+		return 0
+	}
+	left := table.leftSymbol(pairs, symbol)
+	if left >= len(pairs.symlen) || right >= len(pairs.symlen) {
+		return 0
+	}
+	if !visited[left] {
+		pairs.symlen[left] = table.setSymlen(pairs, left, visited)
+	}
+	if !visited[right] {
+		pairs.symlen[right] = table.setSymlen(pairs, right, visited)
+	}
+	return pairs.symlen[left] + pairs.symlen[right] + 1
+}
+
+func (table *syzygyTable) lowestSym(pairs *syzygyPairsData, length int) uint16 {
+	return binary.LittleEndian.Uint16(table.data[pairs.lowestSym+2*length:])
+}
+
+// Each tree entry is 3 bytes holding the 12 bit left and right halves of the symbol
+func (table *syzygyTable) leftSymbol(pairs *syzygyPairsData, symbol int) int {
+	entry := table.data[pairs.btree+3*symbol:]
+	return int(entry[1]&0xF)<<8 | int(entry[0])
+}
+
+func (table *syzygyTable) rightSymbol(pairs *syzygyPairsData, symbol int) int {
+	entry := table.data[pairs.btree+3*symbol:]
+	return int(entry[2])<<4 | int(entry[1]>>4)
+}
+
+// DTZ values are stored as their rank by frequency for each of the four results, the maps turn
+// them back into distances
+func (table *syzygyTable) setDTZMap(position int, maxFile int) (int, error) {
+	table.dtzMap = position
+	for file := 0; file <= maxFile; file++ {
+		pairs := table.get(0, file)
+		if pairs.flags&syzygyFlagMapped == 0 {
+			continue
+		}
+		if pairs.flags&syzygyFlagWide != 0 {
+			position += position & 1
+			for i := range 4 {
+				if err := table.need(position + 2); err != nil {
+					return 0, err
+				}
+				pairs.mapIndex[i] = (position-table.dtzMap)/2 + 1
+				position += 2*int(binary.LittleEndian.Uint16(table.data[position:])) + 2
+			}
+		} else {
+			for i := range 4 {
+				if err := table.need(position + 1); err != nil {
+					return 0, err
+				}
+				pairs.mapIndex[i] = position - table.dtzMap + 1
+				position += int(table.data[position]) + 1
+			}
+		}
+	}
+	return position + position&1, nil
+}
+
+// Returns the value stored for the index
+func (table *syzygyTable) decompress(pairs *syzygyPairsData, index uint64) (int, error) {
+	if pairs.flags&syzygyFlagSingleValue != 0 {
+		return pairs.minSymLen, nil
+	}
+	header := table.data
+	// The sparse index gives the block and offset of every span-th value, counted from the middle
+	// of the span
+	k := index / pairs.span
+	if k >= pairs.sparseIndexSize {
+		return 0, errors.New("index out of range")
+	}
+	entry := pairs.sparseIndex + 6*int(k)
+	block := int(binary.LittleEndian.Uint32(header[entry:]))
+	offset := int(binary.LittleEndian.Uint16(header[entry+4:]))
+	offset += int(index%pairs.span) - int(pairs.span/2)
+	blockLength := func(block int) int {
+		return int(binary.LittleEndian.Uint16(header[pairs.blockLength+2*block:]))
+	}
+	for offset < 0 {
+		block--
+		if block < 0 {
+			return 0, errors.New("index out of range")
+		}
+		offset += blockLength(block) + 1
+	}
+	for offset > blockLength(block) {
+		offset -= blockLength(block) + 1
+		block++
+		if block >= int(pairs.blockLengthSize) {
+			return 0, errors.New("index out of range")
+		}
+	}
+	// Symbols never cross the end of a block but the refill reads a little past it when it can
+	start := pairs.data + int64(block)*int64(pairs.blockSize)
+	data := table.data[start:min(start+int64(pairs.blockSize)+8, int64(len(table.data)))]
+	if len(data) < 8 {
+		return 0, errors.New("block out of range")
+	}
+	buffer := binary.BigEndian.Uint64(data)
+	dataPosition := 8
+	bufferSize := 64
+	symbol := 0
+	for {
+		// The length of the next code is found by comparing against the lowest code of each length
+		length := 0
+		for length < len(pairs.base64)-1 && buffer < pairs.base64[length] {
+			length++
+		}
+		symbol = int((buffer - pairs.base64[length]) >> (64 - length - pairs.minSymLen))
+		symbol += int(table.lowestSym(pairs, length))
+		if symbol >= len(pairs.symlen) {
+			return 0, errors.New("invalid symbol")
+		}
+		if offset < int(pairs.symlen[symbol])+1 {
+			break
+		}
+		offset -= int(pairs.symlen[symbol]) + 1
+		length += pairs.minSymLen
+		buffer <<= length
+		bufferSize -= length
+		if bufferSize <= 32 {
+			bufferSize += 32
+			if dataPosition+4 <= len(data) {
+				buffer |= uint64(binary.BigEndian.Uint32(data[dataPosition:])) << (64 - bufferSize)
+			}
+			dataPosition += 4
+		}
+	}
+	// Expand the symbol into the pair holding the offset until a single value is left
+	for pairs.symlen[symbol] != 0 {
+		left := table.leftSymbol(pairs, symbol)
+		if offset < int(pairs.symlen[left])+1 {
+			symbol = left
+		} else {
+			offset -= int(pairs.symlen[left]) + 1
+			symbol = table.rightSymbol(pairs, symbol)
+		}
+	}
+	return table.leftSymbol(pairs, symbol), nil
+}
+
+// Turns the stored value into a WDL result, or a DTZ in plies
+func (table *syzygyTable) mapScore(file int, value int, wdl int) int {
+	if !table.dtz {
+		return value - 2
+	}
+	pairs := table.get(0, file)
+	if pairs.flags&syzygyFlagMapped != 0 {
+		mapIndex := pairs.mapIndex[[5]int{1, 3, 0, 2, 0}[wdl+2]] + value
+		if pairs.flags&syzygyFlagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(table.data[table.dtzMap+2*mapIndex:]))
+		} else {
+			value = int(table.data[table.dtzMap+mapIndex])
+		}
+	}
+	if (wdl == WDLWin && pairs.flags&syzygyFlagWinPlies == 0) || (wdl == WDLLoss && pairs.flags&syzygyFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+func syzygyPawnLess(a int, b int) bool {
+	return syzygyMapPawns[a] < syzygyMapPawns[b]
+}
+
+func syzygyOffDiagonal(square int) int {
+	return square/8 - square%8
+}
+
+// The pairs data and index of the position in the table. The position is first mirrored so the
+// stronger side is white and the leading piece or pawn ends up in the area the table covers
+func (table *syzygyTable) index(s *State) (*syzygyPairsData, uint64, int, syzygyProbeResult) {
+	squares := [syzygyMaxPieces]int{}
+	pieces := [syzygyMaxPieces]uint8{}
+	size, leadPawnsCount := 0, 0
+	leadPawns := EmptyBitboard
+	tableFile := 0
+	materialKey := s.syzygyMaterialKey()
+	// Tables with the same material on both sides only store white to move
+	symmetricBlackToMove := table.key == table.key2 && s.turn == Black
+	blackStronger := materialKey != table.key
+	flipColor, flipSquares, sideToMove := uint8(0), 0, int(s.turn)
+	if symmetricBlackToMove || blackStronger {
+		flipColor, flipSquares, sideToMove = 8, 56, 1-int(s.turn)
+	}
+	if table.hasPawns {
+		// Pawns come first in the piece order so the first piece gives the leading color
+		leadColor := (table.get(0, 0).pieces[0] ^ flipColor) >> 3
+		leadPawns = s.board[int(leadColor)*6+Pawn]
+		for board := leadPawns; board != EmptyBitboard; {
+			squares[size] = int(PopLSB(&board)) ^ flipSquares
+			size++
+		}
+		leadPawnsCount = size
+		best := 0
+		for i := 1; i < leadPawnsCount; i++ {
+			if syzygyPawnLess(squares[best], squares[i]) {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+		tableFile = squares[0] % 8
+		if tableFile > 3 {
+			tableFile = 7 - tableFile
+		}
+	}
+	if table.dtz {
+		flags := table.get(sideToMove, tableFile).flags
+		if int(flags&syzygyFlagSideToMove) != sideToMove && !(table.key == table.key2 && !table.hasPawns) {
+			return nil, 0, 0, syzygyChangeSideToMove
+		}
+	}
+	for board := s.occupied ^ leadPawns; board != EmptyBitboard; {
+		square := PopLSB(&board)
+		squares[size] = int(square) ^ flipSquares
+		pieces[size] = syzygyPieceCodes[s.board.getPieceAt(square)] ^ flipColor
+		size++
+	}
+	pairs := table.get(sideToMove, tableFile)
+	// Match the piece order of the table
+	for i := leadPawnsCount; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if pairs.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+	if squares[0]%8 > 3 {
+		for i := range size {
+			squares[i] ^= 7
+		}
+	}
+	index := uint64(0)
+	if table.hasPawns {
+		index = syzygyLeadPawnIndex[leadPawnsCount][squares[0]]
+		slices.SortStableFunc(squares[1:leadPawnsCount], func(a, b int) int {
+			if syzygyPawnLess(a, b) {
+				return -1
+			} else if syzygyPawnLess(b, a) {
+				return 1
+			}
+			return 0
+		})
+		for i := 1; i < leadPawnsCount; i++ {
+			index += syzygyBinomial[i][syzygyMapPawns[squares[i]]]
+		}
+	} else {
+		// Without pawns the leading piece is also kept below the fifth rank and the a1-h8 diagonal
+		if squares[0]/8 > 3 {
+			for i := range size {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < pairs.groupLength[0]; i++ {
+			if syzygyOffDiagonal(squares[i]) == 0 {
+				continue
+			}
+			if syzygyOffDiagonal(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = ((squares[j] >> 3) | (squares[j] << 3)) & 63
+				}
+			}
+			break
+		}
+		if table.hasUniquePieces {
+			adjust1, adjust2 := 0, 0
+			if squares[1] > squares[0] {
+				adjust1 = 1
+			}
+			if squares[2] > squares[0] {
+				adjust2++
+			}
+			if squares[2] > squares[1] {
+				adjust2++
+			}
+			if syzygyOffDiagonal(squares[0]) != 0 {
+				index = uint64((syzygyMapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2)
+			} else if syzygyOffDiagonal(squares[1]) != 0 {
+				index = uint64((6*63+(squares[0]/8)*28+syzygyMapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+			} else if syzygyOffDiagonal(squares[2]) != 0 {
+				index = uint64(6*63*62 + 4*28*62 + (squares[0]/8)*7*28 + (squares[1]/8-adjust1)*28 + syzygyMapB1H1H7[squares[2]])
+			} else {
+				index = uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]/8)*7*6 + (squares[1]/8-adjust1)*6 + (squares[2]/8 - adjust2))
+			}
+		} else {
+			index = uint64(syzygyMapKK[syzygyMapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+	index *= pairs.groupIndex[0]
+	groupStart := pairs.groupLength[0]
+	remainingPawns := table.hasPawns && table.pawnCount[1] != 0
+	for next := 1; pairs.groupLength[next] != 0; next++ {
+		group := squares[groupStart : groupStart+pairs.groupLength[next]]
+		slices.Sort(group)
+		n := uint64(0)
+		for i, square := range group {
+			// Squares taken by the earlier groups are skipped
+			adjust := 0
+			for _, earlier := range squares[:groupStart] {
+				if square > earlier {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += syzygyBinomial[i+1][square-adjust]
+		}
+		remainingPawns = false
+		index += n * pairs.groupIndex[next]
+		groupStart += pairs.groupLength[next]
+	}
+	return pairs, index, tableFile, syzygyOK
+}
+
+// Probes the table itself. For DTZ tables wdl is the result of the position
+func (table *syzygyTable) probe(s *State, wdl int) (int, syzygyProbeResult) {
+	pairs, index, file, result := table.index(s)
+	if result != syzygyOK {
+		return 0, result
+	}
+	value, err := table.decompress(pairs, index)
+	if err != nil {
+		return 0, syzygyFail
+	}
+	return table.mapScore(file, value, wdl), syzygyOK
+}
+
+// Castling is not stored in the tables
+func (tb *Tablebases) CanProbe(s *State) bool {
+	if BitCount(s.occupied) > tb.maxPieces {
+		return false
+	}
+	for _, available := range s.castleAvailability {
+		if available {
+			return false
+		}
+	}
+	return true
+}
+
+func (tb *Tablebases) probeTable(s *State, dtz bool, wdl int) (int, syzygyProbeResult) {
+	if BitCount(s.occupied) == 2 {
+		return WDLDraw, syzygyOK
+	}
+	pair := tb.tables[s.syzygyMaterialKey()]
+	if pair == nil {
+		return 0, syzygyFail
+	}
+	table := pair.wdl
+	if dtz {
+		table = pair.dtz
+	}
+	if table == nil || table.ensureLoaded() != nil {
+		return 0, syzygyFail
+	}
+	return table.probe(s, wdl)
+}
+
+func (s *State) isZeroingMove(move Move) bool {
+	return s.isCapture(move) || s.board[s.turn*6+Pawn]&boardFromSquare(move.OriginSquare()) != 0
+}
+
+func (s *State) isCapture(move Move) bool {
+	return move.SpecialMove() == EnPassantSpacialMove || s.sideOccupied[1-s.turn]&boardFromSquare(move.DestinationSquare()) != 0
+}
+
+// Probes run inside the search at every node after a zeroing move, so the recursion reuses its move
+// buffers instead of allocating
+var syzygyMoveBuffers = sync.Pool{New: func() any { return NewMoveBuffer() }}
+
+// Tables may store any value for positions where a capture is at least as good, and do not know
+// about en passant, so captures are searched and the best of them and the stored value is the
+// result. With checkZeroing pawn moves are searched as well as DTZ tables do the same for them
+func (tb *Tablebases) search(s *State, checkZeroing bool) (int, syzygyProbeResult) {
+	bestValue := WDLLoss
+	buffer := syzygyMoveBuffers.Get().(*MoveBuffer)
+	defer syzygyMoveBuffers.Put(buffer)
+	legalMoves := LegalMovesInto(s, buffer)
+	moveCount := 0
+	for _, move := range legalMoves {
+		if !s.isCapture(move) && (!checkZeroing || !s.isZeroingMove(move)) {
+			continue
+		}
+		moveCount++
+		s.MakeMove(move)
+		value, result := tb.search(s, false)
+		s.UnMakeMove(move)
+		if result == syzygyFail {
+			return WDLDraw, syzygyFail
+		}
+		value = -value
+		if value > bestValue {
+			bestValue = value
+			if value >= WDLWin {
+				return value, syzygyZeroingBestMove
+			}
+		}
+	}
+	// With every legal move already searched the stored value is not needed, and could be wrong
+	noMoreMoves := moveCount != 0 && moveCount == len(legalMoves)
+	value := bestValue
+	if !noMoreMoves {
+		var result syzygyProbeResult
+		value, result = tb.probeTable(s, false, WDLDraw)
+		if result != syzygyOK {
+			return WDLDraw, syzygyFail
+		}
+	}
+	if bestValue >= value {
+		if bestValue > WDLDraw || noMoreMoves {
+			return bestValue, syzygyZeroingBestMove
+		}
+		return bestValue, syzygyOK
+	}
+	return value, syzygyOK
+}
+
+// The result with the fifty move rule for the side to move
+func (tb *Tablebases) ProbeWDL(s *State) (int, bool) {
+	wdl, result := tb.search(s, false)
+	return wdl, result != syzygyFail
+}
+
+// The DTZ of the move just before a zeroing move
+func dtzBeforeZeroing(wdl int) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+func signOf(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+// Plies until the fifty move counter is reset by a capture or pawn move, positive when winning,
+// negative when losing and 0 for draws. Values past 100 are cursed wins and blessed losses
+func (tb *Tablebases) ProbeDTZ(s *State) (int, bool) {
+	wdl, result := tb.search(s, true)
+	if result == syzygyFail {
+		return 0, false
+	}
+	if wdl == WDLDraw {
+		return 0, true
+	}
+	if result == syzygyZeroingBestMove {
+		return dtzBeforeZeroing(wdl), true
+	}
+	dtz, result := tb.probeTable(s, true, wdl)
+	if result == syzygyFail {
+		return 0, false
+	}
+	if result != syzygyChangeSideToMove {
+		if wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+			dtz += 100
+		}
+		return dtz * signOf(wdl), true
+	}
+	// The table is for the other side to move so the best DTZ is found one ply deeper
+	minDTZ := 0xFFFF
+	for _, move := range LegalMoves(s) {
+		zeroing := s.isZeroingMove(move)
+		s.MakeMove(move)
+		var dtz int
+		ok := true
+		if zeroing {
+			// The sign comes from the result after the move, the DTZ is the one before it
+			var moveWDL int
+			moveWDL, ok = tb.ProbeWDL(s)
+			dtz = -dtzBeforeZeroing(moveWDL)
+		} else {
+			dtz, ok = tb.ProbeDTZ(s)
+			dtz = -dtz
+		}
+		if dtz == 1 && s.check && len(LegalMoves(s)) == 0 {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += signOf(dtz)
+		}
+		if dtz < minDTZ && signOf(dtz) == signOf(wdl) {
+			minDTZ = dtz
+		}
+		s.UnMakeMove(move)
+		if !ok {
+			return 0, false
+		}
+	}
+	// Without legal moves the position is mate
+	if minDTZ == 0xFFFF {
+		return -1, true
+	}
+	return minDTZ, true
+}
+
+// The legal root moves that keep the best tablebase result. Wins keep the moves closest to
+// resetting the fifty move counter so progress is always made, losses the ones furthest from it
+// and draws every drawing move. WDL tables are used when DTZ is missing. Nil when the position
+// can not be probed
+func (tb *Tablebases) RootMoves(s *State) []Move {
+	if !tb.CanProbe(s) {
+		return nil
+	}
+	legalMoves := LegalMoves(s)
+	if len(legalMoves) == 0 {
+		return nil
+	}
+	ranks := make([]int, len(legalMoves))
+	if !tb.rankRootMovesDTZ(s, legalMoves, ranks) && !tb.rankRootMovesWDL(s, legalMoves, ranks) {
+		return nil
+	}
+	best := slices.Max(ranks)
+	rootMoves := []Move{}
+	for i, move := range legalMoves {
+		if ranks[i] == best {
+			rootMoves = append(rootMoves, move)
+		}
+	}
+	return rootMoves
+}
+
+func (tb *Tablebases) rankRootMovesDTZ(s *State, legalMoves []Move, ranks []int) bool {
+	fiftyMoveCount := int(s.lastCapOrPawn)
+	for i, move := range legalMoves {
+		s.MakeMove(move)
+		dtz := 0
+		ok := true
+		if s.lastCapOrPawn == 0 {
+			var wdl int
+			wdl, ok = tb.ProbeWDL(s)
+			dtz = dtzBeforeZeroing(-wdl)
+		} else if s.repetitionMap.get(s.hashcode) < 3 && s.lastCapOrPawn < 100 {
+			dtz, ok = tb.ProbeDTZ(s)
+			dtz = -dtz
+			dtz += signOf(dtz)
+		}
+		// A mating move is always one ply from the end
+		if s.check && dtz == 2 && len(LegalMoves(s)) == 0 {
+			dtz = 1
+		}
+		s.UnMakeMove(move)
+		if !ok {
+			return false
+		}
+		if dtz > 0 {
+			ranks[i] = syzygyMaxDTZ - (dtz + fiftyMoveCount)
+		} else if dtz < 0 {
+			ranks[i] = -syzygyMaxDTZ + (-dtz + fiftyMoveCount)
+		}
+	}
+	return true
+}
+
+func (tb *Tablebases) rankRootMovesWDL(s *State, legalMoves []Move, ranks []int) bool {
+	for i, move := range legalMoves {
+		s.MakeMove(move)
+		wdl, ok := tb.ProbeWDL(s)
+		s.UnMakeMove(move)
+		if !ok {
+			return false
+		}
+		ranks[i] = -wdl
+	}
+	return true
+}
+
+// Search score of a probed result ply moves from the root
+func tablebaseScore(wdl int, ply int16) int32 {
+	switch wdl {
+	case WDLWin:
+		return tablebaseWinValue - int32(ply)*CentiPawn
+	case WDLLoss:
+		return -tablebaseWinValue + int32(ply)*CentiPawn
+	}
+	// Cursed wins and blessed losses are draws but slightly better or worse than a plain one
+	return int32(wdl) * CentiPawn
+}
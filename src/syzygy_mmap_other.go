@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap the whole table is read once
+func mapSyzygyFile(file *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func unmapSyzygyFile(data []byte) {}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// Tables are mapped rather than read so the operating system only loads the blocks that are probed
+func mapSyzygyFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapSyzygyFile(data []byte) {
+	if len(data) != 0 {
+		syscall.Munmap(data)
+	}
+}
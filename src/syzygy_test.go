@@ -0,0 +1,670 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// One side to move of a test table. Sides with a single value store nothing else, the others store
+// which of the values each position has using codes of the same length
+type testTableSide struct {
+	flags  uint8
+	values []int
+	symbol func(index uint64) int
+}
+
+const (
+	testTableBlockBits = 8
+	testTableSpanBits  = 12
+)
+
+func (side testTableSide) symbolBits() int {
+	return max(bits.Len(uint(len(side.values)-1)), 1)
+}
+
+// Values in a block, every block holds the same number of codes
+func (side testTableSide) blockValues() uint64 {
+	return uint64((8 << testTableBlockBits) / side.symbolBits())
+}
+
+// Writes a table in the Syzygy layout with the same piece order for both sides and every file
+func writeTestTable(t *testing.T, path string, dtz bool, flags byte, pieces []uint8, sides []testTableSide, tableSize uint64) {
+	files := 1
+	if flags&2 != 0 {
+		files = 4
+	}
+	data := slices.Clone(syzygyWDLMagic)
+	if dtz {
+		data = slices.Clone(syzygyDTZMagic)
+	}
+	data = append(data, flags)
+	for range files {
+		data = append(data, 0)
+		for _, piece := range pieces {
+			data = append(data, piece|piece<<4)
+		}
+	}
+	data = append(data, make([]byte, len(data)&1)...)
+	numBlocks := func(side testTableSide) uint64 {
+		return (tableSize + side.blockValues() - 1) / side.blockValues()
+	}
+	span := uint64(1 << testTableSpanBits)
+	for range files {
+		for _, side := range sides {
+			if side.symbol == nil {
+				data = append(data, side.flags|syzygyFlagSingleValue, byte(side.values[0]))
+				continue
+			}
+			symbolBits := byte(side.symbolBits())
+			data = append(data, side.flags, testTableBlockBits, testTableSpanBits, 0)
+			data = binary.LittleEndian.AppendUint32(data, uint32(numBlocks(side)))
+			data = append(data, symbolBits, symbolBits)
+			data = binary.LittleEndian.AppendUint16(data, 0)
+			data = binary.LittleEndian.AppendUint16(data, uint16(len(side.values)))
+			// Every symbol is a leaf holding a value
+			for _, value := range side.values {
+				data = append(data, byte(value), byte(value>>8)|0xF0, 0xFF)
+			}
+			data = append(data, make([]byte, len(side.values)&1)...)
+		}
+	}
+	if dtz {
+		data = append(data, make([]byte, len(data)&1)...)
+	}
+	for range files {
+		for _, side := range sides {
+			for k := uint64(0); side.symbol != nil && k < (tableSize+span-1)/span; k++ {
+				index := k*span + span/2
+				data = binary.LittleEndian.AppendUint32(data, uint32(index/side.blockValues()))
+				data = binary.LittleEndian.AppendUint16(data, uint16(index%side.blockValues()))
+			}
+		}
+	}
+	for range files {
+		for _, side := range sides {
+			for block := uint64(0); side.symbol != nil && block < numBlocks(side); block++ {
+				data = binary.LittleEndian.AppendUint16(data, uint16(min(side.blockValues(), tableSize-block*side.blockValues())-1))
+			}
+		}
+	}
+	for range files {
+		for _, side := range sides {
+			if side.symbol == nil {
+				continue
+			}
+			data = append(data, make([]byte, (64-len(data)%64)%64)...)
+			blocks := make([]byte, numBlocks(side)<<testTableBlockBits)
+			symbolBits := uint64(side.symbolBits())
+			for index := range tableSize {
+				// Codes are read from the most significant bit
+				position := index/side.blockValues()<<(testTableBlockBits+3) + index%side.blockValues()*symbolBits
+				for bit := range symbolBits {
+					if side.symbol(index)>>(symbolBits-1-bit)&1 == 1 {
+						blocks[(position+bit)/8] |= 0x80 >> ((position + bit) % 8)
+					}
+				}
+			}
+			data = append(data, blocks...)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadTestTable(t *testing.T, path string, code string) *syzygyTable {
+	syzygyIndexTablesOnce.Do(setupSyzygyIndexTables)
+	table, err := newSyzygyTable(code, path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.ensureLoaded(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(table.close)
+	return table
+}
+
+// Builds a state from piece and square pairs without the rest of the state a FEN would set up
+func testTableState(turn uint8, pieces []uint8, squares []int) *State {
+	s := &State{turn: turn}
+	for i, piece := range pieces {
+		s.board[piece] |= boardFromSquare(Square(squares[i]))
+		s.occupied |= boardFromSquare(Square(squares[i]))
+	}
+	return s
+}
+
+var squareSymmetries = []func(int) int{
+	func(square int) int { return square },
+	func(square int) int { return square ^ 7 },
+	func(square int) int { return square ^ 56 },
+	func(square int) int { return square ^ 63 },
+	func(square int) int { return ((square >> 3) | (square << 3)) & 63 },
+	func(square int) int { return ((square>>3)|(square<<3))&63 ^ 7 },
+	func(square int) int { return ((square>>3)|(square<<3))&63 ^ 56 },
+	func(square int) int { return ((square>>3)|(square<<3))&63 ^ 63 },
+}
+
+func TestSyzygyIndexTables(t *testing.T) {
+	syzygyIndexTablesOnce.Do(setupSyzygyIndexTables)
+	kingCodes := map[int]bool{}
+	for index := range 10 {
+		for square := range 64 {
+			kingCodes[syzygyMapKK[index][square]] = true
+		}
+	}
+	if len(kingCodes) != 462 || !kingCodes[461] || kingCodes[462] {
+		t.Errorf("king pairs map to %d codes, want 0 to 461", len(kingCodes))
+	}
+	if syzygyMapB1H1H7[squareIndex("h7")] != 27 || syzygyMapA1D1D4[squareIndex("d4")] != 9 || syzygyMapA1D1D4[squareIndex("b1")] != 0 {
+		t.Error("triangle maps do not cover the expected squares")
+	}
+	if syzygyMapPawns[squareIndex("a2")] != 47 || syzygyMapPawns[squareIndex("h2")] != 46 || syzygyMapPawns[squareIndex("e7")] != 0 {
+		t.Error("pawn map does not order the pawns from the edges")
+	}
+	if syzygyBinomial[2][5] != 10 || syzygyBinomial[5][63] != 7028847 {
+		t.Error("binomial coefficients are wrong")
+	}
+	if syzygyLeadPawnsSize[1][0] != 6 || syzygyLeadPawnsSize[2][0] != 47+45+43+41+39+37 {
+		t.Error("leading pawn sizes are wrong")
+	}
+}
+
+func squareIndex(square string) int {
+	return int(SFS(square))
+}
+
+// Positions that are mirror images of each other share an index and all others get their own
+func TestSyzygyIndexEncoding(t *testing.T) {
+	directory := t.TempDir()
+	pieces := []uint8{6, 4, 14}
+	writeTestTable(t, filepath.Join(directory, "KRvK.rtbw"), false, 1, pieces, []testTableSide{{values: []int{4}}, {values: []int{0}}}, 31332)
+	table := loadTestTable(t, filepath.Join(directory, "KRvK.rtbw"), "KRvK")
+	canonicalIndexes := map[[3]int]uint64{}
+	usedIndexes := map[uint64][3]int{}
+	for whiteKing := range 64 {
+		for rook := range 64 {
+			for blackKing := range 64 {
+				if whiteKing == rook || whiteKing == blackKing || rook == blackKing {
+					continue
+				}
+				s := testTableState(White, []uint8{WhiteKing, WhiteRook, BlackKing}, []int{whiteKing, rook, blackKing})
+				_, index, _, _ := table.index(s)
+				if index >= 31332 {
+					t.Fatalf("index %d is past the end of the table", index)
+				}
+				canonical := [3]int{64, 64, 64}
+				for _, symmetry := range squareSymmetries {
+					mirrored := [3]int{symmetry(whiteKing), symmetry(rook), symmetry(blackKing)}
+					if slices.Compare(mirrored[:], canonical[:]) < 0 {
+						canonical = mirrored
+					}
+				}
+				if previous, ok := canonicalIndexes[canonical]; ok && previous != index {
+					t.Fatalf("mirrored positions of %v have indexes %d and %d", canonical, previous, index)
+				}
+				canonicalIndexes[canonical] = index
+				if other, ok := usedIndexes[index]; ok && other != canonical {
+					t.Fatalf("%v and %v share index %d", other, canonical, index)
+				}
+				usedIndexes[index] = canonical
+				// With the colors swapped the table is read from the other side
+				swapped := testTableState(Black, []uint8{BlackKing, BlackRook, WhiteKing}, []int{whiteKing ^ 56, rook ^ 56, blackKing ^ 56})
+				if _, swappedIndex, _, _ := table.index(swapped); swappedIndex != index {
+					t.Fatalf("color swapped index %d, want %d", swappedIndex, index)
+				}
+			}
+		}
+	}
+	if len(usedIndexes) != len(canonicalIndexes) {
+		t.Errorf("%d indexes for %d distinct positions", len(usedIndexes), len(canonicalIndexes))
+	}
+
+	writeTestTable(t, filepath.Join(directory, "KPvK.rtbw"), false, 3, []uint8{1, 6, 14}, []testTableSide{{values: []int{4}}, {values: []int{2}}}, 6*63*62)
+	pawnTable := loadTestTable(t, filepath.Join(directory, "KPvK.rtbw"), "KPvK")
+	type pawnKey struct {
+		file  int
+		index uint64
+	}
+	pawnIndexes := map[pawnKey][3]int{}
+	for pawn := 8; pawn < 56; pawn++ {
+		for whiteKing := range 64 {
+			for blackKing := range 64 {
+				if whiteKing == pawn || blackKing == pawn || whiteKing == blackKing {
+					continue
+				}
+				s := testTableState(White, []uint8{WhitePawn, WhiteKing, BlackKing}, []int{pawn, whiteKing, blackKing})
+				_, index, file, _ := pawnTable.index(s)
+				if index >= 6*63*62 {
+					t.Fatalf("pawn index %d is past the end of the table", index)
+				}
+				canonical := [3]int{pawn, whiteKing, blackKing}
+				if pawn%8 > 3 {
+					canonical = [3]int{pawn ^ 7, whiteKing ^ 7, blackKing ^ 7}
+				}
+				if other, ok := pawnIndexes[pawnKey{file, index}]; ok && other != canonical {
+					t.Fatalf("%v and %v share index %d on file %d", other, canonical, index, file)
+				}
+				pawnIndexes[pawnKey{file, index}] = canonical
+			}
+		}
+	}
+}
+
+func TestSyzygyDecompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "KRvK.rtbw")
+	whiteBit := func(index uint64) int { return int(index%3) & 1 }
+	blackBit := func(index uint64) int { return int(index*7/5) & 1 }
+	writeTestTable(t, path, false, 1, []uint8{6, 4, 14}, []testTableSide{{values: []int{2, 4}, symbol: whiteBit}, {values: []int{0, 2}, symbol: blackBit}}, 31332)
+	table := loadTestTable(t, path, "KRvK")
+	for whiteKing := 0; whiteKing < 64; whiteKing += 3 {
+		for rook := range 64 {
+			for blackKing := 1; blackKing < 64; blackKing += 2 {
+				if whiteKing == rook || whiteKing == blackKing || rook == blackKing {
+					continue
+				}
+				for turn := range uint8(2) {
+					s := testTableState(turn, []uint8{WhiteKing, WhiteRook, BlackKing}, []int{whiteKing, rook, blackKing})
+					_, index, _, _ := table.index(s)
+					want := [2]int{2 * whiteBit(index), 2*blackBit(index) - 2}[turn]
+					if value, result := table.probe(s, WDLDraw); result != syzygyOK || value != want {
+						t.Fatalf("probed %d for index %d with %d to move, want %d", value, index, turn, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// With a table where the rook side always wins the rook must not be left hanging
+func TestSyzygyProbe(t *testing.T) {
+	directory := t.TempDir()
+	writeTestTable(t, filepath.Join(directory, "KRvK.rtbw"), false, 1, []uint8{6, 4, 14}, []testTableSide{{values: []int{4}}, {values: []int{0}}}, 31332)
+	if err := SetSyzygyPath(directory); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSyzygyPath("")
+	if tablebases.Count() != 1 || tablebases.MaxPieces() != 3 {
+		t.Errorf("found %d tables with up to %d pieces, want 1 with 3", tablebases.Count(), tablebases.MaxPieces())
+	}
+	tests := []struct {
+		fen string
+		wdl int
+	}{
+		{"8/8/8/4k3/8/8/8/3RK3 w - - 0 1", WDLWin},
+		{"8/8/8/4k3/8/8/8/3RK3 b - - 0 1", WDLLoss},
+		{"8/8/8/4k3/3R4/8/8/4K3 b - - 0 1", WDLDraw}, // The king takes the rook
+		{"3rk3/8/8/8/4K3/8/8/8 w - - 0 1", WDLLoss},
+		{"8/8/8/4k3/8/8/8/4K3 w - - 0 1", WDLDraw},
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wdl, ok := tablebases.ProbeWDL(s); !ok || wdl != test.wdl {
+			t.Errorf("%s probed %d (%v), want %d", test.fen, wdl, ok, test.wdl)
+		}
+	}
+	s, _ := ParseFEN("8/8/8/4k3/8/8/8/3RK3 w - - 0 1")
+	if _, ok := tablebases.ProbeDTZ(s); ok {
+		t.Error("probed DTZ without a DTZ table")
+	}
+	checkRootMoves := func() {
+		t.Helper()
+		rootMoves := tablebases.RootMoves(s)
+		if len(rootMoves) == 0 {
+			t.Fatal("no root moves")
+		}
+		for _, move := range rootMoves {
+			if slices.Contains([]string{"d1d4", "d1d5", "d1d6"}, move.ShortString()) {
+				t.Errorf("%s leaves the rook hanging", move.ShortString())
+			}
+		}
+	}
+	checkRootMoves()
+	// Every position has the same distance so the only difference left is the hanging rook
+	writeTestTable(t, filepath.Join(directory, "KRvK.rtbz"), true, 1, []uint8{6, 4, 14}, []testTableSide{{values: []int{5}}}, 31332)
+	if err := SetSyzygyPath(directory); err != nil {
+		t.Fatal(err)
+	}
+	if dtz, ok := tablebases.ProbeDTZ(s); !ok || dtz != 11 {
+		t.Errorf("DTZ with white to move %d (%v), want 11", dtz, ok)
+	}
+	blackToMove, _ := ParseFEN("8/8/8/4k3/8/8/8/3RK3 b - - 0 1")
+	if dtz, ok := tablebases.ProbeDTZ(blackToMove); !ok || dtz != -12 {
+		t.Errorf("DTZ with black to move %d (%v), want -12", dtz, ok)
+	}
+	checkRootMoves()
+	clear(transpositionTable)
+	ClearSearchHistory()
+	move := s.Search(SearchLimits{maxDepth: 3}, false, nil)
+	if slices.Contains([]string{"d1d4", "d1d5", "d1d6"}, move.ShortString()) {
+		t.Errorf("search played %s with tablebases", move.ShortString())
+	}
+}
+
+// Plies to mate of every king and queen or rook against king position, found backwards from the
+// mates. Positions are turn<<18 | white king<<12 | piece<<6 | black king, draws are -1 and
+// illegal positions -2
+func solveKXvK(piece uint8) []int {
+	attacks := func(square int, occupied Bitboard) Bitboard {
+		if piece == WhiteQueen {
+			return getQueenMoves(Square(square), occupied)
+		}
+		return getRookMoves(Square(square), occupied)
+	}
+	mate := make([]int, 1<<19)
+	successors := make([][]int32, 1<<19)
+	for position := range mate {
+		turn, whiteKing, square, blackKing := position>>18, position>>12&63, position>>6&63, position&63
+		whiteKingBoard, pieceBoard, blackKingBoard := boardFromSquare(Square(whiteKing)), boardFromSquare(Square(square)), boardFromSquare(Square(blackKing))
+		occupied := whiteKingBoard | pieceBoard | blackKingBoard
+		inCheck := attacks(square, occupied)&blackKingBoard != 0
+		if BitCount(occupied) != 3 || moveBoards[King][whiteKing]&blackKingBoard != 0 || (turn == White && inCheck) {
+			mate[position] = -2
+			continue
+		}
+		mate[position] = -1
+		if turn == White {
+			for board := moveBoards[King][whiteKing] &^ pieceBoard &^ moveBoards[King][blackKing]; board != EmptyBitboard; {
+				successors[position] = append(successors[position], int32(1<<18|int(PopLSB(&board))<<12|square<<6|blackKing))
+			}
+			for board := attacks(square, occupied) &^ whiteKingBoard; board != EmptyBitboard; {
+				successors[position] = append(successors[position], int32(1<<18|whiteKing<<12|int(PopLSB(&board))<<6|blackKing))
+			}
+			continue
+		}
+		canCapture := false
+		for board := moveBoards[King][blackKing] &^ moveBoards[King][whiteKing]; board != EmptyBitboard; {
+			to := int(PopLSB(&board))
+			if to == square {
+				canCapture = true
+			} else if attacks(square, occupied&^blackKingBoard)&boardFromSquare(Square(to)) == 0 {
+				successors[position] = append(successors[position], int32(whiteKing<<12|square<<6|to))
+			}
+		}
+		if canCapture {
+			successors[position] = nil
+		} else if len(successors[position]) == 0 && inCheck {
+			mate[position] = 0
+		}
+	}
+	// White wins once one move reaches a lost position and black loses once every move does
+	for ply, changed := 1, true; changed || ply%2 == 0; ply++ {
+		changed = false
+		for position, moves := range successors {
+			if mate[position] != -1 || len(moves) == 0 || position>>18 == ply%2 {
+				continue
+			}
+			lost := position>>18 == Black
+			for _, move := range moves {
+				if lost && mate[move] < 0 {
+					lost = false
+					break
+				} else if !lost && mate[move] == ply-1 {
+					lost = true
+					break
+				}
+			}
+			if lost {
+				mate[position] = ply
+				changed = true
+			}
+		}
+	}
+	return mate
+}
+
+func kxkFEN(position int, piece uint8) string {
+	squares := [64]byte{}
+	squares[position>>12&63], squares[position>>6&63], squares[position&63] = 'K', "KQRBNP"[piece], 'k'
+	fen := strings.Builder{}
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := range 8 {
+			if squares[rank*8+file] == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			fen.WriteByte(squares[rank*8+file])
+		}
+		if empty > 0 {
+			fen.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			fen.WriteByte('/')
+		}
+	}
+	fen.WriteString([2]string{" w - - 0 1", " b - - 0 1"}[position>>18])
+	return fen.String()
+}
+
+// Official tables are not available offline, so complete KQvK and KRvK tables are solved here and
+// written in the Syzygy layout. The solutions are checked against the published longest mates of
+// 10 and 16 moves
+func writeKXvKTables(t *testing.T, directory string) map[uint8][]int {
+	solutions := map[uint8][]int{}
+	for piece, name := range map[uint8]string{WhiteQueen: "KQvK", WhiteRook: "KRvK"} {
+		mate := solveKXvK(piece)
+		longest := slices.Max(mate[:1<<18])
+		if want := map[uint8]int{WhiteQueen: 19, WhiteRook: 31}[piece]; longest != want {
+			t.Fatalf("longest %s mate is %d plies, want %d", name, longest, want)
+		}
+		solutions[piece] = mate
+		pieces := []uint8{6, syzygyPieceCodes[piece], 14}
+		indexPath := filepath.Join(t.TempDir(), name+".rtbw")
+		writeTestTable(t, indexPath, false, 1, pieces, []testTableSide{{values: []int{2}}, {values: []int{2}}}, 31332)
+		table := loadTestTable(t, indexPath, name)
+		wdl := [2][]int{make([]int, 31332), make([]int, 31332)}
+		dtz := make([]int, 31332)
+		for position, plies := range mate {
+			if plies < -1 {
+				continue
+			}
+			turn := uint8(position >> 18)
+			s := testTableState(turn, []uint8{WhiteKing, piece, BlackKing}, []int{position >> 12 & 63, position >> 6 & 63, position & 63})
+			_, index, _, _ := table.index(s)
+			if plies >= 0 {
+				wdl[turn][index] = WDLWin * [2]int{1, -1}[turn]
+			}
+			if turn == White && plies > 0 {
+				dtz[index] = plies - 1
+			}
+		}
+		side := func(flags uint8, stored []int, offset int) testTableSide {
+			values := slices.Clone(stored)
+			slices.Sort(values)
+			values = slices.Compact(values)
+			symbols := map[int]int{}
+			for symbol, value := range values {
+				symbols[value] = symbol
+				values[symbol] += offset
+			}
+			return testTableSide{flags: flags, values: values, symbol: func(index uint64) int { return symbols[stored[index]] }}
+		}
+		writeTestTable(t, filepath.Join(directory, name+".rtbw"), false, 1, pieces, []testTableSide{side(0, wdl[White], 2), side(0, wdl[Black], 2)}, 31332)
+		// Only white to move is stored, with distances in plies
+		writeTestTable(t, filepath.Join(directory, name+".rtbz"), true, 1, pieces, []testTableSide{side(syzygyFlagWinPlies|syzygyFlagLossPlies, dtz, 0)}, 31332)
+	}
+	return solutions
+}
+
+func TestSyzygyKXvK(t *testing.T) {
+	directory := t.TempDir()
+	solutions := writeKXvKTables(t, directory)
+	if err := SetSyzygyPath(directory); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSyzygyPath("")
+	tests := []struct {
+		fen string
+		wdl int
+		dtz int
+	}{
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", WDLWin, 1},    // Rh8#
+		{"k7/8/2K5/8/8/8/8/7R w - - 0 1", WDLWin, 3},    // Kb6 Kb8 Rh8#
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", WDLLoss, -2},  // Kb8 Rh8#
+		{"8/8/8/4k3/3R4/8/8/4K3 b - - 0 1", WDLDraw, 0}, // The king takes the rook
+		{"7k/8/6K1/8/8/8/8/1Q6 w - - 0 1", WDLWin, 1},   // Qb8#
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", WDLDraw, 0},  // Stalemate
+		{"8/8/8/8/8/8/8/kQK5 b - - 0 1", WDLLoss, -1},   // Mate
+		{"7r/8/8/8/8/1k6/8/K7 b - - 0 1", WDLWin, 1},    // Colors swapped, Rh1#
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wdl, ok := tablebases.ProbeWDL(s); !ok || wdl != test.wdl {
+			t.Errorf("%s probed WDL %d (%v), want %d", test.fen, wdl, ok, test.wdl)
+		}
+		if dtz, ok := tablebases.ProbeDTZ(s); !ok || dtz != test.dtz {
+			t.Errorf("%s probed DTZ %d (%v), want %d", test.fen, dtz, ok, test.dtz)
+		}
+	}
+	// WDL probes run inside the search so they must not allocate, even when captures are searched
+	for _, fen := range []string{"k7/8/2K5/8/8/8/8/7R w - - 0 1", "8/8/8/4k3/3R4/8/8/4K3 b - - 0 1"} {
+		s, _ := ParseFEN(fen)
+		tablebases.ProbeWDL(s)
+		if allocs := testing.AllocsPerRun(100, func() { tablebases.ProbeWDL(s) }); allocs != 0 {
+			t.Errorf("%s WDL probe made %.1f allocations, want 0", fen, allocs)
+		}
+	}
+	// Every probe agrees with the solution for a spread of positions
+	for piece, mate := range solutions {
+		for position := 0; position < len(mate); position += 211 {
+			if mate[position] < -1 {
+				continue
+			}
+			s, err := ParseFEN(kxkFEN(position, piece))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantWDL, wantDTZ := WDLDraw, 0
+			if mate[position] >= 0 {
+				wantWDL, wantDTZ = WDLWin, max(mate[position], 1)
+				if s.turn == Black {
+					wantWDL, wantDTZ = WDLLoss, -wantDTZ
+				}
+			}
+			wdl, wdlOK := tablebases.ProbeWDL(s)
+			dtz, dtzOK := tablebases.ProbeDTZ(s)
+			if !wdlOK || !dtzOK || wdl != wantWDL || dtz != wantDTZ {
+				t.Fatalf("%s probed WDL %d and DTZ %d, want %d and %d", kxkFEN(position, piece), wdl, dtz, wantWDL, wantDTZ)
+			}
+		}
+	}
+	// Root moves are exactly the ones keeping the shortest mate, and never the stalemate
+	rootTests := []struct {
+		fen      string
+		excluded string
+	}{
+		{"k7/8/2K5/8/8/8/8/7R w - - 0 1", "h1h8"},
+		{"7k/8/6K1/8/8/8/8/5Q2 w - - 0 1", "f1f7"},
+		{"8/2k5/8/8/8/8/8/K6R w - - 0 1", "h1h7"},
+	}
+	for _, test := range rootTests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dtz, _ := tablebases.ProbeDTZ(s)
+		want := []string{}
+		for _, move := range LegalMoves(s) {
+			s.MakeMove(move)
+			if moveDTZ, _ := tablebases.ProbeDTZ(s); (dtz > 1 && moveDTZ == 1-dtz) || (dtz == 1 && s.check && len(LegalMoves(s)) == 0) {
+				want = append(want, move.ShortString())
+			}
+			s.UnMakeMove(move)
+		}
+		got := []string{}
+		for _, move := range tablebases.RootMoves(s) {
+			got = append(got, move.ShortString())
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		if len(got) == 0 || !slices.Equal(got, want) || slices.Contains(got, test.excluded) {
+			t.Errorf("%s root moves %v, want %v", test.fen, got, want)
+		}
+	}
+}
+
+// The published KQvK, KRvK and KPvK tables, when copied into testdata/syzygy, are checked against
+// known results and the solutions above. They are not part of the repository so the test is
+// skipped without them
+func TestSyzygyRealTables(t *testing.T) {
+	directory := filepath.Join("testdata", "syzygy")
+	for _, name := range []string{"KQvK", "KRvK", "KPvK"} {
+		for _, extension := range []string{".rtbw", ".rtbz"} {
+			if _, err := os.Stat(filepath.Join(directory, name+extension)); err != nil {
+				t.Skipf("%s%s not found in %s", name, extension, directory)
+			}
+		}
+	}
+	if err := SetSyzygyPath(directory); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSyzygyPath("")
+	tests := []struct {
+		fen string
+		wdl int
+		dtz int
+	}{
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", WDLWin, 1},    // Rh8#
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", WDLLoss, -2},  // Kb8 Rh8#
+		{"8/8/8/4k3/3R4/8/8/4K3 b - - 0 1", WDLDraw, 0}, // The king takes the rook
+		{"7k/8/6K1/8/8/8/8/1Q6 w - - 0 1", WDLWin, 1},   // Qb8#
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", WDLDraw, 0},  // Stalemate
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", WDLWin, 1},   // e8=Q
+		{"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", WDLDraw, 0}, // Stalemate
+		{"7k/8/6K1/7P/8/8/8/8 w - - 0 1", WDLDraw, 0},   // Rook pawn with the king in the corner
+	}
+	for _, test := range tests {
+		s, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wdl, ok := tablebases.ProbeWDL(s); !ok || wdl != test.wdl {
+			t.Errorf("%s probed WDL %d (%v), want %d", test.fen, wdl, ok, test.wdl)
+		}
+		if dtz, ok := tablebases.ProbeDTZ(s); !ok || dtz != test.dtz {
+			t.Errorf("%s probed DTZ %d (%v), want %d", test.fen, dtz, ok, test.dtz)
+		}
+	}
+	// Published tables may round DTZ to whole moves, so it is only checked to within a ply
+	for _, piece := range []uint8{WhiteQueen, WhiteRook} {
+		mate := solveKXvK(piece)
+		for position := 0; position < len(mate); position += 97 {
+			if mate[position] < -1 {
+				continue
+			}
+			s, err := ParseFEN(kxkFEN(position, piece))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantWDL, wantDTZ := WDLDraw, 0
+			if mate[position] >= 0 {
+				wantWDL, wantDTZ = WDLWin, max(mate[position], 1)
+				if s.turn == Black {
+					wantWDL, wantDTZ = WDLLoss, -wantDTZ
+				}
+			}
+			wdl, wdlOK := tablebases.ProbeWDL(s)
+			dtz, dtzOK := tablebases.ProbeDTZ(s)
+			if !wdlOK || !dtzOK || wdl != wantWDL || dtz-wantDTZ > 1 || wantDTZ-dtz > 1 || signOf(dtz) != signOf(wantDTZ) {
+				t.Fatalf("%s probed WDL %d and DTZ %d, want %d and %d", kxkFEN(position, piece), wdl, dtz, wantWDL, wantDTZ)
+			}
+		}
+	}
+}
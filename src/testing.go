@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,25 +13,75 @@ var genTimer = NewRunningTimer()
 var makeTimer = NewRunningTimer()
 var unMakeTimer = NewRunningTimer()
 
+type PerftDivideEntry struct {
+	move  Move
+	nodes int64
+}
+
 func PerftChecker(depth int64, s *State) {
 	currentDepth := depth
 	for {
-		moves := s.quickGenMoves()
-		for i, move := range *moves {
-			fmt.Print(move.ShortString())
+		entries := PerftDivide(currentDepth, s)
+		for i, entry := range entries {
+			fmt.Print(entry.move.ShortString())
 			fmt.Printf(", Move %d: ", i)
-			s.MakeMove(move)
-			var counter int64 = 0
-			Perft(currentDepth-1, &counter, s)
-			fmt.Println(counter)
-			s.UnMakeMove(move)
+			fmt.Println(entry.nodes)
 		}
 		move_selection := GetUserNumber("Enter move number: ")
-		s.MakeMove((*moves)[move_selection])
+		s.MakeMove(entries[move_selection].move)
 		currentDepth--
 	}
 }
 
+// Node count below each legal move, which makes it possible to find the move a generator bug is
+// under by comparing against another engine
+func PerftDivide(depth int64, s *State) []PerftDivideEntry {
+	entries := []PerftDivideEntry{}
+	for _, move := range LegalMoves(s) {
+		s.MakeMove(move)
+		var counter int64 = 0
+		Perft(depth-1, &counter, s)
+		s.UnMakeMove(move)
+		entries = append(entries, PerftDivideEntry{move, counter})
+	}
+	return entries
+}
+
+// Format is "perft <depth> [fen]", the moves are printed in coordinate order so the output can be
+// diffed against other engines
+func PerftCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ghobos perft <depth> [fen]")
+	}
+	depth, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || depth < 1 {
+		return fmt.Errorf("invalid depth %q", args[0])
+	}
+	fenString := startingFenString
+	if len(args) > 1 {
+		fenString = strings.Join(args[1:], " ")
+	}
+	s, err := ParseFEN(fenString)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	entries := PerftDivide(depth, s)
+	duration := time.Since(start)
+	slices.SortFunc(entries, func(a, b PerftDivideEntry) int {
+		return strings.Compare(a.move.ShortString(), b.move.ShortString())
+	})
+	var total int64 = 0
+	for _, entry := range entries {
+		fmt.Printf("%s: %d\n", entry.move.ShortString(), entry.nodes)
+		total += entry.nodes
+	}
+	fmt.Println()
+	fmt.Println("Nodes searched:", total)
+	fmt.Printf("Time: %v, %.2f Million Nodes per Second\n", duration.Round(time.Millisecond), float64(total)/duration.Seconds()/1_000_000.0)
+	return nil
+}
+
 func PerftTester() {
 	PerftRunner(6, FenState("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"), 119060324)
 	PerftRunner(5, FenState("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"), 193690690)
@@ -56,9 +110,9 @@ func PerftRunner(depth int64, s *State, expectedCount int64) {
 func Perft(depth int64, moveCounter *int64, s *State) {
 	if depth != 0 {
 		genTimer.Start()
-		moves := s.quickGenMoves()
+		moves := LegalMoves(s)
 		genTimer.Stop()
-		for _, move := range *moves {
+		for _, move := range moves {
 			makeTimer.Start()
 			s.MakeMove(move)
 			makeTimer.Stop()
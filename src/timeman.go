@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+const (
+	MoveOverhead = 30 * time.Millisecond // Reserved for communication lag with the interface
+
+	suddenDeathMovesToGo = 30 // Assumed number of moves left when the whole game is played on one clock
+	maxMovesToGo         = 50
+
+	hardLimitFactor    = 5    // How many times the soft limit the search may run before being aborted
+	maxSoftShare       = 0.4  // Largest fraction of the clock a single move may plan to use
+	maxHardShare       = 0.75 // Largest fraction of the clock a single move may ever use
+	lastMoveHardShare  = 0.9  // Used instead when the control resets after this move
+	scoreDropThreshold = CentiPawn * 30
+	scoreDropScale     = CentiPawn * 100
+	maxScoreDropBonus  = 0.5
+)
+
+// Indexed by how many iterations in a row the best move has stayed the same
+var bestMoveStabilityScale [5]float64 = [5]float64{1.5, 1.2, 1.0, 0.85, 0.7}
+
+type TimeManager struct {
+	softLimit         time.Duration // No new iteration is started after this
+	hardLimit         time.Duration // The search is aborted after this
+	scale             float64       // Applied to the soft limit based on how the search is going
+	bestMove          Move
+	bestMoveStability int
+	lastScore         int32
+	iterations        int
+}
+
+// movesToGo of zero means the rest of the game must be played on the remaining time
+func NewTimeManager(timeLeft time.Duration, increment time.Duration, movesToGo int) *TimeManager {
+	available := max(timeLeft-MoveOverhead, time.Millisecond)
+	if movesToGo == 0 {
+		movesToGo = suddenDeathMovesToGo
+	}
+	movesToGo = min(movesToGo, maxMovesToGo)
+	softLimit := available/time.Duration(movesToGo) + increment*3/4
+	hardLimit := softLimit * hardLimitFactor
+	if movesToGo == 1 {
+		hardLimit = min(hardLimit, time.Duration(float64(available)*lastMoveHardShare))
+	} else {
+		softLimit = min(softLimit, time.Duration(float64(available)*maxSoftShare))
+		hardLimit = min(hardLimit, time.Duration(float64(available)*maxHardShare))
+	}
+	softLimit = min(softLimit, hardLimit)
+	return &TimeManager{
+		softLimit:         softLimit,
+		hardLimit:         hardLimit,
+		scale:             1.0,
+		bestMove:          NilMove,
+		bestMoveStability: 2,
+	}
+}
+
+// Called after every completed iteration with its result
+func (tm *TimeManager) update(bestMove Move, score int32) {
+	if tm.iterations > 0 {
+		if bestMove == tm.bestMove {
+			tm.bestMoveStability = min(tm.bestMoveStability+1, len(bestMoveStabilityScale)-1)
+		} else {
+			tm.bestMoveStability = 0
+		}
+	}
+	tm.scale = bestMoveStabilityScale[tm.bestMoveStability]
+	// Spend more time when the score falls so the search can look for a way out
+	scoreDrop := int64(tm.lastScore) - int64(score)
+	if tm.iterations > 0 && scoreDrop > int64(scoreDropThreshold) {
+		tm.scale *= 1.0 + min(float64(scoreDrop)/float64(scoreDropScale), 1.0)*maxScoreDropBonus
+	}
+	tm.bestMove = bestMove
+	tm.lastScore = score
+	tm.iterations++
+}
+
+func (tm *TimeManager) shouldStop(elapsed time.Duration) bool {
+	return elapsed >= min(time.Duration(float64(tm.softLimit)*tm.scale), tm.hardLimit)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewTimeManager(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeLeft  time.Duration
+		increment time.Duration
+		movesToGo int
+		softLimit time.Duration
+		hardLimit time.Duration
+	}{
+		{"sudden death", time.Minute, 0, 0, 1999 * time.Millisecond, 9995 * time.Millisecond},
+		{"sudden death with increment", time.Minute, time.Second, 0, 2749 * time.Millisecond, 13745 * time.Millisecond},
+		{"moves to go", 10 * time.Second, 0, 40, 249250 * time.Microsecond, 1246250 * time.Microsecond},
+		{"moves to go capped", time.Minute, 0, 100, 1199400 * time.Microsecond, 5997 * time.Millisecond},
+		{"increment beyond the clock", time.Second, 2 * time.Second, 0, 388 * time.Millisecond, 727500 * time.Microsecond},
+		{"last move", 10 * time.Second, 0, 1, 8973 * time.Millisecond, 8973 * time.Millisecond},
+		{"last move with increment", 2 * time.Second, time.Second, 1, 1773 * time.Millisecond, 1773 * time.Millisecond},
+		{"low time", 100 * time.Millisecond, time.Second, 0, 28 * time.Millisecond, 52500 * time.Microsecond},
+		{"low time on the last move", 100 * time.Millisecond, time.Second, 1, 63 * time.Millisecond, 63 * time.Millisecond},
+		{"clock below the overhead", 20 * time.Millisecond, 0, 0, 33333 * time.Nanosecond, 166665 * time.Nanosecond},
+	}
+	for _, test := range tests {
+		tm := NewTimeManager(test.timeLeft, test.increment, test.movesToGo)
+		// Float rounding may move a limit by a nanosecond
+		if (tm.softLimit-test.softLimit).Abs() > time.Microsecond || (tm.hardLimit-test.hardLimit).Abs() > time.Microsecond {
+			t.Errorf("%s: limits %v and %v, want %v and %v", test.name, tm.softLimit, tm.hardLimit, test.softLimit, test.hardLimit)
+		}
+	}
+	// Whatever the clock, the search never plans to use more than is left after the overhead
+	for _, timeLeft := range []time.Duration{0, 10 * time.Millisecond, 31 * time.Millisecond, 100 * time.Millisecond, time.Second, time.Minute, time.Hour} {
+		for _, increment := range []time.Duration{0, 100 * time.Millisecond, time.Second, 10 * time.Second} {
+			for _, movesToGo := range []int{0, 1, 2, 40, 100} {
+				tm := NewTimeManager(timeLeft, increment, movesToGo)
+				available := max(timeLeft-MoveOverhead, time.Millisecond)
+				if tm.softLimit <= 0 || tm.softLimit > tm.hardLimit || tm.hardLimit > available {
+					t.Errorf("%v+%v with %d moves to go has limits %v and %v, want 0 < soft <= hard <= %v", timeLeft, increment, movesToGo,
+						tm.softLimit, tm.hardLimit, available)
+				}
+			}
+		}
+	}
+}
+
+func TestTimeManagerUpdate(t *testing.T) {
+	first, second := SimpleMoveFromString("e2e4"), SimpleMoveFromString("d2d4")
+	steps := []struct {
+		move  Move
+		score int32
+		scale float64
+	}{
+		{first, -5 * CentiPawn, 1.0}, // No score drop on the first iteration
+		{first, -5 * CentiPawn, 0.85},
+		{first, 0, 0.7},
+		{first, 0, 0.7},
+		{second, 0, 1.5},
+		{second, -20 * CentiPawn, 1.2},         // Below the threshold
+		{second, -70 * CentiPawn, 1.0 * 1.25},  // 50 centipawns
+		{second, -400 * CentiPawn, 0.85 * 1.5}, // Bonus capped
+		{first, -400 * CentiPawn, 1.5},
+	}
+	tm := NewTimeManager(time.Minute, 0, 0)
+	for i, step := range steps {
+		tm.update(step.move, step.score)
+		if math.Abs(tm.scale-step.scale) > 1e-9 {
+			t.Errorf("iteration %d has scale %.3f, want %.3f", i+1, tm.scale, step.scale)
+		}
+	}
+	// The soft limit is scaled, but never past the hard limit
+	if tm.shouldStop(tm.softLimit) || !tm.shouldStop(time.Duration(float64(tm.softLimit)*1.5)) {
+		t.Errorf("stopping at the soft limit %v with scale %.2f", tm.softLimit, tm.scale)
+	}
+	tm.scale = 10
+	if !tm.shouldStop(tm.hardLimit) {
+		t.Errorf("not stopping at the hard limit %v with scale %.2f", tm.hardLimit, tm.scale)
+	}
+}
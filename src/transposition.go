@@ -1,5 +1,7 @@
 package main
 
+import "sync/atomic"
+
 // first 14 bits contain depth that this node was searched to
 // last 2 bits contain what type of node it is 0 = PV, 1 = Cut, 2 = All
 type NodeInfo uint16
@@ -11,9 +13,12 @@ type TableData struct {
 	ply          uint16
 	depthAndNode NodeInfo
 }
+
+// The table is shared between search threads without locking. The key is stored xored with the
+// packed data so an entry torn by two threads writing at once reads back as a miss
 type TableEntry struct {
-	key  uint64
-	data TableData
+	key  atomic.Uint64
+	data atomic.Uint64
 }
 
 type TranspositionTable []TableEntry
@@ -42,27 +47,46 @@ var transpositionTable TranspositionTable
 
 // capacity is in megabytes
 func SetupTable(capacity uint64) {
+	ResizeTable(capacity)
+	SetupHashRandoms()
+}
+
+// Replaces the table without changing the hash randoms so existing states keep valid hashcodes
+func ResizeTable(capacity uint64) {
+	// Dropped first so the old table can be collected before the new one is allocated
+	transpositionTable = nil
 	tableCapacity = capacity * megabytesToBytes
 	tableSize = tableCapacity / EntrySize
 	transpositionTable = make([]TableEntry, tableSize)
-	SetupHashRandoms()
 }
 
 func (tt *TranspositionTable) AddState(s *State, eval int32, bestMove Move, depth uint16, nodeType NodeType) {
 	hash := s.hashcode
-	index := hash % tableSize
-	(*tt)[index] = TableEntry{key: hash, data: TableData{eval: EvalHighToLow(eval), bestMove: bestMove, ply: s.ply, depthAndNode: NodeInfo(nodeType)<<14 | NodeInfo(depth)}}
+	entry := &(*tt)[hash%tableSize]
+	data := TableData{eval: EvalHighToLow(eval), bestMove: bestMove, ply: s.ply, depthAndNode: NodeInfo(nodeType)<<14 | NodeInfo(depth)}
+	packed := data.pack()
+	entry.data.Store(packed)
+	entry.key.Store(hash ^ packed)
 }
 
 func (tt *TranspositionTable) SearchState(s *State) (TableData, bool) {
 	hash := s.hashcode
-	index := hash % tableSize
-	if (*tt)[index].key == hash {
-		return (*tt)[index].data, true
+	entry := &(*tt)[hash%tableSize]
+	packed := entry.data.Load()
+	if entry.key.Load()^packed == hash {
+		return unpackTableData(packed), true
 	}
 	return TableData{}, false
 }
 
+func (data *TableData) pack() uint64 {
+	return uint64(uint16(data.eval)) | uint64(data.bestMove)<<16 | uint64(data.ply)<<32 | uint64(data.depthAndNode)<<48
+}
+
+func unpackTableData(packed uint64) TableData {
+	return TableData{eval: int16(packed), bestMove: Move(packed >> 16), ply: uint16(packed >> 32), depthAndNode: NodeInfo(packed >> 48)}
+}
+
 // First return is depth, second is node type
 func (nI *NodeInfo) parseDepthandNode() (uint16, NodeType) {
 	return uint16(*nI) & bitMask14, NodeType(uint16(*nI>>14) & BitMask2)
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TunerSettings struct {
+	dataPath   string
+	outPath    string
+	iterations int
+	k          float64 // Fitted to the data when 0
+	lambda     float64 // How much of the target is the game result rather than the search score
+	threads    int
+	terms      []string // Prefixes of the term names to tune, every term when empty
+}
+
+// The quiet position the tuner evaluates and the value the evaluation should predict, from white's
+// point of view
+type TuningPosition struct {
+	board  Board
+	turn   uint8
+	result float64
+	score  float64
+}
+
+type Tuner struct {
+	settings  *TunerSettings
+	positions []TuningPosition
+	states    []*State // One scratch state per thread
+	k         float64
+}
+
+// Parses the command line of "ghobos tune" and tunes the evaluation
+func TuneCommand(args []string) error {
+	settings, err := parseTunerFlags(args)
+	if err != nil {
+		return err
+	}
+	tuner, err := NewTuner(settings)
+	if err != nil {
+		return err
+	}
+	return tuner.Run()
+}
+
+func parseTunerFlags(args []string) (*TunerSettings, error) {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	outPath := flags.String("out", "eval_params.go", "file the tuned weights are written to after every iteration, JSON if it ends in .json and Go source otherwise")
+	evalParamsPath := flags.String("evalparams", "", "JSON file with the weights to start from instead of the defaults")
+	iterations := flags.Int("iterations", 100, "maximum passes over every weight, tuning also stops once a pass improves nothing")
+	k := flags.Float64("k", 0, "scaling of the sigmoid that maps centipawns to an expected score, 0 fits it to the data")
+	lambda := flags.Float64("lambda", 1, "weight of the game result in the target, the rest comes from the search score")
+	threads := flags.Int("threads", 1, "threads used to compute the error")
+	terms := flags.String("terms", "", "comma separated prefixes of the terms to tune, eg \"Endgame.PieceValues,Midgame.PieceSquare\", empty tunes all of them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ghobos tune [flags] data")
+		fmt.Fprintln(flags.Output(), "The data is a file written by \"ghobos datagen\". Terms:")
+		for _, term := range evalParams.terms() {
+			fmt.Fprintf(flags.Output(), "  %s (%d values)\n", term.name, len(term.values))
+		}
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != 1 {
+		return nil, errors.New("expected exactly one data file")
+	}
+	if *lambda < 0 || *lambda > 1 {
+		return nil, errors.New("lambda must be between 0 and 1")
+	}
+	if err := SetEvalParamsFile(*evalParamsPath); err != nil {
+		return nil, err
+	}
+	settings := &TunerSettings{
+		dataPath:   flags.Arg(0),
+		outPath:    *outPath,
+		iterations: *iterations,
+		k:          *k,
+		lambda:     *lambda,
+		threads:    max(*threads, 1),
+	}
+	if *terms != "" {
+		settings.terms = strings.Split(*terms, ",")
+	}
+	return settings, nil
+}
+
+// Loads the data and replaces every position with the end of its quiescence search, so the static
+// evaluation of the stored positions is what the quiescence search would return
+func NewTuner(settings *TunerSettings) (*Tuner, error) {
+	trainingPositions, err := ReadTrainingFile(settings.dataPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(trainingPositions) == 0 {
+		return nil, fmt.Errorf("%s contains no positions", settings.dataPath)
+	}
+	tuner := &Tuner{settings: settings, positions: make([]TuningPosition, len(trainingPositions))}
+	worker := NewSearchWorker(0)
+	for i, trainingPosition := range trainingPositions {
+		s, err := ParseFEN(trainingPosition.fen)
+		if err != nil {
+			return nil, err
+		}
+		worker.quiescenceLeaf(s)
+		tuner.positions[i] = TuningPosition{s.board, s.turn, trainingPosition.result, float64(trainingPosition.score)}
+	}
+	for range settings.threads {
+		tuner.states = append(tuner.states, StartingFen())
+	}
+	return tuner, nil
+}
+
+// Follows the quiescence search's best moves, which the full window makes exact at every ply
+func (w *SearchWorker) quiescenceLeaf(s *State) {
+	for {
+		w.prepare(s, SearchLimits{}, time.Now(), nil)
+		_, move := w.QuiescenceSearch(LowestEval, highestEval)
+		if move == NilMove {
+			return
+		}
+		s.MakeMove(move)
+	}
+}
+
+// Texel's local search: every weight is moved by one in either direction and the change is kept
+// if it lowers the error over the data
+func (tuner *Tuner) Run() error {
+	terms := tuner.selectedTerms()
+	if len(terms) == 0 {
+		return errors.New("no terms match -terms")
+	}
+	tuner.k = tuner.settings.k
+	if tuner.k == 0 {
+		tuner.k = tuner.fitK()
+	}
+	fmt.Printf("Tuning %d terms on %d positions with K %.4f\n", len(terms), len(tuner.positions), tuner.k)
+	bestError := tuner.error()
+	fmt.Printf("Initial error %.8f\n", bestError)
+	for iteration := 1; iteration <= tuner.settings.iterations; iteration++ {
+		start := time.Now()
+		improved := 0
+		for _, term := range terms {
+			for _, value := range term.values {
+				original := *value
+				for _, delta := range []int32{1, -1} {
+					*value = original + delta
+					setupEvalValues()
+					if tunedError := tuner.error(); tunedError < bestError {
+						bestError = tunedError
+						improved++
+						break
+					}
+					*value = original
+				}
+			}
+		}
+		setupEvalValues()
+		fmt.Printf("Iteration %d: error %.8f, %d weights changed in %s\n", iteration, bestError, improved, time.Since(start).Round(time.Millisecond))
+		if err := tuner.write(); err != nil {
+			return err
+		}
+		if improved == 0 {
+			break
+		}
+	}
+	fmt.Println("Wrote", tuner.settings.outPath)
+	return nil
+}
+
+func (tuner *Tuner) write() error {
+	if strings.HasSuffix(tuner.settings.outPath, ".json") {
+		return SaveEvalParams(tuner.settings.outPath, &evalParams)
+	}
+	return WriteEvalParamsSource(tuner.settings.outPath, &evalParams)
+}
+
+func (tuner *Tuner) selectedTerms() []EvalTerm {
+	terms := evalParams.terms()
+	if len(tuner.settings.terms) == 0 {
+		return terms
+	}
+	return slices.DeleteFunc(terms, func(term EvalTerm) bool {
+		return !slices.ContainsFunc(tuner.settings.terms, func(prefix string) bool { return strings.HasPrefix(term.name, prefix) })
+	})
+}
+
+func sigmoid(k float64, score float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*score/400))
+}
+
+func (tuner *Tuner) error() float64 {
+	return tuner.errorFor(tuner.k, tuner.settings.lambda)
+}
+
+// Mean squared difference between the targets and the expected scores of the static evaluations
+func (tuner *Tuner) errorFor(k float64, lambda float64) float64 {
+	threads := len(tuner.states)
+	threadErrors := make([]float64, threads)
+	chunkSize := (len(tuner.positions) + threads - 1) / threads
+	wait := sync.WaitGroup{}
+	for thread := range threads {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			s := tuner.states[thread]
+			for _, position := range tuner.positions[min(thread*chunkSize, len(tuner.positions)):min((thread+1)*chunkSize, len(tuner.positions))] {
+				s.setBoard(position.board, position.turn)
+				eval := float64(s.EvalState(White)) / float64(CentiPawn)
+				target := lambda*position.result + (1-lambda)*sigmoid(k, position.score)
+				difference := target - sigmoid(k, eval)
+				threadErrors[thread] += difference * difference
+			}
+		}()
+	}
+	wait.Wait()
+	total := 0.0
+	for _, threadError := range threadErrors {
+		total += threadError
+	}
+	return total / float64(len(tuner.positions))
+}
+
+// Golden section search for the K that best predicts the results from the current evaluation
+func (tuner *Tuner) fitK() float64 {
+	ratio := (math.Sqrt(5) - 1) / 2
+	low, high := 0.0, 5.0
+	for high-low > 0.0001 {
+		left := high - ratio*(high-low)
+		right := low + ratio*(high-low)
+		if tuner.errorFor(left, 1) < tuner.errorFor(right, 1) {
+			high = right
+		} else {
+			low = left
+		}
+	}
+	return (low + high) / 2
+}
+
+// Only what the evaluation reads is set, so the state can not be searched afterwards
+func (s *State) setBoard(board Board, turn uint8) {
+	s.board = board
+	s.sideOccupied = [2]Bitboard{}
+	for i := 0; i < 6; i++ {
+		s.sideOccupied[White] |= board[i]
+		s.sideOccupied[Black] |= board[6+i]
+	}
+	s.occupied = s.sideOccupied[White] | s.sideOccupied[Black]
+	s.notOccupied = ^s.occupied
+	s.turn = turn
+	s.pinInfo.pinsSet = [2]bool{}
+}
+
+func WriteEvalParamsSource(path string, params *EvalParams) error {
+	formatted, err := format.Source(generateEvalParamsSource(params))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, formatted, 0o644)
+}
+
+func generateEvalParamsSource(params *EvalParams) []byte {
+	source := bytes.Buffer{}
+	source.WriteString("// Code generated by \"ghobos tune\"; DO NOT EDIT.\n\npackage main\n\n")
+	joinValues := func(values []int32) string {
+		valueStrings := make([]string, len(values))
+		for i, value := range values {
+			valueStrings[i] = fmt.Sprint(value)
+		}
+		return strings.Join(valueStrings, ", ")
+	}
+	writeValues := func(values []int32, perLine int) {
+		for i, value := range values {
+			fmt.Fprintf(&source, "%d,", value)
+			if i%perLine == perLine-1 {
+				source.WriteString("\n")
+			}
+		}
+	}
+	source.WriteString("var defaultEvalParams EvalParams = EvalParams{\n")
+	for _, phase := range []struct {
+		name    string
+		weights *PhaseWeights
+	}{{"Midgame", &params.Midgame}, {"Endgame", &params.Endgame}} {
+		weights := phase.weights
+		fmt.Fprintf(&source, "%s: PhaseWeights{\n", phase.name)
+		fmt.Fprintf(&source, "PieceValues: [6]int32{%s},\n", joinValues(weights.PieceValues[:]))
+		fmt.Fprintf(&source, "DoubledPawn: %d,\n", weights.DoubledPawn)
+		fmt.Fprintf(&source, "IsolatedPawn: %d,\n", weights.IsolatedPawn)
+		fmt.Fprintf(&source, "OpenFileRook: %d,\n", weights.OpenFileRook)
+		fmt.Fprintf(&source, "Mobility: %d,\n", weights.Mobility)
+		source.WriteString("KingSafety: [60]int32{\n")
+		writeValues(weights.KingSafety[:], 10)
+		source.WriteString("},\n")
+		source.WriteString("PieceSquare: [6][64]int32{\n")
+		for piece, values := range weights.PieceSquare {
+			fmt.Fprintf(&source, "{ // %s\n", pieceNames[piece])
+			writeValues(values[:], 8)
+			source.WriteString("},\n")
+		}
+		source.WriteString("},\n")
+		fmt.Fprintf(&source, "PassedPawn: [8]int32{%s},\n", joinValues(weights.PassedPawn[:]))
+		fmt.Fprintf(&source, "PassedPawnFreePath: [8]int32{%s},\n", joinValues(weights.PassedPawnFreePath[:]))
+		fmt.Fprintf(&source, "PassedPawnBlocked: %d,\n", weights.PassedPawnBlocked)
+		fmt.Fprintf(&source, "PassedPawnSupported: %d,\n", weights.PassedPawnSupported)
+		fmt.Fprintf(&source, "PassedPawnConnected: %d,\n", weights.PassedPawnConnected)
+		fmt.Fprintf(&source, "PassedPawnKingDistance: %d,\n", weights.PassedPawnKingDistance)
+		fmt.Fprintf(&source, "PassedPawnEnemyKingDistance: %d,\n", weights.PassedPawnEnemyKingDistance)
+		fmt.Fprintf(&source, "PassedPawnUnstoppable: %d,\n", weights.PassedPawnUnstoppable)
+		source.WriteString("},\n")
+	}
+	fmt.Fprintf(&source, "KingAttackWeights: [6]int32{%s},\n", joinValues(params.KingAttackWeights[:]))
+	source.WriteString("}\n")
+	return source.Bytes()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvalParamsUpToDate(t *testing.T) {
+	generated, err := format.Source(generateEvalParamsSource(&defaultEvalParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing, err := os.ReadFile("eval_params.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, existing) {
+		t.Error("eval_params.go does not match the source written for defaultEvalParams")
+	}
+}
+
+// The positions are labelled against the material so lowering the knight's value has to help
+func TestTuner(t *testing.T) {
+	t.Cleanup(func() {
+		evalParams = defaultEvalParams
+		setupEvalValues()
+	})
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.txt")
+	data := "4k3/8/8/8/8/8/8/2N1K3 w - - 0 1;0;0.5\n" +
+		"4k3/8/8/8/8/8/8/1N2K3 b - - 0 1;0;0.5\n" +
+		"4k3/8/8/8/8/8/P7/4K3 w - - 0 1;0;1.0\n" +
+		"4k3/p7/8/8/8/8/8/4K3 w - - 0 1;0;0.0\n"
+	if err := os.WriteFile(dataPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings := &TunerSettings{dataPath: dataPath, outPath: filepath.Join(dir, "eval_params.go"), iterations: 3, k: 1, lambda: 1, threads: 2,
+		terms: []string{"Endgame.PieceValues"}}
+	tuner, err := NewTuner(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuner.k = settings.k
+	before := tuner.error()
+	if err := tuner.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if after := tuner.error(); after >= before {
+		t.Errorf("error went from %f to %f", before, after)
+	}
+	if evalParams.Endgame.PieceValues[Knight] >= defaultEvalParams.Endgame.PieceValues[Knight] {
+		t.Errorf("knight value %d was not lowered", evalParams.Endgame.PieceValues[Knight])
+	}
+	if _, err := os.Stat(settings.outPath); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EngineName   = "Ghobos"
+	EngineAuthor = "csgarlock"
+
+	DefaultHashSize uint64 = 4096
+	MinHashSize     uint64 = 1
+	MaxHashSize     uint64 = 65536
+)
+
+// Set by the UCI_Chess960 option, castling moves are then written as the king capturing its own rook
+var chess960 bool
+
+type UCIEngine struct {
+	state     *State
+	hashSize  uint64
+	tableSize uint64 // Size the table was last allocated with, 0 before the first allocation
+	searching sync.WaitGroup
+	running   atomic.Bool // Set while a go command is searching, which changes the state
+}
+
+type UCIGoParameters struct {
+	timeLeft  [2]time.Duration
+	increment [2]time.Duration
+	movesToGo int
+	moveTime  time.Duration
+	depth     int32
+	nodes     uint64
+	infinite  bool
+}
+
+// Expects the initial "uci" command to have already been read from standard input. The table is
+// allocated once the GUI is done setting options, at the first isready, ucinewgame or go, so
+// setting a smaller Hash never needs memory for the default sized table as well
+func UCILoop() {
+	SetupHashRandoms()
+	engine := &UCIEngine{state: StartingFen(), hashSize: DefaultHashSize}
+	engine.identify()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			engine.identify()
+		case "isready":
+			engine.ensureTable()
+			fmt.Println("readyok")
+		case "setoption":
+			engine.setOption(fields[1:])
+		case "ucinewgame":
+			engine.newGame()
+		case "position":
+			engine.setPosition(fields[1:])
+		case "go":
+			engine.goSearch(fields[1:])
+		case "eval":
+			engine.printEval()
+		case "stop":
+			stopSearch.Store(true)
+		case "quit":
+			stopSearch.Store(true)
+			engine.searching.Wait()
+			return
+		default:
+			fmt.Println("info string Unknown command:", fields[0])
+		}
+	}
+	stopSearch.Store(true)
+	engine.searching.Wait()
+}
+
+func (engine *UCIEngine) identify() {
+	fmt.Println("id name", EngineName)
+	fmt.Println("id author", EngineAuthor)
+	fmt.Printf("option name Hash type spin default %d min %d max %d\n", DefaultHashSize, MinHashSize, MaxHashSize)
+	fmt.Printf("option name Threads type spin default %d min 1 max %d\n", DefaultSearchThreads, MaxSearchThreads)
+	fmt.Println("option name BookFile type string default <empty>")
+	fmt.Printf("option name BookDepth type spin default %d min 0 max %d\n", DefaultBookDepth, MaxBookDepth)
+	fmt.Printf("option name BookVariety type spin default %d min 0 max 100\n", DefaultBookVariety)
+	fmt.Println("option name SyzygyPath type string default <empty>")
+	fmt.Println("option name EvalFile type string default <empty>")
+	fmt.Println("option name UseNNUE type check default false")
+	fmt.Println("option name UCI_Chess960 type check default false")
+	fmt.Println("option name EvalParamsFile type string default <empty>")
+	for _, term := range defaultEvalParams.terms() {
+		if len(term.values) == 1 {
+			fmt.Printf("option name %s type spin default %d min %d max %d\n", term.name, *term.values[0], -MaxEvalParam, MaxEvalParam)
+		}
+	}
+	fmt.Println("uciok")
+}
+
+// Format is "name <id> [value <x>]"
+func (engine *UCIEngine) setOption(fields []string) {
+	engine.searching.Wait()
+	name, value := parseOptionFields(fields)
+	switch strings.ToLower(name) {
+	case "hash":
+		size, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || size < MinHashSize || size > MaxHashSize {
+			fmt.Println("info string Invalid Hash value:", value)
+			return
+		}
+		engine.hashSize = size
+	case "threads":
+		threads, err := strconv.Atoi(value)
+		if err != nil || threads < 1 || threads > MaxSearchThreads {
+			fmt.Println("info string Invalid Threads value:", value)
+			return
+		}
+		SetSearchThreads(threads)
+	case "bookfile":
+		if value == "<empty>" {
+			value = ""
+		}
+		if err := SetBookFile(value); err != nil {
+			fmt.Println("info string Could not load book:", err)
+		}
+	case "bookdepth":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 0 || depth > MaxBookDepth {
+			fmt.Println("info string Invalid BookDepth value:", value)
+			return
+		}
+		bookSettings.depth = depth
+	case "bookvariety":
+		variety, err := strconv.Atoi(value)
+		if err != nil || variety < 0 || variety > 100 {
+			fmt.Println("info string Invalid BookVariety value:", value)
+			return
+		}
+		bookSettings.variety = variety
+	case "syzygypath":
+		if value == "<empty>" {
+			value = ""
+		}
+		if err := SetSyzygyPath(value); err != nil {
+			fmt.Println("info string Could not load tablebases:", err)
+		} else if tablebases != nil {
+			fmt.Printf("info string Found %d tablebases with up to %d pieces\n", tablebases.Count(), tablebases.MaxPieces())
+		}
+	case "evalfile":
+		if value == "<empty>" {
+			value = ""
+		}
+		if err := SetEvalFile(value); err != nil {
+			fmt.Println("info string Could not load network:", err)
+		} else if evalNetwork != nil {
+			fmt.Printf("info string Loaded network %s with %d hidden neurons\n", evalNetwork.path, evalNetwork.hidden)
+		}
+	case "usennue":
+		use, err := strconv.ParseBool(value)
+		if err != nil {
+			fmt.Println("info string Invalid UseNNUE value:", value)
+			return
+		}
+		useNNUE = use
+	case "uci_chess960":
+		use, err := strconv.ParseBool(value)
+		if err != nil {
+			fmt.Println("info string Invalid UCI_Chess960 value:", value)
+			return
+		}
+		chess960 = use
+	case "evalparamsfile":
+		if value == "<empty>" {
+			value = ""
+		}
+		if err := SetEvalParamsFile(value); err != nil {
+			fmt.Println("info string Could not load evaluation parameters:", err)
+		}
+	default:
+		term, ok := evalParams.term(name)
+		if !ok {
+			fmt.Println("info string Unknown option:", name)
+		} else if !setEvalTerm(term, value) {
+			fmt.Printf("info string Invalid %s value: %s\n", term.name, value)
+		}
+	}
+}
+
+func parseOptionFields(fields []string) (string, string) {
+	nameParts := []string{}
+	valueParts := []string{}
+	var current *[]string
+	for _, field := range fields {
+		if field == "name" {
+			current = &nameParts
+		} else if field == "value" {
+			current = &valueParts
+		} else if current != nil {
+			*current = append(*current, field)
+		}
+	}
+	return strings.Join(nameParts, " "), strings.Join(valueParts, " ")
+}
+
+func (engine *UCIEngine) ensureTable() {
+	if engine.tableSize != engine.hashSize {
+		ResizeTable(engine.hashSize)
+		engine.tableSize = engine.hashSize
+	}
+}
+
+func (engine *UCIEngine) newGame() {
+	engine.searching.Wait()
+	engine.ensureTable()
+	clear(transpositionTable)
+	ClearSearchHistory()
+	lastMoveScore = startingEval
+	engine.state = StartingFen()
+}
+
+// Format is "startpos|fen <fen> [moves <move1> ... <movei>]"
+func (engine *UCIEngine) setPosition(fields []string) {
+	engine.searching.Wait()
+	if len(fields) == 0 {
+		return
+	}
+	movesIndex := len(fields)
+	for i, field := range fields {
+		if field == "moves" {
+			movesIndex = i
+			break
+		}
+	}
+	var state *State
+	if fields[0] == "startpos" {
+		state = StartingFen()
+	} else if fields[0] == "fen" {
+		var err error
+		state, err = ParseFEN(strings.Join(fields[1:movesIndex], " "))
+		if err != nil {
+			fmt.Println("info string", err)
+			return
+		}
+	} else {
+		fmt.Println("info string Invalid position command")
+		return
+	}
+	for i := movesIndex + 1; i < len(fields); i++ {
+		move, ok := state.moveFromShortString(fields[i])
+		if !ok {
+			fmt.Println("info string Illegal move:", fields[i])
+			break
+		}
+		state.MakeMove(move)
+	}
+	engine.state = state
+}
+
+// Not part of the protocol, prints the breakdown of the handcrafted evaluation of the current position
+func (engine *UCIEngine) printEval() {
+	// Not waiting for the search as go infinite only ends with stop
+	if engine.running.Load() {
+		fmt.Println("info string Can not evaluate while searching")
+		return
+	}
+	fmt.Print(engine.state.TraceEval())
+	if nnueEnabled() {
+		fmt.Printf("NNUE: %.2f (white side)\n", float64(engine.state.EvalState(White))/float64(CentiPawn)/100)
+	}
+}
+
+func (engine *UCIEngine) goSearch(fields []string) {
+	engine.searching.Wait()
+	engine.ensureTable()
+	parameters := parseGoParameters(fields)
+	if !parameters.infinite {
+		if move, ok := engine.state.BookMove(); ok {
+			fmt.Println("info string Book move")
+			fmt.Println("bestmove", engine.state.uciMoveString(move))
+			return
+		}
+	}
+	limits := parameters.searchLimits(engine.state.turn)
+	stopSearch.Store(false)
+	engine.searching.Add(1)
+	engine.running.Store(true)
+	state := engine.state
+	go func() {
+		defer engine.searching.Done()
+		bestMove := state.Search(limits, false, func(report SearchReport) { printUCIInfo(report, state) })
+		engine.running.Store(false)
+		// The protocol does not allow a bestmove before stop when searching infinitely
+		for parameters.infinite && !stopSearch.Load() {
+			time.Sleep(5 * time.Millisecond)
+		}
+		fmt.Println("bestmove", state.uciMoveString(bestMove))
+	}()
+}
+
+func parseGoParameters(fields []string) UCIGoParameters {
+	parameters := UCIGoParameters{}
+	for i := 0; i < len(fields); i++ {
+		value := int64(0)
+		if i+1 < len(fields) {
+			value, _ = strconv.ParseInt(fields[i+1], 10, 64)
+		}
+		switch fields[i] {
+		case "wtime":
+			parameters.timeLeft[White] = time.Duration(value) * time.Millisecond
+			i++
+		case "btime":
+			parameters.timeLeft[Black] = time.Duration(value) * time.Millisecond
+			i++
+		case "winc":
+			parameters.increment[White] = time.Duration(value) * time.Millisecond
+			i++
+		case "binc":
+			parameters.increment[Black] = time.Duration(value) * time.Millisecond
+			i++
+		case "movestogo":
+			parameters.movesToGo = int(value)
+			i++
+		case "movetime":
+			parameters.moveTime = time.Duration(value) * time.Millisecond
+			i++
+		case "depth":
+			parameters.depth = int32(value)
+			i++
+		case "nodes":
+			parameters.nodes = uint64(value)
+			i++
+		case "infinite":
+			parameters.infinite = true
+		}
+	}
+	return parameters
+}
+
+func (parameters *UCIGoParameters) searchLimits(turn uint8) SearchLimits {
+	limits := SearchLimits{maxDepth: parameters.depth, maxNodes: parameters.nodes}
+	if parameters.infinite {
+		return limits
+	}
+	if parameters.moveTime != 0 {
+		limits.maxTime = parameters.moveTime
+	} else if parameters.timeLeft[turn] != 0 {
+		limits.timeManager = NewTimeManager(parameters.timeLeft[turn], parameters.increment[turn], parameters.movesToGo)
+	}
+	return limits
+}
+
+// Only the castling rooks of the state are used, which stay the same while it is searched
+func printUCIInfo(report SearchReport, s *State) {
+	nps := uint64(float64(report.nodes) / max(report.elapsed.Seconds(), 0.001))
+	pvString := ""
+	for _, move := range report.pv {
+		pvString += " " + s.uciMoveString(move)
+	}
+	fmt.Printf("info depth %d score %s nodes %d nps %d tbhits %d time %d pv%s\n", report.depth, uciScore(report.score), report.nodes, nps, report.tbHits,
+		report.elapsed.Milliseconds(), pvString)
+}
+
+func uciScore(score int32) string {
+	if score > mateValueCutoff {
+		return fmt.Sprintf("mate %d", (highestEval-score+1)/2)
+	} else if score < -mateValueCutoff {
+		return fmt.Sprintf("mate %d", -(highestEval+score+1)/2)
+	}
+	return fmt.Sprintf("cp %d", score/CentiPawn)
+}
+
+// Coordinate notation, except that castling is the king capturing its own rook with UCI_Chess960
+func (s *State) uciMoveString(move Move) string {
+	if chess960 && move.SpecialMove() == CastleSpecialMove {
+		return move.OriginSquare().String() + s.castleRookSquare(move).String()
+	}
+	return move.ShortString()
+}
+
+// Resolves a move in coordinate notation (eg e2e4, e7e8q) to a legal move in the current state
+func (s *State) moveFromShortString(moveString string) (Move, bool) {
+	moveString = strings.ToLower(moveString)
+	for _, move := range LegalMoves(s) {
+		if s.uciMoveString(move) == moveString {
+			return move, true
+		}
+	}
+	return NilMove, false
+}
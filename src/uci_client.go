@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const uciStartupTimeout = 60 * time.Second
+
+// Drives an external engine over UCI, used by the match runner for both sides
+type UCIClient struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string // Closed when the engine exits
+}
+
+// Result of a single go command
+type UCIClientMove struct {
+	move     string
+	score    int32 // Centipawns relative to the side to move, mates are scaled past uciMateCentiPawns
+	hasScore bool
+	elapsed  time.Duration
+}
+
+const uciMateCentiPawns = 100000
+
+func StartUCIClient(name string, command string, args []string, options []UCIOption) (*UCIClient, error) {
+	cmd := exec.Command(command, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+	client := &UCIClient{name: name, cmd: cmd, stdin: stdin, lines: make(chan string, 64)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			client.lines <- scanner.Text()
+		}
+		close(client.lines)
+	}()
+	client.send("uci")
+	if _, err := client.waitFor("uciok", uciStartupTimeout); err != nil {
+		client.Quit()
+		return nil, err
+	}
+	for _, option := range options {
+		client.send(fmt.Sprintf("setoption name %s value %s", option.name, option.value))
+	}
+	if err := client.ready(); err != nil {
+		client.Quit()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (client *UCIClient) send(command string) {
+	fmt.Fprintln(client.stdin, command)
+}
+
+// Returns the first line starting with the prefix, skipping everything before it
+func (client *UCIClient) waitFor(prefix string, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-client.lines:
+			if !ok {
+				return "", fmt.Errorf("%s exited while waiting for %s", client.name, prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, nil
+			}
+		case <-timer.C:
+			return "", fmt.Errorf("%s did not send %s within %v", client.name, prefix, timeout)
+		}
+	}
+}
+
+func (client *UCIClient) ready() error {
+	client.send("isready")
+	_, err := client.waitFor("readyok", uciStartupTimeout)
+	return err
+}
+
+func (client *UCIClient) NewGame() error {
+	client.send("ucinewgame")
+	return client.ready()
+}
+
+// Sends the position and go command and waits for the best move, keeping the last reported score
+func (client *UCIClient) Go(position string, goCommand string, timeout time.Duration) (UCIClientMove, error) {
+	result := UCIClientMove{}
+	client.send(position)
+	client.send(goCommand)
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-client.lines:
+			if !ok {
+				return result, fmt.Errorf("%s exited during its move", client.name)
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if fields[0] == "info" {
+				if score, ok := parseUCIInfoScore(fields); ok {
+					result.score = score
+					result.hasScore = true
+				}
+			} else if fields[0] == "bestmove" {
+				result.elapsed = time.Since(start)
+				if len(fields) < 2 {
+					return result, fmt.Errorf("%s sent an empty bestmove", client.name)
+				}
+				result.move = fields[1]
+				return result, nil
+			}
+		case <-timer.C:
+			return result, fmt.Errorf("%s did not move within %v", client.name, timeout)
+		}
+	}
+}
+
+func parseUCIInfoScore(fields []string) (int32, bool) {
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] != "score" {
+			continue
+		}
+		value, err := strconv.Atoi(fields[i+2])
+		if err != nil {
+			return 0, false
+		}
+		if fields[i+1] == "cp" {
+			return int32(value), true
+		} else if fields[i+1] == "mate" {
+			if value > 0 {
+				return uciMateCentiPawns - int32(value), true
+			}
+			return -uciMateCentiPawns - int32(value), true
+		}
+	}
+	return 0, false
+}
+
+func (client *UCIClient) Quit() {
+	client.send("quit")
+	client.stdin.Close()
+	// Output has to be read to the end before waiting on the process
+	go func() {
+		for range client.lines {
+		}
+	}()
+	done := make(chan error, 1)
+	go func() {
+		done <- client.cmd.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		client.cmd.Process.Kill()
+		<-done
+	}
+}
@@ -12,6 +12,13 @@ type RunningTimer struct {
 	laps      uint64
 }
 
+func absInt8(x int8) int8 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 func clampInt32(x int32, min int32, max int32) int32 {
 	if x > max {
 		return max
@@ -22,19 +29,24 @@ func clampInt32(x int32, min int32, max int32) int32 {
 	}
 }
 
-func getUserMove() Move {
+// Moves in SAN are resolved against the state, coordinate moves are returned without a promotion
+func getUserMove(s *State) Move {
 	fileMap := map[rune]int{'a': 0, 'b': 1, 'c': 2, 'd': 3, 'e': 4, 'f': 5, 'g': 6, 'h': 7}
 	for {
 		var moveInput string
-		fmt.Print("Please enter your move enter in the format source square destination square (eg e2e4): ")
+		fmt.Print("Please enter your move in SAN (eg Nf3) or in the format source square destination square (eg e2e4): ")
 		_, err := fmt.Scanln(&moveInput)
 		if err != nil {
 			fmt.Println("Error reading unput: ", err)
 			continue
 		}
-		if len(moveInput) != 4 {
-			fmt.Println("Invalid Move (Bad Format)")
-			continue
+		if !isCoordinateMove(moveInput) || len(moveInput) != 4 {
+			move, err := s.ParseSAN(moveInput)
+			if err != nil {
+				fmt.Printf("Invalid Move (%v)\n", err)
+				continue
+			}
+			return move
 		}
 		sourceFileRune := moveInput[0]
 		sourceRankRune := moveInput[1]
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSearchThreads = 1
+	MaxSearchThreads     = 256
+
+	MaxSearchPly = 256 // Nodes this far from the root are cut off with a static evaluation
+)
+
+// Scratch space for a single ply so generating moves at a node never overwrites its parent's moves
+type PlyData struct {
+	moveLists    MoveLists
+	orderedMoves []Move
+	killers      [2]Move
+}
+
+// Owns everything a search writes to except the shared transposition table. Any number of workers
+// can search at the same time from different goroutines as long as each one has its own state
+type SearchWorker struct {
+	id            int // 0 is the main thread, every other worker is a helper
+	state         *State
+	limits        SearchLimits
+	startTime     time.Time
+	deadline      time.Time    // The search is aborted after this unless it is zero
+	stop          *atomic.Bool // Set from outside to end the search early, may be nil
+	plies         []PlyData
+	trueDepth     int16 // The true depth from the root node
+	historyTable  HistoryTable
+	nodesSearched atomic.Uint64
+	tbHits        atomic.Uint64
+	rootMoves     []Move // Only these are searched at the root when set, eg after a tablebase probe
+	aborted       bool   // Once set every node unwinds immediately without storing results
+	abortAllowed  bool   // The main thread must finish its first iteration so there is always a move to play
+}
+
+// Workers persist between searches so history and killers carry over from move to move
+var searchWorkers []*SearchWorker
+
+// Set by the main thread when it is done to stop the helpers
+var helpersStop atomic.Bool
+
+func NewSearchWorker(id int) *SearchWorker {
+	plies := make([]PlyData, MaxSearchPly)
+	for i := range plies {
+		plies[i].moveLists = newMoveLists()
+		plies[i].orderedMoves = make([]Move, 0, quietMoveListSize+captureMoveListSize)
+		plies[i].killers = [2]Move{NilMove, NilMove}
+	}
+	return &SearchWorker{id: id, plies: plies, trueDepth: -1}
+}
+
+func SetSearchThreads(threads int) {
+	threads = max(1, min(threads, MaxSearchThreads))
+	searchWorkers = make([]*SearchWorker, threads)
+	for i := range threads {
+		searchWorkers[i] = NewSearchWorker(i)
+	}
+}
+
+func ClearSearchHistory() {
+	for _, worker := range searchWorkers {
+		worker.clearHistory()
+	}
+}
+
+func (w *SearchWorker) clearHistory() {
+	w.historyTable = HistoryTable{}
+	for i := range w.plies {
+		w.plies[i].killers = [2]Move{NilMove, NilMove}
+	}
+}
+
+// Searches the state using only this worker and returns the best move and its score
+func (w *SearchWorker) Run(state *State, limits SearchLimits, stop *atomic.Bool, reporter SearchReporter) (Move, int32) {
+	w.prepare(state, limits, time.Now(), stop)
+	return w.iterativeDeepening(startingEval, false, reporter)
+}
+
+func (w *SearchWorker) prepare(state *State, limits SearchLimits, startTime time.Time, stop *atomic.Bool) {
+	w.state = state
+	w.limits = limits
+	w.startTime = startTime
+	w.deadline = time.Time{}
+	if limits.maxTime != 0 {
+		w.deadline = startTime.Add(limits.maxTime)
+	} else if limits.timeManager != nil {
+		w.deadline = startTime.Add(limits.timeManager.hardLimit)
+	}
+	w.stop = stop
+	w.trueDepth = -1
+	w.nodesSearched.Store(0)
+	w.tbHits.Store(0)
+	w.rootMoves = nil
+	w.aborted = false
+	w.abortAllowed = w.id != 0
+}
+
+func totalNodesSearched() uint64 {
+	total := uint64(0)
+	for _, worker := range searchWorkers {
+		total += worker.nodesSearched.Load()
+	}
+	return total
+}
+
+func totalTablebaseHits() uint64 {
+	total := uint64(0)
+	for _, worker := range searchWorkers {
+		total += worker.tbHits.Load()
+	}
+	return total
+}
+
+func (w *SearchWorker) stopRequested() bool {
+	return w.stop != nil && w.stop.Load()
+}
+
+// Polled at every node so the check itself only happens about once every thousand nodes
+func (w *SearchWorker) pollStop(nodes uint64) bool {
+	if !w.aborted && w.abortAllowed && nodes&stopPollMask == 0 {
+		w.aborted = w.stopRequested() || (!w.deadline.IsZero() && time.Now().After(w.deadline)) || !w.limits.withinNodes(nodes)
+	}
+	return w.aborted
+}
+
+func (w *SearchWorker) addKiller(move Move) {
+	killers := &w.plies[w.trueDepth].killers
+	killers[1] = killers[0]
+	killers[0] = move
+}
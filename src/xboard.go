@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const xboardMateScore = 100000
+
+type XBoardEngine struct {
+	state         *State
+	moveHistory   []Move
+	engineSide    uint8
+	forceMode     bool
+	post          bool
+	searching     bool
+	searchDone    chan Move
+	movesPerLevel int
+	baseTime      time.Duration
+	increment     time.Duration
+	moveTime      time.Duration
+	maxDepth      int32
+	timeLeft      time.Duration
+}
+
+// Expects the initial "xboard" command to have already been read from standard input
+func XBoardLoop() {
+	engine := &XBoardEngine{searchDone: make(chan Move)}
+	engine.newGame()
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				engine.abortSearch()
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if !engine.handleCommand(fields) {
+				engine.abortSearch()
+				return
+			}
+		case move := <-engine.searchDone:
+			engine.searching = false
+			engine.playEngineMove(move)
+		}
+	}
+}
+
+// Returns false when the engine should exit
+func (engine *XBoardEngine) handleCommand(fields []string) bool {
+	switch fields[0] {
+	case "protover":
+		fmt.Printf("feature myname=\"%s\" usermove=1 setboard=1 ping=1 playother=1 colors=0 analyze=0 smp=1 egt=\"syzygy\" sigint=0 sigterm=0\n", EngineName)
+		fmt.Println("feature option=\"BookFile -file \"")
+		fmt.Printf("feature option=\"BookDepth -spin %d 0 %d\"\n", DefaultBookDepth, MaxBookDepth)
+		fmt.Printf("feature option=\"BookVariety -spin %d 0 100\"\n", DefaultBookVariety)
+		fmt.Println("feature option=\"EvalFile -file \"")
+		fmt.Println("feature option=\"UseNNUE -check 0\"")
+		fmt.Println("feature option=\"EvalParamsFile -file \"")
+		for _, term := range defaultEvalParams.terms() {
+			if len(term.values) == 1 {
+				fmt.Printf("feature option=\"%s -spin %d %d %d\"\n", term.name, *term.values[0], -MaxEvalParam, MaxEvalParam)
+			}
+		}
+		fmt.Println("feature done=1")
+	case "new":
+		engine.abortSearch()
+		engine.newGame()
+	case "force":
+		engine.abortSearch()
+		engine.forceMode = true
+	case "go":
+		engine.abortSearch()
+		engine.forceMode = false
+		engine.engineSide = engine.state.turn
+		engine.startSearch()
+	case "playother":
+		engine.abortSearch()
+		engine.forceMode = false
+		engine.engineSide = 1 - engine.state.turn
+	case "usermove":
+		if len(fields) > 1 {
+			engine.userMove(fields[1])
+		}
+	case "setboard":
+		engine.abortSearch()
+		engine.setBoard(strings.Join(fields[1:], " "))
+	case "level":
+		if len(fields) == 4 {
+			engine.setLevel(fields[1], fields[2], fields[3])
+		}
+	case "st":
+		if len(fields) > 1 {
+			seconds, _ := strconv.ParseFloat(fields[1], 64)
+			engine.moveTime = time.Duration(seconds * float64(time.Second))
+		}
+	case "sd":
+		if len(fields) > 1 {
+			depth, _ := strconv.Atoi(fields[1])
+			engine.maxDepth = int32(depth)
+		}
+	case "time":
+		if len(fields) > 1 {
+			centiseconds, _ := strconv.Atoi(fields[1])
+			engine.timeLeft = time.Duration(centiseconds) * 10 * time.Millisecond
+		}
+	case "undo":
+		engine.abortSearch()
+		engine.undoMoves(1)
+	case "remove":
+		engine.abortSearch()
+		engine.undoMoves(2)
+	case "post":
+		engine.post = true
+	case "nopost":
+		engine.post = false
+	case "result":
+		engine.abortSearch()
+		engine.forceMode = true
+	case "?":
+		stopSearch.Store(true)
+	case "cores":
+		if len(fields) > 1 {
+			engine.abortSearch()
+			threads, _ := strconv.Atoi(fields[1])
+			SetSearchThreads(threads)
+		}
+	case "option":
+		engine.abortSearch()
+		engine.setOption(strings.Join(fields[1:], " "))
+	case "egtpath":
+		if len(fields) > 2 && fields[1] == "syzygy" {
+			engine.abortSearch()
+			if err := SetSyzygyPath(strings.Join(fields[2:], " ")); err != nil {
+				fmt.Println("tellusererror Could not load tablebases:", err)
+			}
+		}
+	case "ping":
+		if len(fields) > 1 {
+			fmt.Println("pong", fields[1])
+		}
+	case "quit":
+		return false
+	case "xboard", "accepted", "rejected", "random", "easy", "hard", "computer", "name", "rating", "otim", "ics", "draw":
+	default:
+		// Moves are sent without the usermove prefix if the interface rejected that feature
+		if isCoordinateMove(fields[0]) {
+			engine.userMove(fields[0])
+		} else {
+			fmt.Println("Error (unknown command):", fields[0])
+		}
+	}
+	return true
+}
+
+// Format is "NAME=VALUE" for the options sent as features
+func (engine *XBoardEngine) setOption(option string) {
+	name, value, _ := strings.Cut(option, "=")
+	switch name {
+	case "BookFile":
+		if err := SetBookFile(value); err != nil {
+			fmt.Println("tellusererror Could not load book:", err)
+		}
+	case "BookDepth":
+		depth, err := strconv.Atoi(value)
+		if err == nil && depth >= 0 && depth <= MaxBookDepth {
+			bookSettings.depth = depth
+		}
+	case "BookVariety":
+		variety, err := strconv.Atoi(value)
+		if err == nil && variety >= 0 && variety <= 100 {
+			bookSettings.variety = variety
+		}
+	case "EvalFile":
+		if err := SetEvalFile(value); err != nil {
+			fmt.Println("tellusererror Could not load network:", err)
+		}
+	case "UseNNUE":
+		useNNUE = value == "1"
+	case "EvalParamsFile":
+		if err := SetEvalParamsFile(value); err != nil {
+			fmt.Println("tellusererror Could not load evaluation parameters:", err)
+		}
+	default:
+		if term, ok := evalParams.term(name); !ok {
+			fmt.Println("Error (unknown option):", name)
+		} else if !setEvalTerm(term, value) {
+			fmt.Printf("Error (bad value): %s=%s\n", name, value)
+		}
+	}
+}
+
+func isCoordinateMove(moveString string) bool {
+	if len(moveString) != 4 && len(moveString) != 5 {
+		return false
+	}
+	return isSquareString(moveString[0:2]) && isSquareString(moveString[2:4])
+}
+
+func (engine *XBoardEngine) newGame() {
+	clear(transpositionTable)
+	ClearSearchHistory()
+	lastMoveScore = startingEval
+	engine.state = StartingFen()
+	engine.moveHistory = engine.moveHistory[:0]
+	engine.engineSide = Black
+	engine.forceMode = false
+	engine.timeLeft = engine.baseTime
+	engine.maxDepth = 0
+}
+
+func (engine *XBoardEngine) setBoard(fen string) {
+	state, err := ParseFEN(fen)
+	if err != nil {
+		fmt.Println("tellusererror Illegal position:", err)
+		return
+	}
+	engine.state = state
+	engine.moveHistory = engine.moveHistory[:0]
+}
+
+// Base time is either minutes or minutes:seconds, increment is in seconds
+func (engine *XBoardEngine) setLevel(movesPerLevel string, base string, increment string) {
+	engine.movesPerLevel, _ = strconv.Atoi(movesPerLevel)
+	minutesSeconds := strings.Split(base, ":")
+	minutes, _ := strconv.ParseFloat(minutesSeconds[0], 64)
+	engine.baseTime = time.Duration(minutes * float64(time.Minute))
+	if len(minutesSeconds) > 1 {
+		seconds, _ := strconv.Atoi(minutesSeconds[1])
+		engine.baseTime += time.Duration(seconds) * time.Second
+	}
+	incrementSeconds, _ := strconv.ParseFloat(increment, 64)
+	engine.increment = time.Duration(incrementSeconds * float64(time.Second))
+	engine.timeLeft = engine.baseTime
+	engine.moveTime = 0
+}
+
+func (engine *XBoardEngine) userMove(moveString string) {
+	engine.abortSearch()
+	move, ok := engine.state.moveFromShortString(moveString)
+	if !ok {
+		fmt.Println("Illegal move:", moveString)
+		return
+	}
+	engine.state.MakeMove(move)
+	engine.moveHistory = append(engine.moveHistory, move)
+	if engine.reportResult() {
+		return
+	}
+	if !engine.forceMode && engine.state.turn == engine.engineSide {
+		engine.startSearch()
+	}
+}
+
+func (engine *XBoardEngine) undoMoves(count int) {
+	for i := 0; i < count && len(engine.moveHistory) > 0; i++ {
+		lastMove := engine.moveHistory[len(engine.moveHistory)-1]
+		engine.state.UnMakeMove(lastMove)
+		engine.moveHistory = engine.moveHistory[:len(engine.moveHistory)-1]
+	}
+}
+
+func (engine *XBoardEngine) searchLimits() SearchLimits {
+	limits := SearchLimits{maxDepth: engine.maxDepth}
+	if engine.moveTime != 0 {
+		limits.maxTime = engine.moveTime
+	} else if engine.timeLeft != 0 {
+		movesToGo := 0
+		// Levels count from new or setboard, so the move number in a set up position does not matter.
+		// Whichever side started, the side to move has played half of the moves since then
+		if engine.movesPerLevel != 0 {
+			movesToGo = engine.movesPerLevel - len(engine.moveHistory)/2%engine.movesPerLevel
+		}
+		limits.timeManager = NewTimeManager(engine.timeLeft, engine.increment, movesToGo)
+	}
+	return limits
+}
+
+func (engine *XBoardEngine) startSearch() {
+	if _, _, over := engine.state.gameResult(); over {
+		return
+	}
+	if move, ok := engine.state.BookMove(); ok {
+		engine.playEngineMove(move)
+		return
+	}
+	limits := engine.searchLimits()
+	var reporter SearchReporter
+	if engine.post {
+		reporter = printXBoardThinking
+	}
+	stopSearch.Store(false)
+	engine.searching = true
+	go func() {
+		engine.searchDone <- engine.state.Search(limits, false, reporter)
+	}()
+}
+
+// Stops any running search and throws away its result
+func (engine *XBoardEngine) abortSearch() {
+	if engine.searching {
+		stopSearch.Store(true)
+		<-engine.searchDone
+		engine.searching = false
+	}
+}
+
+func (engine *XBoardEngine) playEngineMove(move Move) {
+	engine.state.MakeMove(move)
+	engine.moveHistory = append(engine.moveHistory, move)
+	fmt.Println("move", move.ShortString())
+	engine.reportResult()
+}
+
+// Returns true if the game is over
+func (engine *XBoardEngine) reportResult() bool {
+	result, reason, over := engine.state.gameResult()
+	if over {
+		fmt.Printf("%s {%s}\n", result, reason)
+	}
+	return over
+}
+
+// Format is "ply score time nodes pv" with score in centipawns and time in centiseconds
+func printXBoardThinking(report SearchReport) {
+	score := report.score / CentiPawn
+	if report.score > mateValueCutoff {
+		score = xboardMateScore + (highestEval-report.score+1)/2
+	} else if report.score < -mateValueCutoff {
+		score = -xboardMateScore - (highestEval+report.score+1)/2
+	}
+	pvString := ""
+	for _, move := range report.pv {
+		pvString += " " + move.ShortString()
+	}
+	fmt.Printf("%d %d %d %d%s\n", report.depth, score, report.elapsed.Milliseconds()/10, report.nodes, pvString)
+}